@@ -54,6 +54,37 @@ License Fields
   {{- end -}}
 {{- end -}}
 
+{{/*
+License ID, used by the support bundle collectors to authenticate with the license-id auth mode
+*/}}
+{{- define "replicated.licenseID" -}}
+  {{- if (.Values.integration).licenseID -}}
+    {{- .Values.integration.licenseID -}}
+  {{- else if .Values.license -}}
+    {{- ((.Values.license | fromYaml).spec).licenseID -}}
+  {{- end -}}
+{{- end -}}
+
+{{/*
+Support bundle http collector options, the collectors authenticate with the license id and trust the certificate of the api
+*/}}
+{{- define "replicated.supportBundleHTTPOptions" -}}
+headers:
+  User-Agent: "troubleshoot.sh/support-bundle"
+  Authorization: {{ include "replicated.licenseID" . | quote }}
+{{- if (.Values.tls).enabled }}
+{{- if .Values.tls.clientAuth }}
+tls:
+  secret:
+    name: {{ required "tls.supportBundleClientSecretName is required when tls.clientAuth is enabled" .Values.tls.supportBundleClientSecretName }}
+    namespace: {{ include "replicated.namespace" . }}
+{{- else }}
+insecureSkipVerify: true
+{{- end }}
+{{- end }}
+timeout: 5s
+{{- end -}}
+
 {{/*
 Is OpenShift
 */}}
@@ -67,6 +98,22 @@ Is OpenShift
   {{- $isOpenShift }}
 {{- end }}
 
+{{/*
+Token Review Enabled
+*/}}
+{{- define "replicated.tokenReviewEnabled" -}}
+  {{- $enabled := false }}
+  {{- if has "token-review" ((.Values.auth).defaultModes | default list) -}}
+    {{- $enabled = true }}
+  {{- end -}}
+  {{- range ((.Values.auth).routes | default list) -}}
+    {{- if has "token-review" (.modes | default list) -}}
+      {{- $enabled = true }}
+    {{- end -}}
+  {{- end -}}
+  {{- $enabled }}
+{{- end }}
+
 {{/*
 Resource Names
 */}}
@@ -78,6 +125,14 @@ Resource Names
   {{ include "replicated.name" . }}-role
 {{- end -}}
 
+{{- define "replicated.clusterRoleName" -}}
+  {{ include "replicated.name" . }}-{{ include "replicated.namespace" . }}-tokenreview
+{{- end -}}
+
+{{- define "replicated.nodesClusterRoleName" -}}
+  {{ include "replicated.name" . }}-{{ include "replicated.namespace" . }}-nodes
+{{- end -}}
+
 {{- define "replicated.roleBindingName" -}}
   {{ include "replicated.name" . }}-rolebinding
 {{- end -}}
@@ -86,6 +141,10 @@ Resource Names
   {{ include "replicated.name" . }}
 {{- end -}}
 
+{{- define "replicated.containerPort" -}}
+  {{ splitList ":" (.Values.listenAddress | default ":3000") | last | default "3000" }}
+{{- end -}}
+
 {{- define "replicated.serviceName" -}}
   {{ include "replicated.name" . }}
 {{- end -}}
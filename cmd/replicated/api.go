@@ -56,34 +56,62 @@ func APICmd() *cobra.Command {
 				return errors.New("only one of license in the config file or integration license id can be specified")
 			}
 
-			params := apiserver.APIServerParams{
-				Context:               cmd.Context(),
-				LicenseBytes:          []byte(replicatedConfig.License),
-				IntegrationLicenseID:  integrationLicenseID,
-				LicenseFields:         replicatedConfig.LicenseFields,
-				AppName:               replicatedConfig.AppName,
-				ChannelID:             replicatedConfig.ChannelID,
-				ChannelName:           replicatedConfig.ChannelName,
-				ChannelSequence:       replicatedConfig.ChannelSequence,
-				ReleaseSequence:       replicatedConfig.ReleaseSequence,
-				ReleaseCreatedAt:      replicatedConfig.ReleaseCreatedAt,
-				ReleaseNotes:          replicatedConfig.ReleaseNotes,
-				VersionLabel:          replicatedConfig.VersionLabel,
-				ReplicatedAppEndpoint: replicatedConfig.ReplicatedAppEndpoint,
-				StatusInformers:       replicatedConfig.StatusInformers,
-				ReplicatedID:          replicatedConfig.ReplicatedID,
-				AppID:                 replicatedConfig.AppID,
-				Namespace:             namespace,
+			// flags take precedence over the config file
+			if listenAddress := v.GetString("listen-address"); listenAddress != "" {
+				replicatedConfig.ListenAddress = listenAddress
+			}
+			if certFile := v.GetString("tls-cert-file"); certFile != "" {
+				replicatedConfig.TLS.CertFile = certFile
+			}
+			if keyFile := v.GetString("tls-key-file"); keyFile != "" {
+				replicatedConfig.TLS.KeyFile = keyFile
+			}
+			if clientCAFile := v.GetString("tls-client-ca-file"); clientCAFile != "" {
+				replicatedConfig.TLS.ClientCAFile = clientCAFile
 			}
-			apiserver.Start(params)
 
-			return nil
+			params := apiserver.APIServerParams{
+				Context:                      cmd.Context(),
+				LicenseBytes:                 []byte(replicatedConfig.License),
+				IntegrationLicenseID:         integrationLicenseID,
+				LicenseFields:                replicatedConfig.LicenseFields,
+				AppName:                      replicatedConfig.AppName,
+				ChannelID:                    replicatedConfig.ChannelID,
+				ChannelName:                  replicatedConfig.ChannelName,
+				ChannelSequence:              replicatedConfig.ChannelSequence,
+				ReleaseSequence:              replicatedConfig.ReleaseSequence,
+				ReleaseCreatedAt:             replicatedConfig.ReleaseCreatedAt,
+				ReleaseNotes:                 replicatedConfig.ReleaseNotes,
+				VersionLabel:                 replicatedConfig.VersionLabel,
+				ReplicatedAppEndpoint:        replicatedConfig.ReplicatedAppEndpoint,
+				StatusInformers:              replicatedConfig.StatusInformers,
+				StatusAggregation:            replicatedConfig.StatusAggregation,
+				StatusDamping:                replicatedConfig.StatusDamping,
+				CertificateExpiryWarningDays: replicatedConfig.CertificateExpiryWarningDays,
+				ReplicatedID:                 replicatedConfig.ReplicatedID,
+				AppID:                        replicatedConfig.AppID,
+				Namespace:                    namespace,
+				LeaderElection:               v.GetBool("leader-election"),
+				Auth:                         replicatedConfig.Auth,
+				ListenAddress:                replicatedConfig.ListenAddress,
+				TLS:                          replicatedConfig.TLS,
+				Webhooks:                     replicatedConfig.Webhooks,
+				CustomMetrics:                replicatedConfig.CustomMetrics,
+				CustomMetricsBatching:        replicatedConfig.CustomMetricsBatching,
+				ClusterInventory:             replicatedConfig.ClusterInventory,
+			}
+			return apiserver.Start(params)
 		},
 	}
 
 	cmd.Flags().String("config-file", "", "path to the replicated config file")
 	cmd.Flags().String("namespace", "", "the namespace where replicated/application is installed")
 	cmd.Flags().String("integration-license-id", "", "the id of the license to use")
+	cmd.Flags().Bool("leader-election", false, "enable leader election to run multiple replicas")
+	cmd.Flags().String("listen-address", "", "the address and port the api listens on (default \":3000\")")
+	cmd.Flags().String("tls-cert-file", "", "path to the tls certificate file, enables tls")
+	cmd.Flags().String("tls-key-file", "", "path to the tls private key file")
+	cmd.Flags().String("tls-client-ca-file", "", "path to the ca bundle used to verify client certificates, enables mtls")
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	if err := RootCmd().Execute(); err != nil {
+	// cancel the context on SIGTERM so that the api server can shutdown gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := RootCmd().ExecuteContext(ctx)
+	stop()
+
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -1,29 +1,59 @@
 package apiserver
 
 import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/cenkalti/backoff/v4"
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate"
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/auth"
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/heartbeat"
 	"github.com/replicatedhq/replicated-sdk/pkg/helm"
 	"github.com/replicatedhq/replicated-sdk/pkg/integration"
 	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
 	sdklicense "github.com/replicatedhq/replicated-sdk/pkg/license"
 	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/report"
 	"github.com/replicatedhq/replicated-sdk/pkg/store"
 	"github.com/replicatedhq/replicated-sdk/pkg/upstream"
 	upstreamtypes "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"github.com/replicatedhq/replicated-sdk/pkg/webhook"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	followerSyncInterval = 10 * time.Second
+	// stepDownTimeout bounds the time spent sending the buffered custom app metrics when leadership is lost,
+	// so that the leader election callback returns
+	stepDownTimeout = 10 * time.Second
 )
 
 func bootstrap(params APIServerParams) error {
+	if params.LeaderElection {
+		// replicas are not the leader until they are elected, so they do not checkpoint the store or send the
+		// webhooks of the events that are published while starting
+		leader.Enable()
+	}
+
 	clientset, err := k8sutil.GetClientset()
 	if err != nil {
 		return errors.Wrap(err, "failed to get clientset")
 	}
 
+	if err := auth.Init(params.Auth, clientset, params.Namespace); err != nil {
+		return backoff.Permanent(errors.Wrap(err, "failed to init auth"))
+	}
+
 	replicatedID, appID := params.ReplicatedID, params.AppID
 	if replicatedID == "" || appID == "" {
 		// retrieve replicated and app ids
@@ -90,22 +120,36 @@ func bootstrap(params APIServerParams) error {
 		channelName = verifiedLicense.Spec.ChannelName
 	}
 
-	store.InitInMemory(store.InitInMemoryStoreOptions{
-		License:               verifiedLicense,
-		LicenseFields:         params.LicenseFields,
-		AppName:               params.AppName,
-		ChannelID:             channelID,
-		ChannelName:           channelName,
-		ChannelSequence:       params.ChannelSequence,
-		ReleaseSequence:       params.ReleaseSequence,
-		ReleaseCreatedAt:      params.ReleaseCreatedAt,
-		ReleaseNotes:          params.ReleaseNotes,
-		VersionLabel:          params.VersionLabel,
-		ReplicatedAppEndpoint: params.ReplicatedAppEndpoint,
-		Namespace:             params.Namespace,
-		ReplicatedID:          replicatedID,
-		AppID:                 appID,
-	})
+	storeOptions := store.InitSecretStoreOptions{
+		InitInMemoryStoreOptions: store.InitInMemoryStoreOptions{
+			License:               verifiedLicense,
+			LicenseFields:         params.LicenseFields,
+			AppName:               params.AppName,
+			ChannelID:             channelID,
+			ChannelName:           channelName,
+			ChannelSequence:       params.ChannelSequence,
+			ReleaseSequence:       params.ReleaseSequence,
+			ReleaseCreatedAt:      params.ReleaseCreatedAt,
+			ReleaseNotes:          params.ReleaseNotes,
+			VersionLabel:          params.VersionLabel,
+			ReplicatedAppEndpoint: params.ReplicatedAppEndpoint,
+			Namespace:             params.Namespace,
+			ReplicatedID:          replicatedID,
+			AppID:                 appID,
+		},
+		Clientset: clientset,
+	}
+
+	// webhooks subscribe to the events before the store is initialized so that the events published while
+	// rehydrating the store and fetching the updates are delivered
+	if err := webhook.Start(params.Context, params.Webhooks); err != nil {
+		return backoff.Permanent(errors.Wrap(err, "failed to start webhooks"))
+	}
+
+	// the store is checkpointed to a secret so that the last known state is served right away after a restart
+	if err := store.InitSecret(storeOptions); err != nil {
+		return errors.Wrap(err, "failed to init store")
+	}
 
 	isIntegrationModeEnabled, err := integration.IsEnabled(params.Context, clientset, store.GetStore().GetNamespace(), store.GetStore().GetLicense())
 	if err != nil {
@@ -126,29 +170,97 @@ func bootstrap(params APIServerParams) error {
 		store.GetStore().SetUpdates(updates)
 	}
 
-	appStateOperator := appstate.InitOperator(clientset, params.Namespace)
-	appStateOperator.Start()
+	if err := custommetrics.Init(params.CustomMetrics); err != nil {
+		return backoff.Permanent(errors.Wrap(err, "invalid custom metrics"))
+	}
+
+	if err := params.CustomMetricsBatching.Validate(); err != nil {
+		return backoff.Permanent(errors.Wrap(err, "invalid custom metrics batching"))
+	}
+
+	report.InitClusterInventory(params.ClusterInventory)
+
+	if err := params.StatusAggregation.Validate(); err != nil {
+		return backoff.Permanent(errors.Wrap(err, "invalid status aggregation policy"))
+	}
+
+	if err := params.StatusDamping.Validate(); err != nil {
+		return backoff.Permanent(errors.Wrap(err, "invalid status damping policy"))
+	}
+
+	if params.CertificateExpiryWarningDays < 0 {
+		return backoff.Permanent(errors.Errorf("invalid certificate expiry warning of %d days", params.CertificateExpiryWarningDays))
+	}
 
-	// if no status informers are provided, generate them from the helm release
-	informers := params.StatusInformers
-	if informers == nil && helm.IsHelmManaged() {
+	informers := appstatetypes.AppInformersArgs{
+		AppSlug:                      store.GetStore().GetAppSlug(),
+		Sequence:                     store.GetStore().GetReleaseSequence(),
+		Informers:                    params.StatusInformers,
+		Aggregation:                  params.StatusAggregation,
+		Damping:                      params.StatusDamping,
+		CertificateExpiryWarningDays: params.CertificateExpiryWarningDays,
+	}
+	helmRevision := 0
+	if helm.IsHelmManaged() {
 		helmRelease, err := helm.GetRelease(helm.GetReleaseName())
 		if err != nil {
 			return errors.Wrap(err, "failed to get helm release")
 		}
 		if helmRelease != nil {
-			informers = appstate.GenerateStatusInformersForManifest(helmRelease.Manifest)
+			helmRevision = helmRelease.Version
+			// if no status informers are provided, generate them from the helm release
+			if informers.Informers == nil {
+				informers.Informers = appstatetypes.NewStatusInformerConfigs(appstate.GenerateStatusInformersForManifest(getStatusInformersManifest(helmRelease)))
+			}
 		}
 	}
+	setAppInformers(informers)
+
+	dynamicClient, err := k8sutil.GetDynamicClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get dynamic client")
+	}
 
-	appStateOperator.ApplyAppInformers(appstatetypes.AppInformersArgs{
-		AppSlug:   store.GetStore().GetAppSlug(),
-		Sequence:  store.GetStore().GetReleaseSequence(),
-		Informers: informers,
-	})
+	appStateOperator := appstate.InitOperator(clientset, dynamicClient, params.Namespace)
 
-	if err := heartbeat.Start(); err != nil {
-		return errors.Wrap(err, "failed to start heartbeat")
+	if params.LeaderElection {
+		if err := leader.InitReplicaToken(params.Context, clientset, params.Namespace); err != nil {
+			return errors.Wrap(err, "failed to init replica token")
+		}
+
+		// all replicas serve the api, but only the leader runs the status informers and the heartbeat
+		go func() {
+			err := leader.Run(params.Context, leader.RunOptions{
+				Clientset: clientset,
+				Namespace: params.Namespace,
+				Identity:  os.Getenv("REPLICATED_POD_NAME"),
+				OnStartedLeading: func() {
+					if err := startLeaderTasks(appStateOperator, params.CustomMetricsBatching); err != nil {
+						logger.Error(errors.Wrap(err, "failed to start leader tasks"))
+					}
+				},
+				OnStoppedLeading: func() {
+					stopLeaderTasks(appStateOperator)
+				},
+			})
+			if err != nil {
+				logger.Error(errors.Wrap(err, "failed to run leader election"))
+			}
+		}()
+		go syncFromLeader(params.Context)
+	} else if err := startLeaderTasks(appStateOperator, params.CustomMetricsBatching); err != nil {
+		return errors.Wrap(err, "failed to start leader tasks")
+	}
+
+	if secretStore, ok := store.GetStore().(*store.SecretStore); ok {
+		go secretStore.RunCustomAppMetricsHistoryCheckpoints(params.Context)
+	}
+
+	if helm.IsHelmManaged() {
+		// refresh the status informers when the helm release is upgraded without restarting the sdk
+		go helm.WatchRelease(params.Context, clientset, helmRevision, func(helmRelease *release.Release) {
+			refreshAppInformers(appStateOperator, params, helmRelease)
+		})
 	}
 
 	// this is at the end of the bootstrap function so that it doesn't re-run on retry
@@ -162,3 +274,84 @@ func bootstrap(params APIServerParams) error {
 
 	return nil
 }
+
+// getStatusInformersManifest returns the release manifest along with the hook jobs (e.g. migrations) that are not deleted when they succeed,
+// so that their status is monitored too.
+func getStatusInformersManifest(helmRelease *release.Release) string {
+	manifests := []string{helmRelease.Manifest}
+	for _, hook := range helmRelease.Hooks {
+		if hook == nil || hook.Kind != "Job" {
+			continue
+		}
+		if slices.Contains(hook.Events, release.HookTest) || slices.Contains(hook.DeletePolicies, release.HookSucceeded) {
+			continue
+		}
+		manifests = append(manifests, hook.Manifest)
+	}
+	return strings.Join(manifests, "\n---\n")
+}
+
+// startLeaderTasks starts the tasks that must only run in a single replica at a time.
+func startLeaderTasks(appStateOperator *appstate.Operator, customMetricsBatching custommetricstypes.BatchingConfig) error {
+	leaderTasksMtx.Lock()
+	defer leaderTasksMtx.Unlock()
+
+	leaderTasksRunning = true
+
+	// start the outbox first, so that the reports of the other tasks are queued if they fail to send
+	clientset, err := k8sutil.GetClientset()
+	if err != nil {
+		return errors.Wrap(err, "failed to get clientset")
+	}
+	if err := report.StartOutbox(clientset, store.GetStore().GetNamespace()); err != nil {
+		return errors.Wrap(err, "failed to start report outbox")
+	}
+	report.StartCustomAppMetricsBatch(clientset, customMetricsBatching)
+
+	appStateOperator.Start()
+	appStateOperator.ApplyAppInformers(appInformers)
+
+	if err := heartbeat.Start(); err != nil {
+		return errors.Wrap(err, "failed to start heartbeat")
+	}
+
+	return nil
+}
+
+func stopLeaderTasks(appStateOperator *appstate.Operator) {
+	leaderTasksMtx.Lock()
+	defer leaderTasksMtx.Unlock()
+
+	leaderTasksRunning = false
+	heartbeat.Stop()
+	appStateOperator.Shutdown()
+	// the buffered custom app metrics are sent before the outbox stops, so that they are queued for the next leader if they fail to send
+	ctx, cancel := context.WithTimeout(context.Background(), stepDownTimeout)
+	defer cancel()
+	report.StopCustomAppMetricsBatch(ctx)
+	report.StopOutbox()
+}
+
+// syncFromLeader periodically reloads the state checkpointed by the leader while this replica is not the leader.
+func syncFromLeader(ctx context.Context) {
+	ticker := time.NewTicker(followerSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if leader.IsLeader() {
+				continue
+			}
+			secretStore, ok := store.GetStore().(*store.SecretStore)
+			if !ok {
+				continue
+			}
+			if err := secretStore.Reload(ctx); err != nil {
+				logger.Error(errors.Wrap(err, "failed to reload store from leader checkpoint"))
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	upstreamtypes "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"github.com/replicatedhq/replicated-sdk/pkg/webhook"
+	webhooktypes "github.com/replicatedhq/replicated-sdk/pkg/webhook/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestBootstrap_NonLeaderReplica runs the startup sequence of bootstrap on a replica that has not been elected yet.
+func TestBootstrap_NonLeaderReplica(t *testing.T) {
+	req := require.New(t)
+
+	var delivered atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered.Add(1)
+	}))
+	defer srv.Close()
+
+	clientset := fake.NewSimpleClientset(
+		k8sutil.CreateTestDeployment(util.GetReplicatedDeploymentName(), "default", "1", map[string]string{"app": "replicated"}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leader.Enable()
+	defer leader.Disable()
+
+	req.NoError(webhook.Start(ctx, []webhooktypes.WebhookConfig{{URL: srv.URL, Secret: "secret"}}))
+	defer webhook.Stop()
+
+	req.NoError(store.InitSecret(store.InitSecretStoreOptions{
+		InitInMemoryStoreOptions: store.InitInMemoryStoreOptions{
+			License:   &kotsv1beta1.License{Spec: kotsv1beta1.LicenseSpec{LicenseID: "license-id", AppSlug: "app-slug"}},
+			Namespace: "default",
+		},
+		Clientset: clientset,
+	}))
+	defer store.SetStore(nil)
+	clientset.ClearActions()
+
+	store.GetStore().SetUpdates([]upstreamtypes.ChannelRelease{{VersionLabel: "1.0.1"}})
+
+	// the store is not checkpointed and the webhooks are not sent until the replica is elected
+	for _, action := range clientset.Actions() {
+		req.NotContains([]string{"create", "update"}, action.GetVerb())
+	}
+	time.Sleep(100 * time.Millisecond)
+	req.Equal(int32(0), delivered.Load())
+}
@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate"
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/helm"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+var (
+	// leaderTasksMtx serializes starting and stopping the leader tasks with applying new status informers
+	leaderTasksMtx     sync.Mutex
+	leaderTasksRunning bool
+	// appInformers are the status informers that are applied when the leader tasks start
+	appInformers appstatetypes.AppInformersArgs
+)
+
+func setAppInformers(args appstatetypes.AppInformersArgs) {
+	leaderTasksMtx.Lock()
+	defer leaderTasksMtx.Unlock()
+	appInformers = args
+}
+
+// refreshAppInformers regenerates the status informers for a new revision of the helm release,
+// and applies them if this replica is running the status informers.
+func refreshAppInformers(appStateOperator *appstate.Operator, params APIServerParams, helmRelease *release.Release) {
+	leaderTasksMtx.Lock()
+	defer leaderTasksMtx.Unlock()
+
+	next := appInformers
+	next.Informers = params.StatusInformers
+	next.Aggregation = params.StatusAggregation
+	next.Damping = params.StatusDamping
+	next.CertificateExpiryWarningDays = params.CertificateExpiryWarningDays
+
+	// the config file is only read when the sdk starts, so use the config of the new revision if it contains the replicated secret
+	replicatedConfig, err := helm.GetReplicatedConfig(helmRelease)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get replicated config from helm release revision %d", helmRelease.Version))
+	} else if replicatedConfig != nil {
+		next.Informers = replicatedConfig.StatusInformers
+		next.Aggregation = replicatedConfig.StatusAggregation
+		next.Damping = replicatedConfig.StatusDamping
+		next.CertificateExpiryWarningDays = replicatedConfig.CertificateExpiryWarningDays
+		if replicatedConfig.ReleaseSequence > 0 {
+			next.Sequence = replicatedConfig.ReleaseSequence
+		}
+	}
+
+	// if no status informers are provided, generate them from the helm release
+	if next.Informers == nil {
+		next.Informers = appstatetypes.NewStatusInformerConfigs(appstate.GenerateStatusInformersForManifest(getStatusInformersManifest(helmRelease)))
+	}
+
+	appInformers = next
+	if leaderTasksRunning {
+		appStateOperator.ApplyAppInformers(next)
+	}
+}
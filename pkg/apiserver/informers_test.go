@@ -0,0 +1,56 @@
+package apiserver
+
+import (
+	"testing"
+
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func Test_refreshAppInformers(t *testing.T) {
+	req := require.New(t)
+
+	setAppInformers(appstatetypes.AppInformersArgs{
+		AppSlug:   "app-slug",
+		Sequence:  1,
+		Informers: appstatetypes.NewStatusInformerConfigs([]appstatetypes.StatusInformerString{"default/deployment/web"}),
+	})
+
+	// a new revision adds a workload
+	refreshAppInformers(nil, APIServerParams{}, &release.Release{
+		Version: 2,
+		Manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: default
+`,
+	})
+	req.Equal("app-slug", appInformers.AppSlug)
+	req.Equal(int64(1), appInformers.Sequence)
+	req.Equal(appstatetypes.NewStatusInformerConfigs([]appstatetypes.StatusInformerString{"default/deployment/web", "default/statefulset/db"}), appInformers.Informers)
+
+	// a new revision with the replicated secret sets the release sequence and the informers
+	refreshAppInformers(nil, APIServerParams{}, &release.Release{
+		Version: 3,
+		Manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: replicated
+stringData:
+  config.yaml: |
+    releaseSequence: 5
+    statusInformers:
+    - deployment/api
+`,
+	})
+	req.Equal(int64(5), appInformers.Sequence)
+	req.Equal([]appstatetypes.StatusInformerConfig{{Informer: "deployment/api"}}, appInformers.Informers)
+}
@@ -0,0 +1,58 @@
+package apiserver
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/report"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/replicatedhq/replicated-sdk/pkg/tags"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// TestRoleAllowsSecretUpdates makes sure the chart's role allows updating every secret that the sdk writes,
+// otherwise the secrets are created but every later update is forbidden.
+func TestRoleAllowsSecretUpdates(t *testing.T) {
+	req := require.New(t)
+
+	template, err := os.ReadFile("../../chart/templates/replicated-role.yaml")
+	req.NoError(err)
+
+	// drop the template directives, the secret names that the sdk writes are not templated
+	lines := []string{}
+	for _, line := range strings.Split(string(template), "\n") {
+		if !strings.Contains(line, "{{") {
+			lines = append(lines, line)
+		}
+	}
+
+	var role struct {
+		Rules []struct {
+			Resources     []string `yaml:"resources"`
+			Verbs         []string `yaml:"verbs"`
+			ResourceNames []string `yaml:"resourceNames"`
+		} `yaml:"rules"`
+	}
+	req.NoError(yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &role))
+
+	updatableSecrets := []string{}
+	for _, rule := range role.Rules {
+		if slices.Contains(rule.Resources, "secrets") && slices.Contains(rule.Verbs, "update") {
+			updatableSecrets = append(updatableSecrets, rule.ResourceNames...)
+		}
+	}
+
+	for _, name := range []string{
+		store.StoreSecretName,
+		report.OutboxSecretName,
+		store.CustomAppMetricsHistorySecretName,
+		tags.InstanceMetadataSecretName,
+		(&report.InstanceReport{}).GetSecretName(),
+		(&report.CustomAppMetricsReport{}).GetSecretName(),
+	} {
+		req.Contains(updatableSecrets, name, "the role does not allow updating the %s secret", name)
+	}
+}
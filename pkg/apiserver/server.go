@@ -8,78 +8,156 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	apiservertypes "github.com/replicatedhq/replicated-sdk/pkg/apiserver/types"
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	authtypes "github.com/replicatedhq/replicated-sdk/pkg/auth/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/buildversion"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/events"
 	"github.com/replicatedhq/replicated-sdk/pkg/handlers"
 	sdklicensetypes "github.com/replicatedhq/replicated-sdk/pkg/license/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/metrics"
+	reporttypes "github.com/replicatedhq/replicated-sdk/pkg/report/types"
+	webhooktypes "github.com/replicatedhq/replicated-sdk/pkg/webhook/types"
 )
 
 type APIServerParams struct {
-	Context               context.Context
-	LicenseBytes          []byte
-	IntegrationLicenseID  string
-	LicenseFields         sdklicensetypes.LicenseFields
-	AppName               string
-	ChannelID             string
-	ChannelName           string
-	ChannelSequence       int64
-	ReleaseSequence       int64
-	ReleaseCreatedAt      string
-	ReleaseNotes          string
-	VersionLabel          string
-	ReplicatedAppEndpoint string
-	StatusInformers       []appstatetypes.StatusInformerString
-	ReplicatedID          string
-	AppID                 string
-	Namespace             string
+	Context                      context.Context
+	LicenseBytes                 []byte
+	IntegrationLicenseID         string
+	LicenseFields                sdklicensetypes.LicenseFields
+	AppName                      string
+	ChannelID                    string
+	ChannelName                  string
+	ChannelSequence              int64
+	ReleaseSequence              int64
+	ReleaseCreatedAt             string
+	ReleaseNotes                 string
+	VersionLabel                 string
+	ReplicatedAppEndpoint        string
+	StatusInformers              []appstatetypes.StatusInformerConfig
+	StatusAggregation            *appstatetypes.AggregationPolicy
+	StatusDamping                appstatetypes.DampingPolicy
+	CertificateExpiryWarningDays int
+	ReplicatedID                 string
+	AppID                        string
+	Namespace                    string
+	LeaderElection               bool
+	Auth                         authtypes.AuthConfig
+	ListenAddress                string
+	TLS                          apiservertypes.TLSConfig
+	Webhooks                     []webhooktypes.WebhookConfig
+	CustomMetrics                []custommetricstypes.MetricDefinition
+	CustomMetricsBatching        custommetricstypes.BatchingConfig
+	ClusterInventory             reporttypes.ClusterInventoryConfig
 }
 
-func Start(params APIServerParams) {
+const (
+	DefaultListenAddress = ":3000"
+)
+
+func Start(params APIServerParams) error {
 	log.Println("Replicated version:", buildversion.Version())
 
 	backoffDuration := 10 * time.Second
 	bootstrapFn := func() error {
 		return bootstrap(params)
 	}
-	err := backoff.RetryNotify(bootstrapFn, backoff.NewConstantBackOff(backoffDuration), func(err error, d time.Duration) {
+	err := backoff.RetryNotify(bootstrapFn, backoff.WithContext(backoff.NewConstantBackOff(backoffDuration), params.Context), func(err error, d time.Duration) {
 		log.Printf("failed to bootstrap, retrying in %s: %v", d, err)
 	})
 	if err != nil {
-		log.Fatalf("failed to bootstrap: %v", err)
+		return errors.Wrap(err, "failed to bootstrap")
+	}
+
+	var reloader *certReloader
+	if params.TLS.IsEnabled() {
+		reloader, err = newCertReloader(params.TLS)
+		if err != nil {
+			return errors.Wrap(err, "failed to load tls certificate")
+		}
+		go reloader.watch(params.Context)
+		handlers.SetLeaderTLSConfig(reloader.leaderTLSConfig())
+	} else if params.TLS.IsClientAuthEnabled() {
+		return errors.New("tls cert and key files are required for client certificate verification")
 	}
 
 	r := mux.NewRouter()
 	r.Use(handlers.CorsMiddleware)
 
-	// TODO: make all routes authenticated
-	authRouter := r.NewRoute().Subrouter()
-	authRouter.Use(handlers.RequireValidLicenseIDMiddleware)
-
 	r.HandleFunc("/healthz", handlers.Healthz)
 
+	// all other routes are authenticated
+	authRouter := r.NewRoute().Subrouter()
+	if params.TLS.IsClientAuthEnabled() {
+		authRouter.Use(handlers.RequireClientCertMiddleware)
+	}
+	authRouter.Use(handlers.RequireAuthMiddleware)
+
 	// license
-	r.HandleFunc("/api/v1/license/info", handlers.GetLicenseInfo).Methods("GET")
-	r.HandleFunc("/api/v1/license/fields", handlers.GetLicenseFields).Methods("GET")
-	r.HandleFunc("/api/v1/license/fields/{fieldName}", handlers.GetLicenseField).Methods("GET")
+	authRouter.HandleFunc("/api/v1/license/info", handlers.GetLicenseInfo).Methods("GET")
+	authRouter.HandleFunc("/api/v1/license/fields", handlers.GetLicenseFields).Methods("GET")
+	authRouter.HandleFunc("/api/v1/license/fields/{fieldName}", handlers.GetLicenseField).Methods("GET")
 
 	// app
-	r.HandleFunc("/api/v1/app/info", handlers.GetCurrentAppInfo).Methods("GET")
-	r.HandleFunc("/api/v1/app/updates", handlers.GetAppUpdates).Methods("GET")
-	r.HandleFunc("/api/v1/app/history", handlers.GetAppHistory).Methods("GET")
-	r.HandleFunc("/api/v1/app/custom-metrics", handlers.SendCustomAppMetrics).Methods("POST")
-	r.HandleFunc("/api/v1/app/instance-tags", handlers.SendAppInstanceTags).Methods("POST")
+	authRouter.HandleFunc("/api/v1/app/info", handlers.GetCurrentAppInfo).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/updates", handlers.GetAppUpdates).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/history", handlers.GetAppHistory).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/status/history", handlers.GetAppStatusHistory).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/status/stream", handlers.StreamAppStatus).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/custom-metrics", handlers.GetCustomAppMetrics).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/custom-metrics", handlers.ForwardToLeader(handlers.SendCustomAppMetrics)).Methods("POST")
+	authRouter.HandleFunc("/api/v1/app/custom-metrics/schema", handlers.GetCustomAppMetricsSchema).Methods("GET")
+	authRouter.HandleFunc("/api/v1/app/instance-tags", handlers.ForwardToLeader(handlers.SendAppInstanceTags)).Methods("POST")
+	authRouter.HandleFunc("/api/v1/app/outbox", handlers.ForwardToLeader(handlers.GetOutbox)).Methods("GET")
+
+	// webhooks
+	authRouter.HandleFunc("/api/v1/webhooks/deliveries", handlers.ForwardToLeader(handlers.GetWebhookDeliveries)).Methods("GET")
+
+	// metrics
+	authRouter.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	// integration
-	r.HandleFunc("/api/v1/integration/mock-data", handlers.EnforceMockAccess(handlers.PostIntegrationMockData)).Methods("POST")
-	r.HandleFunc("/api/v1/integration/mock-data", handlers.EnforceMockAccess(handlers.GetIntegrationMockData)).Methods("GET")
-	r.HandleFunc("/api/v1/integration/status", handlers.EnforceMockAccess(handlers.GetIntegrationStatus)).Methods("GET")
+	authRouter.HandleFunc("/api/v1/integration/mock-data", handlers.EnforceMockAccess(handlers.PostIntegrationMockData)).Methods("POST")
+	authRouter.HandleFunc("/api/v1/integration/mock-data", handlers.EnforceMockAccess(handlers.GetIntegrationMockData)).Methods("GET")
+	authRouter.HandleFunc("/api/v1/integration/status", handlers.EnforceMockAccess(handlers.GetIntegrationStatus)).Methods("GET")
+
+	listenAddress := params.ListenAddress
+	if listenAddress == "" {
+		listenAddress = DefaultListenAddress
+	}
 
 	srv := &http.Server{
 		Handler: r,
-		Addr:    ":3000",
+		Addr:    listenAddress,
+	}
+	// streams never become idle, end them so that the server can shut down gracefully
+	srv.RegisterOnShutdown(events.Shutdown)
+
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if reloader != nil {
+			srv.TLSConfig = reloader.serverTLSConfig()
+			log.Printf("Starting Replicated API on %s (TLS)...\n", listenAddress)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting Replicated API on %s...\n", listenAddress)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return errors.Wrap(err, "failed to serve")
+	case <-params.Context.Done():
 	}
 
-	log.Printf("Starting Replicated API on port %d...\n", 3000)
+	shutdown(srv)
 
-	log.Fatal(srv.ListenAndServe())
+	return nil
 }
@@ -0,0 +1,50 @@
+package apiserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate"
+	"github.com/replicatedhq/replicated-sdk/pkg/heartbeat"
+	"github.com/replicatedhq/replicated-sdk/pkg/report"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/replicatedhq/replicated-sdk/pkg/webhook"
+)
+
+const (
+	// shutdownTimeout must be lower than the pod's termination grace period (30 seconds by default)
+	shutdownTimeout = 25 * time.Second
+)
+
+// shutdown drains the http server, stops the background tasks and flushes the buffered custom app metrics and the pending instance reports.
+func shutdown(srv *http.Server) {
+	log.Println("Shutting down Replicated API...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("failed to gracefully shutdown http server: %v", err)
+	}
+
+	heartbeat.Stop()
+	webhook.Stop()
+
+	if appStateOperator := appstate.GetOperator(); appStateOperator != nil {
+		appStateOperator.Shutdown()
+	}
+
+	report.StopCustomAppMetricsBatch(ctx)
+	if secretStore, ok := store.GetStore().(*store.SecretStore); ok {
+		secretStore.CheckpointCustomAppMetricsHistory(ctx)
+	}
+	if err := report.FlushPendingReports(ctx); err != nil {
+		log.Printf("failed to flush pending reports: %v", err)
+	}
+	// the events that are still in the outbox are retried by the next leader
+	report.StopOutbox()
+
+	log.Println("Replicated API shutdown complete")
+}
@@ -0,0 +1,171 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apiservertypes "github.com/replicatedhq/replicated-sdk/pkg/apiserver/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+)
+
+const (
+	certReloadInterval = 10 * time.Second
+)
+
+// certReloader serves the certificate and client CA bundle from disk and reloads them when the files change.
+// Files mounted from a secret are updated in place by the kubelet when the secret changes (e.g. when cert-manager rotates the certificate).
+type certReloader struct {
+	config apiservertypes.TLSConfig
+
+	mtx       sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	checksum  [sha256.Size]byte
+}
+
+func newCertReloader(config apiservertypes.TLSConfig) (*certReloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("both tls cert file and key file must be specified")
+	}
+
+	c := &certReloader{config: config}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload reads the files and replaces the certificate and client CAs if they changed.
+// If the new files can't be loaded (e.g. the cert and key are read in the middle of a rotation), the current ones are kept.
+func (c *certReloader) reload() (bool, error) {
+	certPEM, err := os.ReadFile(c.config.CertFile)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read tls cert file")
+	}
+	keyPEM, err := os.ReadFile(c.config.KeyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read tls key file")
+	}
+	var caPEM []byte
+	if c.config.IsClientAuthEnabled() {
+		caPEM, err = os.ReadFile(c.config.ClientCAFile)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to read tls client ca file")
+		}
+	}
+
+	checksum := sha256.Sum256(bytes.Join([][]byte{certPEM, keyPEM, caPEM}, []byte{0}))
+
+	c.mtx.RLock()
+	unchanged := c.cert != nil && checksum == c.checksum
+	c.mtx.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load tls key pair")
+	}
+
+	var clientCAs *x509.CertPool
+	if c.config.IsClientAuthEnabled() {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return false, errors.New("no certificates found in tls client ca file")
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.checksum = checksum
+
+	return true, nil
+}
+
+func (c *certReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(certReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.reload()
+			if err != nil {
+				logger.Error(errors.Wrap(err, "failed to reload tls certificate"))
+				continue
+			}
+			if reloaded {
+				logger.Infof("Reloaded TLS certificate")
+			}
+		}
+	}
+}
+
+func (c *certReloader) getCertificate() *tls.Certificate {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.cert
+}
+
+func (c *certReloader) getClientCAs() *x509.CertPool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.clientCAs
+}
+
+// serverTLSConfig returns the tls config for the api server.
+// When client auth is enabled, client certificates are verified if presented, but not required in the handshake
+// so that kubelet probes can reach /healthz. The authenticated routes require a verified certificate instead.
+func (c *certReloader) serverTLSConfig() *tls.Config {
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return c.getCertificate(), nil
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+	}
+	if c.config.IsClientAuthEnabled() {
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: getCertificate,
+				ClientAuth:     tls.VerifyClientCertIfGiven,
+				ClientCAs:      c.getClientCAs(),
+			}, nil
+		}
+	}
+
+	return config
+}
+
+// leaderTLSConfig returns the tls config used to forward requests to the leader replica.
+// The leader is addressed by its pod ip, which is not included in the certificate, but all replicas serve the same certificate,
+// so the leader is verified by comparing the certificate it presents to our own.
+// No client certificate is presented, the serving certificate is usually not issued by the client CA (nor for client auth),
+// forwarded requests are authenticated with the replica token instead.
+func (c *certReloader) leaderTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true, // verified in VerifyPeerCertificate
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			cert := c.getCertificate()
+			if len(rawCerts) == 0 || len(cert.Certificate) == 0 || !bytes.Equal(rawCerts[0], cert.Certificate[0]) {
+				return errors.New("leader certificate does not match")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,228 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apiservertypes "github.com/replicatedhq/replicated-sdk/pkg/apiserver/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/handlers"
+	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	} else {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestCert(t *testing.T, config apiservertypes.TLSConfig, cert *testCert) {
+	require.NoError(t, os.WriteFile(config.CertFile, cert.certPEM, 0600))
+	require.NoError(t, os.WriteFile(config.KeyFile, cert.keyPEM, 0600))
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	req := require.New(t)
+
+	dir := t.TempDir()
+	config := apiservertypes.TLSConfig{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+
+	ca := newTestCert(t, "ca", nil)
+	first := newTestCert(t, "replicated", ca)
+	writeTestCert(t, config, first)
+
+	reloader, err := newCertReloader(config)
+	req.NoError(err)
+	req.Equal(first.cert.Raw, reloader.getCertificate().Certificate[0])
+
+	reloaded, err := reloader.reload()
+	req.NoError(err)
+	req.False(reloaded, "files have not changed")
+
+	// rotated certificate
+	second := newTestCert(t, "replicated", ca)
+	writeTestCert(t, config, second)
+
+	reloaded, err = reloader.reload()
+	req.NoError(err)
+	req.True(reloaded)
+	req.Equal(second.cert.Raw, reloader.getCertificate().Certificate[0])
+
+	// mismatched cert and key keep the current certificate
+	req.NoError(os.WriteFile(config.KeyFile, first.keyPEM, 0600))
+
+	_, err = reloader.reload()
+	req.Error(err)
+	req.Equal(second.cert.Raw, reloader.getCertificate().Certificate[0])
+}
+
+func TestCertReloader_ClientAuth(t *testing.T) {
+	req := require.New(t)
+
+	dir := t.TempDir()
+	config := apiservertypes.TLSConfig{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+
+	// the serving certificate is not issued by the client CA, as is usually the case
+	ca := newTestCert(t, "ca", nil)
+	servingCA := newTestCert(t, "serving-ca", nil)
+	writeTestCert(t, config, newTestCert(t, "replicated", servingCA))
+	req.NoError(os.WriteFile(config.ClientCAFile, ca.certPEM, 0600))
+
+	req.NoError(leader.InitReplicaToken(context.Background(), fake.NewSimpleClientset(
+		k8sutil.CreateTestDeployment(util.GetReplicatedDeploymentName(), "default", "1", map[string]string{"app": "replicated"}),
+	), "default"))
+
+	reloader, err := newCertReloader(config)
+	req.NoError(err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+
+	srv := &http.Server{
+		Handler: handlers.RequireClientCertMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})),
+		TLSConfig: reloader.serverTLSConfig(),
+	}
+	go srv.ServeTLS(listener, "", "")
+	defer srv.Close()
+
+	url := "https://" + listener.Addr().String()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(servingCA.cert)
+
+	clientCert := newTestCert(t, "client", ca)
+	clientKeyPair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	req.NoError(err)
+
+	untrustedCA := newTestCert(t, "untrusted-ca", nil)
+	untrustedCert := newTestCert(t, "client", untrustedCA)
+	untrustedKeyPair, err := tls.X509KeyPair(untrustedCert.certPEM, untrustedCert.keyPEM)
+	req.NoError(err)
+
+	tests := []struct {
+		name       string
+		tlsConfig  *tls.Config
+		header     http.Header
+		wantStatus int
+		wantErr    bool
+	}{
+		{
+			name:       "no client certificate",
+			tlsConfig:  &tls.Config{RootCAs: rootCAs},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "trusted client certificate",
+			tlsConfig:  &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{clientKeyPair}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "untrusted client certificate",
+			tlsConfig: &tls.Config{
+				RootCAs: rootCAs,
+				GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					return &untrustedKeyPair, nil
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "leader forwarding",
+			tlsConfig: reloader.leaderTLSConfig(),
+			header: http.Header{
+				"X-Replicated-Forwarded-From": {"replicated-pod-2"},
+				"X-Replicated-Replica-Token":  {leader.GetReplicaToken()},
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "forged forwarding",
+			tlsConfig: reloader.leaderTLSConfig(),
+			header: http.Header{
+				"X-Replicated-Forwarded-From": {"replicated-pod-2"},
+				"X-Replicated-Replica-Token":  {"forged"},
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tt.tlsConfig}}
+			r, err := http.NewRequest("GET", url, nil)
+			require.NoError(t, err)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			resp, err := client.Do(r)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
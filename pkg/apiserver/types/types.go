@@ -0,0 +1,19 @@
+package types
+
+type TLSConfig struct {
+	// CertFile and KeyFile enable TLS for the api. The files are reloaded when they change, e.g. when cert-manager rotates the certificate.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ClientCAFile enables client certificate verification (mTLS) using the CA bundle in the file
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// IsEnabled returns true if the api should be served over TLS
+func (c TLSConfig) IsEnabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// IsClientAuthEnabled returns true if clients must present a certificate signed by the client CA
+func (c TLSConfig) IsClientAuthEnabled() bool {
+	return c.ClientCAFile != ""
+}
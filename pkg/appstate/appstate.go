@@ -7,17 +7,24 @@ import (
 	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"k8s.io/apimachinery/pkg/api/meta"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
 
 type Monitor struct {
 	clientset       kubernetes.Interface
+	dynamicClient   dynamic.Interface
 	targetNamespace string
 	appInformersCh  chan appInformer
 	appStatusCh     chan types.AppStatus
 	cancel          context.CancelFunc
+	// done is closed when the monitor is shut down
+	done <-chan struct{}
 }
 
 type EventHandler interface {
@@ -27,19 +34,23 @@ type EventHandler interface {
 }
 
 type appInformer struct {
-	appSlug   string
-	sequence  int64
-	informers []types.StatusInformer
+	appSlug     string
+	sequence    int64
+	informers   []types.StatusInformer
+	aggregation *types.AggregationPolicy
+	damping     types.DampingPolicy
 }
 
-func NewMonitor(clientset kubernetes.Interface, targetNamespace string) *Monitor {
+func NewMonitor(clientset kubernetes.Interface, dynamicClient dynamic.Interface, targetNamespace string) *Monitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	m := &Monitor{
 		clientset:       clientset,
+		dynamicClient:   dynamicClient,
 		targetNamespace: targetNamespace,
 		appInformersCh:  make(chan appInformer),
 		appStatusCh:     make(chan types.AppStatus),
 		cancel:          cancel,
+		done:            ctx.Done(),
 	}
 	go m.run(ctx)
 	return m
@@ -49,15 +60,18 @@ func (m *Monitor) Shutdown() {
 	m.cancel()
 }
 
-func (m *Monitor) Apply(appSlug string, sequence int64, informers []types.StatusInformer) {
-	m.appInformersCh <- struct {
-		appSlug   string
-		sequence  int64
-		informers []types.StatusInformer
-	}{
-		appSlug:   appSlug,
-		sequence:  sequence,
-		informers: informers,
+// Apply applies the informers of the app, they are ignored if the monitor has been shut down.
+func (m *Monitor) Apply(appSlug string, sequence int64, informers []types.StatusInformer, aggregation *types.AggregationPolicy, damping types.DampingPolicy) {
+	select {
+	case m.appInformersCh <- appInformer{
+		appSlug:     appSlug,
+		sequence:    sequence,
+		informers:   informers,
+		aggregation: aggregation,
+		damping:     damping,
+	}:
+	case <-m.done:
+		log.Printf("ignoring informers for app %s, the monitor is shut down", appSlug)
 	}
 }
 
@@ -68,13 +82,15 @@ func (m *Monitor) AppStatusChan() <-chan types.AppStatus {
 func (m *Monitor) run(ctx context.Context) {
 	log.Println("Starting monitor loop")
 
-	defer close(m.appStatusCh)
-
+	// wait for the app monitors to stop forwarding before closing the channel
+	var forwarders sync.WaitGroup
 	appMonitors := make(map[string]*AppMonitor)
 	defer func() {
 		for _, appMonitor := range appMonitors {
 			appMonitor.Shutdown()
 		}
+		forwarders.Wait()
+		close(m.appStatusCh)
 	}()
 
 	for {
@@ -88,36 +104,40 @@ func (m *Monitor) run(ctx context.Context) {
 				if appMonitor != nil {
 					appMonitor.Shutdown()
 				}
-				appMonitor = NewAppMonitor(m.clientset, m.targetNamespace, appInformer.appSlug, appInformer.sequence)
+				appMonitor = NewAppMonitor(m.clientset, m.dynamicClient, m.targetNamespace, appInformer.appSlug, appInformer.sequence)
+				forwarders.Add(1)
 				go func() {
+					defer forwarders.Done()
 					for appStatus := range appMonitor.AppStatusChan() {
 						m.appStatusCh <- appStatus
 					}
 				}()
 				appMonitors[appInformer.appSlug] = appMonitor
 			}
-			appMonitor.Apply(appInformer.informers)
+			appMonitor.Apply(appInformer.informers, appInformer.aggregation, appInformer.damping)
 		}
 	}
 }
 
 type AppMonitor struct {
 	clientset       kubernetes.Interface
+	dynamicClient   dynamic.Interface
 	targetNamespace string
 	appSlug         string
-	informersCh     chan []types.StatusInformer
+	informersCh     chan appInformer
 	appStatusCh     chan types.AppStatus
 	cancel          context.CancelFunc
 	sequence        int64
 }
 
-func NewAppMonitor(clientset kubernetes.Interface, targetNamespace, appSlug string, sequence int64) *AppMonitor {
+func NewAppMonitor(clientset kubernetes.Interface, dynamicClient dynamic.Interface, targetNamespace, appSlug string, sequence int64) *AppMonitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	m := &AppMonitor{
 		appSlug:         appSlug,
 		clientset:       clientset,
+		dynamicClient:   dynamicClient,
 		targetNamespace: targetNamespace,
-		informersCh:     make(chan []types.StatusInformer),
+		informersCh:     make(chan appInformer),
 		appStatusCh:     make(chan types.AppStatus),
 		cancel:          cancel,
 		sequence:        sequence,
@@ -130,8 +150,14 @@ func (m *AppMonitor) Shutdown() {
 	m.cancel()
 }
 
-func (m *AppMonitor) Apply(informers []types.StatusInformer) {
-	m.informersCh <- informers
+func (m *AppMonitor) Apply(informers []types.StatusInformer, aggregation *types.AggregationPolicy, damping types.DampingPolicy) {
+	m.informersCh <- appInformer{
+		appSlug:     m.appSlug,
+		sequence:    m.sequence,
+		informers:   informers,
+		aggregation: aggregation,
+		damping:     damping,
+	}
 }
 
 func (m *AppMonitor) AppStatusChan() <-chan types.AppStatus {
@@ -142,12 +168,15 @@ func (m *AppMonitor) run(ctx context.Context) {
 	log.Println("Starting app monitor loop")
 
 	defer close(m.informersCh)
-	defer close(m.appStatusCh)
 
+	// wait for the informers to stop sending before closing the channel
+	var informersWg sync.WaitGroup
 	prevCancel := context.CancelFunc(func() {})
 	defer func() {
 		// wrap this in a function to cancel the variable when updated
 		prevCancel()
+		informersWg.Wait()
+		close(m.appStatusCh)
 	}()
 
 	for {
@@ -155,21 +184,28 @@ func (m *AppMonitor) run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 
-		case informers := <-m.informersCh:
+		case appInformer := <-m.informersCh:
 			prevCancel() // cancel previous loop
 
 			log.Println("App monitor got new informers")
 
 			ctx, cancel := context.WithCancel(ctx)
 			prevCancel = cancel
-			go m.runInformers(ctx, informers)
+			informersWg.Add(1)
+			go func() {
+				defer informersWg.Done()
+				m.runInformers(ctx, appInformer.informers, appInformer.aggregation, appInformer.damping)
+			}()
 		}
 	}
 }
 
-type runControllerFunc func(context.Context, kubernetes.Interface, string, []types.StatusInformer, chan<- types.ResourceState)
+type runControllerFunc func(context.Context, kubernetes.Interface, kubeinformers.SharedInformerFactory, string, []types.StatusInformer, chan<- types.ResourceState)
+
+// informerResyncPeriod is how often the states of all watched resources are recalculated from the informer caches
+const informerResyncPeriod = time.Minute
 
-func (m *AppMonitor) runInformers(ctx context.Context, informers []types.StatusInformer) {
+func (m *AppMonitor) runInformers(ctx context.Context, informers []types.StatusInformer, aggregation *types.AggregationPolicy, damping types.DampingPolicy) {
 	informers = normalizeStatusInformers(informers, m.targetNamespace)
 
 	log.Printf("Running informers: %#v", informers)
@@ -180,16 +216,14 @@ func (m *AppMonitor) runInformers(ctx context.Context, informers []types.StatusI
 		ResourceStates: resourceStates,
 		UpdatedAt:      time.Now(),
 		Sequence:       m.sequence,
-		State:          types.GetState(resourceStates),
 	}
-	m.appStatusCh <- appStatus // reset last app status
-
-	var shutdown sync.WaitGroup
-	resourceStateCh := make(chan types.ResourceState)
-	defer func() {
-		shutdown.Wait()
-		close(resourceStateCh)
-	}()
+	appStatus.State, appStatus.Aggregation = types.AggregateState(resourceStates, informers, aggregation)
+	// reset last app status
+	select {
+	case m.appStatusCh <- appStatus:
+	case <-ctx.Done():
+		return
+	}
 
 	// Collect namespace/kind pairs
 	namespaceKinds := make(map[string]map[string][]types.StatusInformer)
@@ -202,49 +236,161 @@ func (m *AppMonitor) runInformers(ctx context.Context, informers []types.StatusI
 		namespaceKinds[informer.Namespace] = kindsInNs
 	}
 
+	// the controllers of a namespace share a single informer per resource, and look up the pods, services
+	// and endpoints that the states depend on from the informer caches instead of the api server
+	factories := map[string]kubeinformers.SharedInformerFactory{}
+	dynamicFactories := map[string]dynamicinformer.DynamicSharedInformerFactory{}
+	for namespace := range namespaceKinds {
+		factories[namespace] = kubeinformers.NewSharedInformerFactoryWithOptions(m.clientset, informerResyncPeriod, kubeinformers.WithNamespace(namespace))
+		if m.dynamicClient != nil {
+			dynamicFactories[namespace] = dynamicinformer.NewFilteredDynamicSharedInformerFactory(m.dynamicClient, informerResyncPeriod, namespace, nil)
+		}
+	}
+
+	var shutdown sync.WaitGroup
+	resourceStateCh := make(chan types.ResourceState)
+	defer func() {
+		// drain the channel so that controllers and informers blocked on sending can exit
+		go func() {
+			for range resourceStateCh {
+			}
+		}()
+		shutdown.Wait()
+		for _, factory := range factories {
+			factory.Shutdown()
+		}
+		for _, factory := range dynamicFactories {
+			factory.Shutdown()
+		}
+		close(resourceStateCh)
+	}()
+
 	goRun := func(fn runControllerFunc, namespace string, informers []types.StatusInformer) {
 		shutdown.Add(1)
 		go func() {
-			fn(ctx, m.clientset, namespace, informers, resourceStateCh)
+			fn(ctx, m.clientset, factories[namespace], namespace, informers, resourceStateCh)
 			shutdown.Done()
 		}()
 	}
 
 	kindImpls := map[string]runControllerFunc{
+		CronJobResourceKind:               runCronJobController,
 		DaemonSetResourceKind:             runDaemonSetController,
 		DeploymentResourceKind:            runDeploymentController,
+		HTTPCheckResourceKind:             runHTTPCheckController,
 		IngressResourceKind:               runIngressController,
+		JobResourceKind:                   runJobController,
 		PersistentVolumeClaimResourceKind: runPersistentVolumeClaimController,
+		SecretResourceKind:                runSecretController,
 		ServiceResourceKind:               runServiceController,
 		StatefulSetResourceKind:           runStatefulSetController,
+		TCPCheckResourceKind:              runTCPCheckController,
 	}
 	for namespace, kinds := range namespaceKinds {
 		for kind, informers := range kinds {
+			// informers that set an api version are watched with the dynamic client, so built-in kinds can use state rules too
+			if dynamicInformers := filterDynamicStatusInformers(informers); len(dynamicInformers) > 0 {
+				shutdown.Add(1)
+				go func(namespace string, informers []types.StatusInformer) {
+					defer shutdown.Done()
+					runDynamicController(ctx, m.clientset, dynamicFactories[namespace], namespace, informers, resourceStateCh)
+				}(namespace, dynamicInformers)
+			}
+
+			builtinInformers := filterBuiltinStatusInformers(informers)
+			if len(builtinInformers) == 0 {
+				continue
+			}
 			if impl, ok := kindImpls[kind]; ok {
-				goRun(impl, namespace, informers)
+				goRun(impl, namespace, builtinInformers)
+			} else if isGatewayAPIResourceKind(kind) {
+				shutdown.Add(1)
+				go func(namespace string, kind string, informers []types.StatusInformer) {
+					defer shutdown.Done()
+					runGatewayAPIController(ctx, m.clientset, factories[namespace], dynamicFactories[namespace], namespace, kind, informers, resourceStateCh)
+				}(namespace, kind, builtinInformers)
 			} else {
-				log.Printf("Informer requested for unsupported resource kind %v", kind)
+				log.Printf("Informer requested for unsupported resource kind %v, set an apiVersion to watch it with the dynamic client", kind)
 			}
 		}
 	}
 
+	damper := newStateDamper(damping)
+	expireCh := make(chan struct{})
+	scheduleExpire := func(after time.Duration) {
+		time.AfterFunc(after, func() {
+			select {
+			case expireCh <- struct{}{}:
+			case <-ctx.Done():
+			}
+		})
+	}
+
 	for {
+		var nextResourceStates []types.ResourceState
 		select {
 		case <-ctx.Done():
 			return
 		case resourceState := <-resourceStateCh:
-			appStatus.ResourceStates = resourceStatesApplyNew(appStatus.ResourceStates, resourceState)
-			appStatus.State = types.GetState(appStatus.ResourceStates)
-			appStatus.UpdatedAt = time.Now() // TODO: this should come from the informer
-			m.appStatusCh <- appStatus
+			reportedState, ok := resourceStatesGetState(appStatus.ResourceStates, resourceState)
+			if resourceState.State == types.StateMissing && !ok {
+				// a resource that does not match a label selector (anymore) and is not tracked
+				continue
+			}
+			if report, after := damper.observe(reportedState, resourceState); !report {
+				scheduleExpire(after)
+				continue
+			}
+			nextResourceStates = append(nextResourceStates, resourceState)
+		case <-expireCh:
+			nextResourceStates = damper.expire()
+			if len(nextResourceStates) == 0 {
+				continue
+			}
+		}
+
+		for _, resourceState := range nextResourceStates {
+			appStatus.ResourceStates = resourceStatesApplyNew(appStatus.ResourceStates, resourceState, informers)
+		}
+		appStatus.State, appStatus.Aggregation = types.AggregateState(appStatus.ResourceStates, informers, aggregation)
+		appStatus.UpdatedAt = time.Now() // TODO: this should come from the informer
+		select {
+		case m.appStatusCh <- appStatus:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-func runInformer(ctx context.Context, informer cache.SharedInformer, eventHandler EventHandler) {
+// informerFactory starts the informers that were requested from it.
+type informerFactory interface {
+	Start(stopCh <-chan struct{})
+}
+
+// informerDependency is an informer of resources that the state of the watched resources depends on, such as the endpoints of a service.
+// If onChange is set, it is called with the namespace and name of each resource that changes after the initial sync.
+type informerDependency struct {
+	informer cache.SharedInformer
+	onChange func(namespace string, name string)
+}
+
+// runInformer starts the informers of the factory, waits for the informer and its dependencies to sync,
+// and then sends the events of the informer to the event handler until the context is done.
+func runInformer(ctx context.Context, factory informerFactory, informer cache.SharedInformer, eventHandler EventHandler, dependencies ...informerDependency) {
 	defer utilruntime.HandleCrash()
 
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	factory.Start(ctx.Done())
+
+	synced := []cache.InformerSynced{informer.HasSynced}
+	for _, dependency := range dependencies {
+		synced = append(synced, dependency.informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return
+	}
+
+	// the handler receives an add event for each resource in the cache
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			eventHandler.ObjectCreated(obj)
 		},
@@ -255,6 +401,54 @@ func runInformer(ctx context.Context, informer cache.SharedInformer, eventHandle
 			eventHandler.ObjectDeleted(obj)
 		},
 	})
+	if err != nil {
+		log.Printf("Failed to add event handler: %v", err)
+		return
+	}
 
-	informer.Run(ctx.Done())
+	for _, dependency := range dependencies {
+		if dependency.onChange == nil {
+			continue
+		}
+		if err := addDependencyEventHandler(dependency.informer, dependency.onChange); err != nil {
+			log.Printf("Failed to add dependency event handler: %v", err)
+			return
+		}
+	}
+
+	<-ctx.Done()
+}
+
+func addDependencyEventHandler(informer cache.SharedInformer, onChange func(namespace string, name string)) error {
+	changed := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return
+		}
+		onChange(namespace, name)
+	}
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// the watched resources were just calculated from the synced caches
+			if !isInInitialList {
+				changed(obj)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			// skip resyncs, the watched resources are resynced themselves
+			if oldMeta, err := meta.Accessor(old); err == nil {
+				if newMeta, err := meta.Accessor(new); err == nil && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+					return
+				}
+			}
+			changed(new)
+		},
+		DeleteFunc: changed,
+	})
+	return err
 }
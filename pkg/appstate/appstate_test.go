@@ -0,0 +1,116 @@
+package appstate
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMonitorShutdown(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+		},
+	})
+
+	m := NewMonitor(clientset, nil, "default")
+	m.Apply("app-slug", 1, []types.StatusInformer{
+		{Kind: "deployment", Name: "test-deployment", Namespace: "default"},
+	}, nil, nil)
+
+	// wait for the informers to report the deployment
+	timeout := time.After(10 * time.Second)
+	for reported := false; !reported; {
+		select {
+		case appStatus := <-m.AppStatusChan():
+			for _, resourceState := range appStatus.ResourceStates {
+				if resourceState.Name == "test-deployment" && resourceState.State != types.StateMissing {
+					reported = true
+				}
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for app status")
+		}
+	}
+
+	m.Shutdown()
+
+	// the app status channel must be closed once all monitors have stopped
+	for {
+		select {
+		case _, ok := <-m.AppStatusChan():
+			if !ok {
+				return
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for monitor to shutdown")
+		}
+	}
+}
+
+func TestMonitorSelectorStatusInformer(t *testing.T) {
+	labels := map[string]string{"app.kubernetes.io/part-of": "myapp"}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a", Namespace: "default", Labels: labels}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b", Namespace: "default", Labels: labels}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}},
+	)
+
+	informer, err := types.StatusInformerString("deployment/selector:app.kubernetes.io/part-of=myapp").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMonitor(clientset, nil, "default")
+	defer m.Shutdown()
+	m.Apply("app-slug", 1, []types.StatusInformer{informer}, nil, nil)
+
+	waitForResources := func(want ...string) {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case appStatus := <-m.AppStatusChan():
+				names := []string{}
+				for _, resourceState := range appStatus.ResourceStates {
+					names = append(names, resourceState.Name)
+				}
+				sort.Strings(names)
+				if reflect.DeepEqual(names, want) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for resources %v", want)
+			}
+		}
+	}
+
+	waitForResources("tenant-a", "tenant-b")
+
+	// a new match appears
+	_, err = clientset.AppsV1().Deployments("default").Create(context.TODO(), &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "tenant-c", Namespace: "default", Labels: labels}}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForResources("tenant-a", "tenant-b", "tenant-c")
+
+	// a match is deleted
+	if err := clientset.AppsV1().Deployments("default").Delete(context.TODO(), "tenant-a", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForResources("tenant-b", "tenant-c")
+
+	// a match no longer has the labels
+	_, err = clientset.AppsV1().Deployments("default").Update(context.TODO(), &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b", Namespace: "default"}}, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForResources("tenant-c")
+}
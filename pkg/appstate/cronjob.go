@@ -0,0 +1,111 @@
+package appstate
+
+import (
+	"context"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	batchv1 "k8s.io/api/batch/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	CronJobResourceKind = "cronjob"
+)
+
+func init() {
+	registerResourceKindNames(CronJobResourceKind, "cronjobs", "cj")
+}
+
+func runCronJobController(
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	informer := factory.Batch().V1().CronJobs().Informer()
+
+	eventHandler := NewCronJobEventHandler(
+		filterStatusInformersByResourceKind(informers, CronJobResourceKind),
+		resourceStateCh,
+	)
+
+	runInformer(ctx, factory, informer, eventHandler)
+	return
+}
+
+type cronJobEventHandler struct {
+	informers       []types.StatusInformer
+	resourceStateCh chan<- types.ResourceState
+}
+
+func NewCronJobEventHandler(informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *cronJobEventHandler {
+	return &cronJobEventHandler{
+		informers:       informers,
+		resourceStateCh: resourceStateCh,
+	}
+}
+
+func (h *cronJobEventHandler) ObjectCreated(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- makeCronJobResourceState(r, CalculateCronJobState(r))
+}
+
+func (h *cronJobEventHandler) ObjectUpdated(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeCronJobResourceState(r, types.StateMissing)
+		}
+		return
+	}
+	h.resourceStateCh <- makeCronJobResourceState(r, CalculateCronJobState(r))
+}
+
+func (h *cronJobEventHandler) ObjectDeleted(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- makeCronJobResourceState(r, types.StateMissing)
+}
+
+func (h *cronJobEventHandler) cast(obj interface{}) *batchv1.CronJob {
+	r, _ := obj.(*batchv1.CronJob)
+	return r
+}
+
+func (h *cronJobEventHandler) getInformer(r *batchv1.CronJob) (types.StatusInformer, bool) {
+	if r != nil {
+		for _, informer := range h.informers {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
+				return informer, true
+			}
+		}
+	}
+	return types.StatusInformer{}, false
+}
+
+func makeCronJobResourceState(r *batchv1.CronJob, state types.State) types.ResourceState {
+	return types.ResourceState{
+		Kind:      CronJobResourceKind,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Labels:    r.Labels,
+		State:     state,
+	}
+}
+
+// CalculateCronJobState returns degraded if the last scheduled run did not succeed, and ready otherwise.
+func CalculateCronJobState(r *batchv1.CronJob) types.State {
+	if r.Status.LastScheduleTime == nil || len(r.Status.Active) > 0 {
+		// never scheduled, or the last scheduled run is still running
+		return types.StateReady
+	}
+	if r.Status.LastSuccessfulTime == nil || r.Status.LastSuccessfulTime.Before(r.Status.LastScheduleTime) {
+		return types.StateDegraded
+	}
+	return types.StateReady
+}
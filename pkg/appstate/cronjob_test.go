@@ -0,0 +1,68 @@
+package appstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCalculateCronJobState(t *testing.T) {
+	scheduled := metav1.NewTime(time.Now().Add(-time.Hour))
+	succeeded := metav1.NewTime(scheduled.Add(time.Minute))
+	previousSuccess := metav1.NewTime(scheduled.Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		status batchv1.CronJobStatus
+		want   types.State
+	}{
+		{
+			name: "never scheduled",
+			want: types.StateReady,
+		},
+		{
+			name: "last run succeeded",
+			status: batchv1.CronJobStatus{
+				LastScheduleTime:   &scheduled,
+				LastSuccessfulTime: &succeeded,
+			},
+			want: types.StateReady,
+		},
+		{
+			name: "last run is running",
+			status: batchv1.CronJobStatus{
+				Active:             []corev1.ObjectReference{{Name: "backup-123"}},
+				LastScheduleTime:   &scheduled,
+				LastSuccessfulTime: &previousSuccess,
+			},
+			want: types.StateReady,
+		},
+		{
+			name: "last run failed",
+			status: batchv1.CronJobStatus{
+				LastScheduleTime:   &scheduled,
+				LastSuccessfulTime: &previousSuccess,
+			},
+			want: types.StateDegraded,
+		},
+		{
+			name: "never succeeded",
+			status: batchv1.CronJobStatus{
+				LastScheduleTime: &scheduled,
+			},
+			want: types.StateDegraded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cronJob := &batchv1.CronJob{Status: tt.status}
+			if got := CalculateCronJobState(cronJob); got != tt.want {
+				t.Errorf("CalculateCronJobState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,16 +3,14 @@ package appstate
 import (
 	"context"
 	"log"
-	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 )
 
 const (
@@ -24,36 +22,33 @@ const (
 type daemonSetEventHandler struct {
 	informers       []types.StatusInformer
 	resourceStateCh chan<- types.ResourceState
-	clientset       kubernetes.Interface
-	targetNamespace string
+	daemonSetLister appslisters.DaemonSetLister
+	podLister       corelisters.PodLister
 }
 
 func init() {
 	registerResourceKindNames(DaemonSetResourceKind, "daemonsets", "ds")
 }
 
-func runDaemonSetController(ctx context.Context, clientset kubernetes.Interface,
+func runDaemonSetController(ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory,
 	targetNamespace string, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
 ) {
-	listwatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.AppsV1().DaemonSets(targetNamespace).List(context.TODO(), options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.AppsV1().DaemonSets(targetNamespace).Watch(context.TODO(), options)
-		},
-	}
+	daemonSets := factory.Apps().V1().DaemonSets()
 
-	informer := cache.NewSharedInformer(listwatch, &appsv1.DaemonSet{}, time.Minute)
+	pods := factory.Core().V1().Pods()
 
 	eventHandler := &daemonSetEventHandler{
 		informers:       filterStatusInformersByResourceKind(informers, DaemonSetResourceKind),
 		resourceStateCh: resourceStateCh,
-		clientset:       clientset,
-		targetNamespace: targetNamespace,
+		daemonSetLister: daemonSets.Lister(),
+		podLister:       pods.Lister(),
 	}
 
-	runInformer(ctx, informer, eventHandler)
+	// the state, reason and message of daemonsets are derived from their pods, so they are recalculated when their pods change
+	runInformer(ctx, factory, daemonSets.Informer(), eventHandler, informerDependency{
+		informer: pods.Informer(),
+		onChange: eventHandler.podChanged,
+	})
 }
 
 func (h *daemonSetEventHandler) ObjectCreated(obj interface{}) {
@@ -62,7 +57,7 @@ func (h *daemonSetEventHandler) ObjectCreated(obj interface{}) {
 		return
 	}
 
-	h.resourceStateCh <- makeDaemonSetResourceState(r, h.calculateDaemonSetState(h.clientset, h.targetNamespace, r))
+	h.resourceStateCh <- addPodDiagnostics(h.podLister, makeDaemonSetResourceState(r, h.calculateDaemonSetState(r)), r.Spec.Selector)
 }
 
 func (h *daemonSetEventHandler) ObjectDeleted(obj interface{}) {
@@ -77,16 +72,20 @@ func (h *daemonSetEventHandler) ObjectDeleted(obj interface{}) {
 func (h *daemonSetEventHandler) ObjectUpdated(obj interface{}) {
 	r := h.cast(obj)
 	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeDaemonSetResourceState(r, types.StateMissing)
+		}
 		return
 	}
 
-	h.resourceStateCh <- makeDaemonSetResourceState(r, h.calculateDaemonSetState(h.clientset, h.targetNamespace, r))
+	h.resourceStateCh <- addPodDiagnostics(h.podLister, makeDaemonSetResourceState(r, h.calculateDaemonSetState(r)), r.Spec.Selector)
 }
 
 func (h *daemonSetEventHandler) getInformer(r *appsv1.DaemonSet) (types.StatusInformer, bool) {
 	if r != nil {
 		for _, informer := range h.informers {
-			if r.Namespace == informer.Namespace && r.Name == informer.Name {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
 				return informer, true
 			}
 		}
@@ -95,6 +94,20 @@ func (h *daemonSetEventHandler) getInformer(r *appsv1.DaemonSet) (types.StatusIn
 	return types.StatusInformer{}, false
 }
 
+// podChanged recalculates the states of the daemonsets that select the pod.
+func (h *daemonSetEventHandler) podChanged(namespace string, name string) {
+	pod, _ := h.podLister.Pods(namespace).Get(name)
+	daemonSets, err := h.daemonSetLister.DaemonSets(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, r := range daemonSets {
+		if _, ok := h.getInformer(r); ok && selectsPod(r.Spec.Selector, pod) {
+			h.ObjectUpdated(r)
+		}
+	}
+}
+
 func (h *daemonSetEventHandler) cast(obj interface{}) *appsv1.DaemonSet {
 	r, _ := obj.(*appsv1.DaemonSet)
 	return r
@@ -103,7 +116,7 @@ func (h *daemonSetEventHandler) cast(obj interface{}) *appsv1.DaemonSet {
 // The pods in a daemonset can be identified by the match label set in the daemonset and the
 // "controller-revision-hash" can be used to determine if they are all the in the same daemonset
 // version.
-func (h *daemonSetEventHandler) calculateDaemonSetState(clientset kubernetes.Interface, targetNamespace string, r *appsv1.DaemonSet) types.State {
+func (h *daemonSetEventHandler) calculateDaemonSetState(r *appsv1.DaemonSet) types.State {
 	if r == nil {
 		return types.StateUnavailable
 	}
@@ -112,9 +125,7 @@ func (h *daemonSetEventHandler) calculateDaemonSetState(clientset kubernetes.Int
 		return types.StateUpdating
 	}
 
-	listOptions := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(r.Spec.Selector.MatchLabels).String()}
-
-	pods, err := clientset.CoreV1().Pods(targetNamespace).List(context.TODO(), listOptions)
+	pods, err := h.podLister.Pods(r.Namespace).List(labels.SelectorFromSet(r.Spec.Selector.MatchLabels))
 	if err != nil {
 		log.Printf("failed to get daemonset pod list: %s", err)
 		return types.StateUnavailable
@@ -122,7 +133,7 @@ func (h *daemonSetEventHandler) calculateDaemonSetState(clientset kubernetes.Int
 
 	// If the pod version labels are not all the same, then the daemonset is updating.
 	currentVersion := ""
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		validOwner := false
 		for _, owner := range pod.ObjectMeta.OwnerReferences {
 			if owner.Kind == DaemonSetOwnerKind && owner.Name == r.ObjectMeta.Name {
@@ -173,10 +184,14 @@ func (h *daemonSetEventHandler) calculateDaemonSetState(clientset kubernetes.Int
 }
 
 func makeDaemonSetResourceState(r *appsv1.DaemonSet, state types.State) types.ResourceState {
+	readyReplicas, desiredReplicas := r.Status.NumberReady, r.Status.DesiredNumberScheduled
 	return types.ResourceState{
-		Kind:      DaemonSetResourceKind,
-		Name:      r.Name,
-		Namespace: r.Namespace,
-		State:     state,
+		Kind:            DaemonSetResourceKind,
+		Name:            r.Name,
+		Namespace:       r.Namespace,
+		Labels:          r.Labels,
+		State:           state,
+		ReadyReplicas:   &readyReplicas,
+		DesiredReplicas: &desiredReplicas,
 	}
 }
@@ -0,0 +1,78 @@
+package appstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/metrics"
+)
+
+// stateDamper holds back resource state changes until the resource has been in the new state for the duration
+// of the damping policy, so that short flaps, such as a pod restarting, do not change the app state.
+type stateDamper struct {
+	durations map[types.State]time.Duration
+	pending   map[string]pendingResourceState
+	now       func() time.Time
+}
+
+type pendingResourceState struct {
+	resourceState types.ResourceState
+	since         time.Time
+}
+
+func newStateDamper(policy types.DampingPolicy) *stateDamper {
+	return &stateDamper{
+		durations: policy.Durations(),
+		pending:   map[string]pendingResourceState{},
+		now:       time.Now,
+	}
+}
+
+// observe records a new state of a resource that is currently reported in the reported state. It returns true if the state
+// should be reported now. Otherwise the state is pending, and expire should be called after the returned duration.
+// Changes from the missing state are never damped, so that resources are reported as soon as they are first seen.
+func (d *stateDamper) observe(reportedState types.State, resourceState types.ResourceState) (bool, time.Duration) {
+	key := resourceStateKey(resourceState)
+	pending, isPending := d.pending[key]
+
+	if resourceState.State == reportedState {
+		if isPending {
+			// the resource returned to the reported state before the change was reported
+			delete(d.pending, key)
+			metrics.IncSuppressedStateFlaps(resourceState.Kind, pending.resourceState.State)
+		}
+		return true, 0
+	}
+
+	duration := d.durations[resourceState.State]
+	if duration == 0 || reportedState == types.StateMissing || reportedState == "" {
+		delete(d.pending, key)
+		return true, 0
+	}
+
+	if !isPending || pending.resourceState.State != resourceState.State {
+		pending.since = d.now()
+	}
+	pending.resourceState = resourceState
+	d.pending[key] = pending
+
+	return false, pending.since.Add(duration).Sub(d.now())
+}
+
+// expire returns the pending states that have lasted for the duration of the policy, and stops tracking them.
+func (d *stateDamper) expire() []types.ResourceState {
+	now := d.now()
+	var expired []types.ResourceState
+	for key, pending := range d.pending {
+		if !now.Before(pending.since.Add(d.durations[pending.resourceState.State])) {
+			expired = append(expired, pending.resourceState)
+			delete(d.pending, key)
+		}
+	}
+	return expired
+}
+
+func resourceStateKey(resourceState types.ResourceState) string {
+	return fmt.Sprintf("%s/%s/%s", resourceState.Namespace, resourceState.Kind, resourceState.Name)
+}
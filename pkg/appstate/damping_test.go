@@ -0,0 +1,57 @@
+package appstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_stateDamper(t *testing.T) {
+	req := require.New(t)
+
+	now := time.Now()
+	d := newStateDamper(types.DampingPolicy{types.StateDegraded: "30s", types.StateReady: "10s"})
+	d.now = func() time.Time { return now }
+
+	web := func(state types.State) types.ResourceState {
+		return types.ResourceState{Kind: "deployment", Name: "web", Namespace: "default", State: state}
+	}
+
+	// the first state is reported immediately
+	report, _ := d.observe(types.StateMissing, web(types.StateReady))
+	req.True(report)
+
+	// states without a duration are reported immediately
+	report, _ = d.observe(types.StateReady, web(types.StateUnavailable))
+	req.True(report)
+
+	// a short flap is suppressed
+	report, after := d.observe(types.StateReady, web(types.StateDegraded))
+	req.False(report)
+	req.Equal(30*time.Second, after)
+	now = now.Add(5 * time.Second)
+	report, _ = d.observe(types.StateReady, web(types.StateReady))
+	req.True(report)
+	now = now.Add(30 * time.Second)
+	req.Empty(d.expire())
+
+	// a lasting change is reported once it has lasted for the duration
+	report, _ = d.observe(types.StateReady, web(types.StateDegraded))
+	req.False(report)
+	now = now.Add(20 * time.Second)
+	report, after = d.observe(types.StateReady, web(types.StateDegraded))
+	req.False(report)
+	req.Equal(10*time.Second, after)
+	req.Empty(d.expire())
+	now = now.Add(10 * time.Second)
+	req.Equal([]types.ResourceState{web(types.StateDegraded)}, d.expire())
+
+	// recovery is damped as well
+	report, after = d.observe(types.StateDegraded, web(types.StateReady))
+	req.False(report)
+	req.Equal(10*time.Second, after)
+	now = now.Add(10 * time.Second)
+	req.Equal([]types.ResourceState{web(types.StateReady)}, d.expire())
+}
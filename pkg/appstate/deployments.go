@@ -2,15 +2,14 @@ package appstate
 
 import (
 	"context"
-	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/labels"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 )
 
 const (
@@ -22,41 +21,41 @@ func init() {
 }
 
 func runDeploymentController(
-	ctx context.Context, clientset kubernetes.Interface, targetNamespace string,
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
 	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
 ) {
-	listwatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.AppsV1().Deployments(targetNamespace).List(context.TODO(), options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.AppsV1().Deployments(targetNamespace).Watch(context.TODO(), options)
-		},
-	}
-	informer := cache.NewSharedInformer(
-		listwatch,
-		&appsv1.Deployment{},
-		time.Minute,
-	)
+	deployments := factory.Apps().V1().Deployments()
+
+	pods := factory.Core().V1().Pods()
 
 	eventHandler := NewDeploymentEventHandler(
+		deployments.Lister(),
+		pods.Lister(),
 		filterStatusInformersByResourceKind(informers, DeploymentResourceKind),
 		resourceStateCh,
 	)
 
-	runInformer(ctx, informer, eventHandler)
+	// the reason and message of deployments are derived from their pods, so they are recalculated when their pods change
+	runInformer(ctx, factory, deployments.Informer(), eventHandler, informerDependency{
+		informer: pods.Informer(),
+		onChange: eventHandler.podChanged,
+	})
 	return
 }
 
 type deploymentEventHandler struct {
-	informers       []types.StatusInformer
-	resourceStateCh chan<- types.ResourceState
+	deploymentLister appslisters.DeploymentLister
+	podLister        corelisters.PodLister
+	informers        []types.StatusInformer
+	resourceStateCh  chan<- types.ResourceState
 }
 
-func NewDeploymentEventHandler(informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *deploymentEventHandler {
+func NewDeploymentEventHandler(deploymentLister appslisters.DeploymentLister, podLister corelisters.PodLister, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *deploymentEventHandler {
 	return &deploymentEventHandler{
-		informers:       informers,
-		resourceStateCh: resourceStateCh,
+		deploymentLister: deploymentLister,
+		podLister:        podLister,
+		informers:        informers,
+		resourceStateCh:  resourceStateCh,
 	}
 }
 
@@ -65,15 +64,19 @@ func (h *deploymentEventHandler) ObjectCreated(obj interface{}) {
 	if _, ok := h.getInformer(r); !ok {
 		return
 	}
-	h.resourceStateCh <- makeDeploymentResourceState(r, h.calculateDeploymentState(r))
+	h.resourceStateCh <- addPodDiagnostics(h.podLister, makeDeploymentResourceState(r, h.calculateDeploymentState(r)), r.Spec.Selector)
 }
 
 func (h *deploymentEventHandler) ObjectUpdated(obj interface{}) {
 	r := h.cast(obj)
 	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeDeploymentResourceState(r, types.StateMissing)
+		}
 		return
 	}
-	h.resourceStateCh <- makeDeploymentResourceState(r, h.calculateDeploymentState(r))
+	h.resourceStateCh <- addPodDiagnostics(h.podLister, makeDeploymentResourceState(r, h.calculateDeploymentState(r)), r.Spec.Selector)
 }
 
 func (h *deploymentEventHandler) ObjectDeleted(obj interface{}) {
@@ -84,6 +87,20 @@ func (h *deploymentEventHandler) ObjectDeleted(obj interface{}) {
 	h.resourceStateCh <- makeDeploymentResourceState(r, types.StateMissing)
 }
 
+// podChanged recalculates the states of the deployments that select the pod.
+func (h *deploymentEventHandler) podChanged(namespace string, name string) {
+	pod, _ := h.podLister.Pods(namespace).Get(name)
+	deployments, err := h.deploymentLister.Deployments(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, r := range deployments {
+		if _, ok := h.getInformer(r); ok && selectsPod(r.Spec.Selector, pod) {
+			h.ObjectUpdated(r)
+		}
+	}
+}
+
 func (h *deploymentEventHandler) cast(obj interface{}) *appsv1.Deployment {
 	r, _ := obj.(*appsv1.Deployment)
 	return r
@@ -92,7 +109,7 @@ func (h *deploymentEventHandler) cast(obj interface{}) *appsv1.Deployment {
 func (h *deploymentEventHandler) getInformer(r *appsv1.Deployment) (types.StatusInformer, bool) {
 	if r != nil {
 		for _, informer := range h.informers {
-			if r.Namespace == informer.Namespace && r.Name == informer.Name {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
 				return informer, true
 			}
 		}
@@ -101,11 +118,19 @@ func (h *deploymentEventHandler) getInformer(r *appsv1.Deployment) (types.Status
 }
 
 func makeDeploymentResourceState(r *appsv1.Deployment, state types.State) types.ResourceState {
+	readyReplicas := r.Status.ReadyReplicas
+	desiredReplicas := int32(1)
+	if r.Spec.Replicas != nil {
+		desiredReplicas = *r.Spec.Replicas
+	}
 	return types.ResourceState{
-		Kind:      DeploymentResourceKind,
-		Name:      r.Name,
-		Namespace: r.Namespace,
-		State:     state,
+		Kind:            DeploymentResourceKind,
+		Name:            r.Name,
+		Namespace:       r.Namespace,
+		Labels:          r.Labels,
+		State:           state,
+		ReadyReplicas:   &readyReplicas,
+		DesiredReplicas: &desiredReplicas,
 	}
 }
 
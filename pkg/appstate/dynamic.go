@@ -0,0 +1,234 @@
+package appstate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+var (
+	// defaultStateRules are used when an informer doesn't specify any rules.
+	// Resources with a Ready condition follow that condition, other resources are ready when they exist.
+	defaultStateRules = []types.StateRule{
+		{Condition: &types.ConditionMatch{Type: "Ready", Status: "True"}, State: types.StateReady},
+		{Condition: &types.ConditionMatch{Type: "Ready", Status: "False"}, State: types.StateUnavailable},
+		{Condition: &types.ConditionMatch{Type: "Ready", Status: "Unknown"}, State: types.StateUpdating},
+	}
+	defaultState = types.StateReady
+)
+
+// runDynamicController watches resources of any kind with the dynamic client. The informers are for a single kind in a single namespace,
+// but may use different api versions.
+func runDynamicController(
+	ctx context.Context, clientset kubernetes.Interface, factory dynamicinformer.DynamicSharedInformerFactory, targetNamespace string,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	if factory == nil {
+		log.Printf("Dynamic client is not configured, not watching %d informers", len(informers))
+		return
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+
+	informersByAPIVersion := map[string][]types.StatusInformer{}
+	for _, informer := range informers {
+		informersByAPIVersion[informer.APIVersion] = append(informersByAPIVersion[informer.APIVersion], informer)
+	}
+
+	done := make(chan struct{})
+	running := 0
+	for apiVersion, informers := range informersByAPIVersion {
+		gvr, err := getGroupVersionResource(mapper, apiVersion, informers[0].Kind)
+		if err != nil {
+			log.Printf("Failed to get resource for informer kind %s in api version %s: %v", informers[0].Kind, apiVersion, err)
+			continue
+		}
+
+		running++
+		go func(gvr schema.GroupVersionResource, informers []types.StatusInformer) {
+			defer func() { done <- struct{}{} }()
+			runDynamicResourceController(ctx, factory, gvr, informers, resourceStateCh)
+		}(gvr, informers)
+	}
+
+	for ; running > 0; running-- {
+		<-done
+	}
+}
+
+func getGroupVersionResource(mapper *restmapper.DeferredDiscoveryRESTMapper, apiVersion string, kind string) (schema.GroupVersionResource, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionResource{}, errors.Wrap(err, "failed to parse api version")
+	}
+
+	// the kind in the informer is usually lowercase, so it is resolved as a resource name, which is case insensitive
+	gvr, err := mapper.ResourceFor(gv.WithResource(strings.ToLower(kind)))
+	if err != nil {
+		return schema.GroupVersionResource{}, errors.Wrap(err, "failed to map kind to resource")
+	}
+	return gvr, nil
+}
+
+func runDynamicResourceController(
+	ctx context.Context, factory dynamicinformer.DynamicSharedInformerFactory, gvr schema.GroupVersionResource,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	informer := factory.ForResource(gvr).Informer()
+
+	eventHandler := NewDynamicEventHandler(
+		informers,
+		resourceStateCh,
+	)
+
+	runInformer(ctx, factory, informer, eventHandler)
+	return
+}
+
+type dynamicEventHandler struct {
+	informers       []types.StatusInformer
+	resourceStateCh chan<- types.ResourceState
+}
+
+func NewDynamicEventHandler(informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *dynamicEventHandler {
+	return &dynamicEventHandler{
+		informers:       informers,
+		resourceStateCh: resourceStateCh,
+	}
+}
+
+func (h *dynamicEventHandler) ObjectCreated(obj interface{}) {
+	r := h.cast(obj)
+	informer, ok := h.getInformer(r)
+	if !ok {
+		return
+	}
+	h.resourceStateCh <- makeDynamicResourceState(informer, r, calculateDynamicState(r, informer))
+}
+
+func (h *dynamicEventHandler) ObjectUpdated(obj interface{}) {
+	r := h.cast(obj)
+	informer, ok := h.getInformer(r)
+	if !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.GetNamespace()) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeDynamicResourceState(h.informers[0], r, types.StateMissing)
+		}
+		return
+	}
+	h.resourceStateCh <- makeDynamicResourceState(informer, r, calculateDynamicState(r, informer))
+}
+
+func (h *dynamicEventHandler) ObjectDeleted(obj interface{}) {
+	r := h.cast(obj)
+	informer, ok := h.getInformer(r)
+	if !ok {
+		return
+	}
+	h.resourceStateCh <- makeDynamicResourceState(informer, r, types.StateMissing)
+}
+
+func (h *dynamicEventHandler) cast(obj interface{}) *unstructured.Unstructured {
+	r, _ := obj.(*unstructured.Unstructured)
+	return r
+}
+
+func (h *dynamicEventHandler) getInformer(r *unstructured.Unstructured) (types.StatusInformer, bool) {
+	if r != nil {
+		for _, informer := range h.informers {
+			if informer.Matches(r.GetNamespace(), r.GetName(), r.GetLabels()) {
+				return informer, true
+			}
+		}
+	}
+	return types.StatusInformer{}, false
+}
+
+func makeDynamicResourceState(informer types.StatusInformer, r *unstructured.Unstructured, state types.State) types.ResourceState {
+	// use the kind from the informer so that the state matches the resource state built from the informers
+	return types.ResourceState{
+		Kind:      informer.Kind,
+		Name:      r.GetName(),
+		Namespace: r.GetNamespace(),
+		Labels:    r.GetLabels(),
+		State:     state,
+	}
+}
+
+// calculateDynamicState returns the state of the first matching rule, or the default state if no rules match.
+func calculateDynamicState(r *unstructured.Unstructured, informer types.StatusInformer) types.State {
+	rules, state := informer.StateRules, informer.DefaultState
+	if len(rules) == 0 {
+		rules = defaultStateRules
+	}
+	if state == "" {
+		state = defaultState
+	}
+
+	for _, rule := range rules {
+		matches, err := stateRuleMatches(r, rule)
+		if err != nil {
+			log.Printf("Failed to evaluate state rule for %s/%s: %v", informer.Kind, r.GetName(), err)
+			continue
+		}
+		if matches {
+			return rule.State
+		}
+	}
+
+	return state
+}
+
+func stateRuleMatches(r *unstructured.Unstructured, rule types.StateRule) (bool, error) {
+	if rule.Condition != nil {
+		conditions, _, err := unstructured.NestedSlice(r.Object, "status", "conditions")
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get status conditions")
+		}
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if fmt.Sprint(condition["type"]) == rule.Condition.Type && strings.EqualFold(fmt.Sprint(condition["status"]), rule.Condition.Status) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	value, err := evaluateJSONPath(r, rule.JSONPath)
+	if err != nil {
+		return false, err
+	}
+	return value == rule.Value, nil
+}
+
+func evaluateJSONPath(r *unstructured.Unstructured, expression string) (string, error) {
+	if !strings.HasPrefix(expression, "{") {
+		expression = fmt.Sprintf("{%s}", expression)
+	}
+
+	j := jsonpath.New("state").AllowMissingKeys(true)
+	if err := j.Parse(expression); err != nil {
+		return "", errors.Wrapf(err, "failed to parse jsonpath %s", expression)
+	}
+
+	var buf bytes.Buffer
+	if err := j.Execute(&buf, r.Object); err != nil {
+		return "", errors.Wrapf(err, "failed to execute jsonpath %s", expression)
+	}
+	return buf.String(), nil
+}
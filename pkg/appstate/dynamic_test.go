@@ -0,0 +1,170 @@
+package appstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPostgres(name string, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "acid.zalan.do/v1",
+			"kind":       "Postgres",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "default",
+			},
+			"status": status,
+		},
+	}
+}
+
+func Test_calculateDynamicState(t *testing.T) {
+	readyCondition := func(status string) map[string]interface{} {
+		return map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": status},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		informer types.StatusInformer
+		want     types.State
+	}{
+		{
+			name: "default rules with ready condition",
+			obj:  newPostgres("main", readyCondition("True")),
+			want: types.StateReady,
+		},
+		{
+			name: "default rules with not ready condition",
+			obj:  newPostgres("main", readyCondition("False")),
+			want: types.StateUnavailable,
+		},
+		{
+			name: "default rules without conditions",
+			obj:  newPostgres("main", nil),
+			want: types.StateReady,
+		},
+		{
+			name: "condition rule",
+			obj: newPostgres("main", map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False"},
+					map[string]interface{}{"type": "Progressing", "status": "True"},
+				},
+			}),
+			informer: types.StatusInformer{
+				StateRules: []types.StateRule{
+					{Condition: &types.ConditionMatch{Type: "Ready", Status: "True"}, State: types.StateReady},
+					{Condition: &types.ConditionMatch{Type: "Progressing", Status: "True"}, State: types.StateUpdating},
+				},
+				DefaultState: types.StateUnavailable,
+			},
+			want: types.StateUpdating,
+		},
+		{
+			name: "jsonpath rule",
+			obj:  newPostgres("main", map[string]interface{}{"phase": "Running"}),
+			informer: types.StatusInformer{
+				StateRules: []types.StateRule{
+					{JSONPath: "{.status.phase}", Value: "Creating", State: types.StateUpdating},
+					{JSONPath: ".status.phase", Value: "Running", State: types.StateReady},
+				},
+				DefaultState: types.StateUnavailable,
+			},
+			want: types.StateReady,
+		},
+		{
+			name: "no matching rule",
+			obj:  newPostgres("main", map[string]interface{}{"phase": "Failed"}),
+			informer: types.StatusInformer{
+				StateRules: []types.StateRule{
+					{JSONPath: "{.status.phase}", Value: "Running", State: types.StateReady},
+				},
+				DefaultState: types.StateUnavailable,
+			},
+			want: types.StateUnavailable,
+		},
+		{
+			name: "missing jsonpath field",
+			obj:  newPostgres("main", nil),
+			informer: types.StatusInformer{
+				StateRules: []types.StateRule{
+					{JSONPath: "{.status.phase}", Value: "", State: types.StateUpdating},
+				},
+			},
+			want: types.StateUpdating,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, calculateDynamicState(tt.obj, tt.informer))
+		})
+	}
+}
+
+func Test_runDynamicController(t *testing.T) {
+	req := require.New(t)
+
+	gvr := schema.GroupVersionResource{Group: "acid.zalan.do", Version: "v1", Resource: "postgreses"}
+
+	clientset := fake.NewSimpleClientset()
+	clientset.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "acid.zalan.do/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "postgreses", SingularName: "postgres", Kind: "Postgres", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "watch"}},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr: "PostgresList",
+	}, newPostgres("main", map[string]interface{}{"phase": "Running"}))
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, "default", nil)
+	defer factory.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resourceStateCh := make(chan types.ResourceState)
+	go runDynamicController(ctx, clientset, factory, "default", []types.StatusInformer{
+		{
+			Kind:       "postgres",
+			Name:       "main",
+			Namespace:  "default",
+			APIVersion: "acid.zalan.do/v1",
+			StateRules: []types.StateRule{
+				{JSONPath: "{.status.phase}", Value: "Running", State: types.StateReady},
+			},
+			DefaultState: types.StateUnavailable,
+		},
+	}, resourceStateCh)
+
+	select {
+	case resourceState := <-resourceStateCh:
+		req.Equal(types.ResourceState{
+			Kind:      "postgres",
+			Name:      "main",
+			Namespace: "default",
+			State:     types.StateReady,
+		}, resourceState)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for resource state")
+	}
+}
@@ -0,0 +1,343 @@
+package appstate
+
+import (
+	"context"
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	GatewayResourceKind   = "gateway"
+	HTTPRouteResourceKind = "httproute"
+
+	GatewayAPIGroup = "gateway.networking.k8s.io"
+)
+
+var (
+	// gatewayAPIVersions are the versions of the Gateway API that are watched, in order of preference
+	gatewayAPIVersions = []string{"v1", "v1beta1"}
+)
+
+func init() {
+	registerResourceKindNames(GatewayResourceKind, "gateways", "gtw")
+	registerResourceKindNames(HTTPRouteResourceKind, "httproutes")
+}
+
+func isGatewayAPIResourceKind(kind string) bool {
+	return kind == GatewayResourceKind || kind == HTTPRouteResourceKind
+}
+
+// runGatewayAPIController watches Gateway API resources of the kind with the dynamic client, since the Gateway API is not built in.
+func runGatewayAPIController(
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, dynamicFactory dynamicinformer.DynamicSharedInformerFactory,
+	targetNamespace string, kind string, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	if dynamicFactory == nil {
+		log.Printf("Dynamic client is not configured, not watching %d %s informers", len(informers), kind)
+		return
+	}
+
+	gvr, err := getGatewayAPIResource(clientset, kind)
+	if err != nil {
+		log.Printf("Failed to get Gateway API resource for informer kind %s: %v", kind, err)
+		return
+	}
+
+	informer := dynamicFactory.ForResource(gvr)
+	informers = filterStatusInformersByResourceKind(informers, kind)
+
+	if kind != HTTPRouteResourceKind {
+		eventHandler := NewGatewayAPIEventHandler(kind, informer.Lister(), serviceBackends{}, informers, resourceStateCh)
+		runInformer(ctx, dynamicFactory, informer.Informer(), eventHandler)
+		return
+	}
+
+	services := factory.Core().V1().Services()
+	endpoints := factory.Core().V1().Endpoints()
+
+	eventHandler := NewGatewayAPIEventHandler(
+		kind,
+		informer.Lister(),
+		serviceBackends{
+			clientset:       clientset,
+			namespace:       targetNamespace,
+			serviceLister:   services.Lister(),
+			endpointsLister: endpoints.Lister(),
+		},
+		informers,
+		resourceStateCh,
+	)
+
+	// routes rely on the status of their backend services and endpoints as well,
+	// so they are recalculated when those change
+	factory.Start(ctx.Done())
+	runInformer(ctx, dynamicFactory, informer.Informer(), eventHandler,
+		informerDependency{informer: services.Informer(), onChange: eventHandler.backendChanged},
+		informerDependency{informer: endpoints.Informer(), onChange: eventHandler.backendChanged},
+	)
+}
+
+func getGatewayAPIResource(clientset kubernetes.Interface, kind string) (schema.GroupVersionResource, error) {
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+
+	var lastErr error
+	for _, version := range gatewayAPIVersions {
+		gvr, err := getGroupVersionResource(mapper, schema.GroupVersion{Group: GatewayAPIGroup, Version: version}.String(), kind)
+		if err == nil {
+			return gvr, nil
+		}
+		lastErr = err
+	}
+	return schema.GroupVersionResource{}, errors.Wrap(lastErr, "gateway api is not installed")
+}
+
+type gatewayAPIEventHandler struct {
+	kind            string
+	lister          cache.GenericLister
+	backends        serviceBackends
+	informers       []types.StatusInformer
+	resourceStateCh chan<- types.ResourceState
+}
+
+func NewGatewayAPIEventHandler(kind string, lister cache.GenericLister, backends serviceBackends, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *gatewayAPIEventHandler {
+	return &gatewayAPIEventHandler{
+		kind:            kind,
+		lister:          lister,
+		backends:        backends,
+		informers:       informers,
+		resourceStateCh: resourceStateCh,
+	}
+}
+
+func (h *gatewayAPIEventHandler) ObjectCreated(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- h.makeResourceState(r, h.calculateState(r))
+}
+
+func (h *gatewayAPIEventHandler) ObjectUpdated(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.GetNamespace()) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- h.makeResourceState(r, types.StateMissing)
+		}
+		return
+	}
+	h.resourceStateCh <- h.makeResourceState(r, h.calculateState(r))
+}
+
+func (h *gatewayAPIEventHandler) ObjectDeleted(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- h.makeResourceState(r, types.StateMissing)
+}
+
+// backendChanged recalculates the states of the routes with a backend service of the name.
+func (h *gatewayAPIEventHandler) backendChanged(namespace string, name string) {
+	objs, err := h.lister.List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, obj := range objs {
+		r := h.cast(obj)
+		if r == nil {
+			continue
+		}
+		route, err := toHTTPRoute(r)
+		if err != nil {
+			continue
+		}
+		for _, backendRef := range route.backendServices() {
+			if backendRef.Namespace == namespace && backendRef.Name == name {
+				h.ObjectUpdated(r)
+				break
+			}
+		}
+	}
+}
+
+func (h *gatewayAPIEventHandler) cast(obj interface{}) *unstructured.Unstructured {
+	r, _ := obj.(*unstructured.Unstructured)
+	return r
+}
+
+func (h *gatewayAPIEventHandler) getInformer(r *unstructured.Unstructured) (types.StatusInformer, bool) {
+	if r != nil {
+		for _, informer := range h.informers {
+			if informer.Matches(r.GetNamespace(), r.GetName(), r.GetLabels()) {
+				return informer, true
+			}
+		}
+	}
+	return types.StatusInformer{}, false
+}
+
+func (h *gatewayAPIEventHandler) makeResourceState(r *unstructured.Unstructured, state types.State) types.ResourceState {
+	return types.ResourceState{
+		Kind:      h.kind,
+		Name:      r.GetName(),
+		Namespace: r.GetNamespace(),
+		Labels:    r.GetLabels(),
+		State:     state,
+	}
+}
+
+func (h *gatewayAPIEventHandler) calculateState(r *unstructured.Unstructured) types.State {
+	switch h.kind {
+	case GatewayResourceKind:
+		gateway, err := toGateway(r)
+		if err != nil {
+			log.Printf("Failed to decode gateway %s: %v", r.GetName(), err)
+			return types.StateUnavailable
+		}
+		return calculateGatewayState(gateway)
+	case HTTPRouteResourceKind:
+		route, err := toHTTPRoute(r)
+		if err != nil {
+			log.Printf("Failed to decode httproute %s: %v", r.GetName(), err)
+			return types.StateUnavailable
+		}
+		return calculateHTTPRouteState(route, h.backends.getState)
+	}
+	return types.StateUnavailable
+}
+
+// gateway is the part of a Gateway API Gateway that its state is calculated from.
+type gateway struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Status            struct {
+		Conditions []metav1.Condition `json:"conditions"`
+		Listeners  []struct {
+			Name       string             `json:"name"`
+			Conditions []metav1.Condition `json:"conditions"`
+		} `json:"listeners"`
+	} `json:"status"`
+}
+
+// httpRoute is the part of a Gateway API HTTPRoute that its state is calculated from.
+type httpRoute struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Rules []struct {
+			BackendRefs []gatewayBackendRef `json:"backendRefs"`
+		} `json:"rules"`
+	} `json:"spec"`
+	Status struct {
+		Parents []struct {
+			Conditions []metav1.Condition `json:"conditions"`
+		} `json:"parents"`
+	} `json:"status"`
+}
+
+type gatewayBackendRef struct {
+	Group     string `json:"group"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func toGateway(r *unstructured.Unstructured) (*gateway, error) {
+	g := &gateway{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(r.Object, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func toHTTPRoute(r *unstructured.Unstructured) (*httpRoute, error) {
+	route := &httpRoute{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(r.Object, route); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+// backendServices returns the services that the route sends traffic to, with their namespaces defaulted.
+func (r *httpRoute) backendServices() []gatewayBackendRef {
+	var refs []gatewayBackendRef
+	for _, rule := range r.Spec.Rules {
+		for _, ref := range rule.BackendRefs {
+			if (ref.Group != "" && ref.Group != "core") || (ref.Kind != "" && ref.Kind != "Service") {
+				continue
+			}
+			if ref.Namespace == "" {
+				ref.Namespace = r.Namespace
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// calculateGatewayState returns the state of a gateway from its Accepted and Programmed conditions,
+// and the Programmed and ResolvedRefs conditions of its listeners.
+func calculateGatewayState(g *gateway) types.State {
+	states := []types.State{
+		gatewayConditionState(g.Status.Conditions, g.Generation, "Accepted", types.StateUnavailable),
+		gatewayConditionState(g.Status.Conditions, g.Generation, "Programmed", types.StateUnavailable),
+	}
+	for _, listener := range g.Status.Listeners {
+		// a listener that is not ready only affects part of the traffic
+		states = append(states,
+			gatewayConditionState(listener.Conditions, g.Generation, "Programmed", types.StateDegraded),
+			gatewayConditionState(listener.Conditions, g.Generation, "ResolvedRefs", types.StateDegraded),
+		)
+	}
+	return types.MinState(states...)
+}
+
+// calculateHTTPRouteState returns the state of a route from the Accepted and ResolvedRefs conditions of each of its parent gateways,
+// and the states of its backend services.
+func calculateHTTPRouteState(r *httpRoute, getServiceState func(namespace string, name string) types.State) types.State {
+	if len(r.Status.Parents) == 0 {
+		// the route has not been reconciled by a gateway controller yet
+		return types.StateUpdating
+	}
+
+	states := []types.State{}
+	for _, parent := range r.Status.Parents {
+		states = append(states,
+			gatewayConditionState(parent.Conditions, r.Generation, "Accepted", types.StateUnavailable),
+			gatewayConditionState(parent.Conditions, r.Generation, "ResolvedRefs", types.StateDegraded),
+		)
+	}
+	for _, ref := range r.backendServices() {
+		states = append(states, getServiceState(ref.Namespace, ref.Name))
+	}
+	return types.MinState(states...)
+}
+
+// gatewayConditionState returns the state for a Gateway API condition. The state is updating if the condition
+// is not known yet or was observed for an older generation of the resource, and falseState if it is false.
+func gatewayConditionState(conditions []metav1.Condition, generation int64, conditionType string, falseState types.State) types.State {
+	condition := meta.FindStatusCondition(conditions, conditionType)
+	if condition == nil || condition.Status == metav1.ConditionUnknown {
+		return types.StateUpdating
+	}
+	if condition.ObservedGeneration != 0 && condition.ObservedGeneration < generation {
+		return types.StateUpdating
+	}
+	if condition.Status == metav1.ConditionFalse {
+		return falseState
+	}
+	return types.StateReady
+}
@@ -0,0 +1,161 @@
+package appstate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func unstructuredFromJSON(t *testing.T, data string) *unstructured.Unstructured {
+	obj := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(data), &obj))
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func Test_calculateGatewayState(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway string
+		want    types.State
+	}{
+		{
+			name:    "not reconciled",
+			gateway: `{"metadata": {"name": "gw", "generation": 1}}`,
+			want:    types.StateUpdating,
+		},
+		{
+			name: "ready",
+			gateway: `{"metadata": {"name": "gw", "generation": 1}, "status": {
+				"conditions": [
+					{"type": "Accepted", "status": "True", "observedGeneration": 1},
+					{"type": "Programmed", "status": "True", "observedGeneration": 1}
+				],
+				"listeners": [{"name": "http", "conditions": [
+					{"type": "Programmed", "status": "True", "observedGeneration": 1},
+					{"type": "ResolvedRefs", "status": "True", "observedGeneration": 1}
+				]}]
+			}}`,
+			want: types.StateReady,
+		},
+		{
+			name: "stale generation",
+			gateway: `{"metadata": {"name": "gw", "generation": 2}, "status": {
+				"conditions": [
+					{"type": "Accepted", "status": "True", "observedGeneration": 1},
+					{"type": "Programmed", "status": "True", "observedGeneration": 1}
+				]
+			}}`,
+			want: types.StateUpdating,
+		},
+		{
+			name: "not programmed",
+			gateway: `{"metadata": {"name": "gw", "generation": 1}, "status": {
+				"conditions": [
+					{"type": "Accepted", "status": "True", "observedGeneration": 1},
+					{"type": "Programmed", "status": "False", "observedGeneration": 1}
+				]
+			}}`,
+			want: types.StateUnavailable,
+		},
+		{
+			name: "listener refs not resolved",
+			gateway: `{"metadata": {"name": "gw", "generation": 1}, "status": {
+				"conditions": [
+					{"type": "Accepted", "status": "True", "observedGeneration": 1},
+					{"type": "Programmed", "status": "True", "observedGeneration": 1}
+				],
+				"listeners": [{"name": "https", "conditions": [
+					{"type": "Programmed", "status": "True", "observedGeneration": 1},
+					{"type": "ResolvedRefs", "status": "False", "observedGeneration": 1}
+				]}]
+			}}`,
+			want: types.StateDegraded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := toGateway(unstructuredFromJSON(t, tt.gateway))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, calculateGatewayState(g))
+		})
+	}
+}
+
+func Test_calculateHTTPRouteState(t *testing.T) {
+	serviceStates := map[string]types.State{
+		"default/web":    types.StateReady,
+		"default/api":    types.StateUnavailable,
+		"other/frontend": types.StateReady,
+	}
+	getServiceState := func(namespace string, name string) types.State {
+		if state, ok := serviceStates[namespace+"/"+name]; ok {
+			return state
+		}
+		return types.StateUnavailable
+	}
+
+	accepted := `"status": {"parents": [{"conditions": [
+		{"type": "Accepted", "status": "True", "observedGeneration": 1},
+		{"type": "ResolvedRefs", "status": "True", "observedGeneration": 1}
+	]}]}`
+
+	tests := []struct {
+		name  string
+		route string
+		want  types.State
+	}{
+		{
+			name:  "no parents",
+			route: `{"metadata": {"name": "route", "namespace": "default", "generation": 1}}`,
+			want:  types.StateUpdating,
+		},
+		{
+			name: "ready",
+			route: `{"metadata": {"name": "route", "namespace": "default", "generation": 1},
+				"spec": {"rules": [{"backendRefs": [{"name": "web"}, {"name": "frontend", "namespace": "other"}]}]}, ` + accepted + `}`,
+			want: types.StateReady,
+		},
+		{
+			name: "backend service unavailable",
+			route: `{"metadata": {"name": "route", "namespace": "default", "generation": 1},
+				"spec": {"rules": [{"backendRefs": [{"name": "web"}]}, {"backendRefs": [{"name": "api"}]}]}, ` + accepted + `}`,
+			want: types.StateUnavailable,
+		},
+		{
+			name: "non service backends are ignored",
+			route: `{"metadata": {"name": "route", "namespace": "default", "generation": 1},
+				"spec": {"rules": [{"backendRefs": [{"name": "web"}, {"group": "example.com", "kind": "Bucket", "name": "api"}]}]}, ` + accepted + `}`,
+			want: types.StateReady,
+		},
+		{
+			name: "not accepted",
+			route: `{"metadata": {"name": "route", "namespace": "default", "generation": 1},
+				"spec": {"rules": [{"backendRefs": [{"name": "web"}]}]},
+				"status": {"parents": [{"conditions": [
+					{"type": "Accepted", "status": "False", "observedGeneration": 1},
+					{"type": "ResolvedRefs", "status": "True", "observedGeneration": 1}
+				]}]}}`,
+			want: types.StateUnavailable,
+		},
+		{
+			name: "refs not resolved",
+			route: `{"metadata": {"name": "route", "namespace": "default", "generation": 1},
+				"spec": {"rules": [{"backendRefs": [{"name": "web"}]}]},
+				"status": {"parents": [{"conditions": [
+					{"type": "Accepted", "status": "True", "observedGeneration": 1},
+					{"type": "ResolvedRefs", "status": "False", "observedGeneration": 1}
+				]}]}}`,
+			want: types.StateDegraded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, err := toHTTPRoute(unstructuredFromJSON(t, tt.route))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, calculateHTTPRouteState(route, getServiceState))
+		})
+	}
+}
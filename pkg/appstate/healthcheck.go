@@ -0,0 +1,197 @@
+package appstate
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	HTTPCheckResourceKind = "httpcheck"
+	TCPCheckResourceKind  = "tcpcheck"
+
+	// healthCheckMaxBodySize is the maximum size of a response body that is matched
+	healthCheckMaxBodySize = 1 << 20
+)
+
+func init() {
+	registerResourceKindNames(HTTPCheckResourceKind, "httpchecks")
+	registerResourceKindNames(TCPCheckResourceKind, "tcpchecks")
+}
+
+// healthCheckFunc checks an endpoint and returns the state of the check, with a reason and message if it failed.
+type healthCheckFunc func(ctx context.Context) (state types.State, reason string, message string)
+
+func runHTTPCheckController(
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	runHealthCheckController(ctx, filterStatusInformersByResourceKind(informers, HTTPCheckResourceKind), resourceStateCh, func(informer types.StatusInformer) (healthCheckFunc, error) {
+		if informer.HealthCheck.URL == "" {
+			return nil, fmt.Errorf("httpcheck requires a url")
+		}
+		return newHTTPCheck(ctx, *informer.HealthCheck), nil
+	})
+}
+
+func runTCPCheckController(
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	runHealthCheckController(ctx, filterStatusInformersByResourceKind(informers, TCPCheckResourceKind), resourceStateCh, func(informer types.StatusInformer) (healthCheckFunc, error) {
+		if informer.HealthCheck.Address == "" {
+			return nil, fmt.Errorf("tcpcheck requires an address")
+		}
+		return newTCPCheck(*informer.HealthCheck), nil
+	})
+}
+
+// runHealthCheckController runs the check of each informer on its interval until the context is done.
+func runHealthCheckController(
+	ctx context.Context, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+	newCheck func(types.StatusInformer) (healthCheckFunc, error),
+) {
+	done := make(chan struct{})
+	running := 0
+	for _, informer := range informers {
+		if informer.HealthCheck == nil || informer.Name == "" {
+			log.Printf("Ignoring %s informer %s/%s without a name and health check", informer.Kind, informer.Namespace, informer.Name)
+			continue
+		}
+		check, err := newCheck(informer)
+		if err != nil {
+			log.Printf("Ignoring %s informer %s/%s: %v", informer.Kind, informer.Namespace, informer.Name, err)
+			continue
+		}
+		// both durations were validated when the informer was parsed
+		interval, _ := informer.HealthCheck.GetInterval()
+		timeout, _ := informer.HealthCheck.GetTimeout()
+
+		running++
+		go func(informer types.StatusInformer) {
+			defer func() { done <- struct{}{} }()
+			runHealthCheck(ctx, informer, check, interval, timeout, resourceStateCh)
+		}(informer)
+	}
+
+	for i := 0; i < running; i++ {
+		<-done
+	}
+}
+
+func runHealthCheck(
+	ctx context.Context, informer types.StatusInformer, check healthCheckFunc,
+	interval time.Duration, timeout time.Duration, resourceStateCh chan<- types.ResourceState,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		state, reason, message := check(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		resourceState := types.ResourceState{
+			Kind:      informer.Kind,
+			Name:      informer.Name,
+			Namespace: informer.Namespace,
+			State:     state,
+			Reason:    reason,
+			Message:   message,
+		}
+		select {
+		case resourceStateCh <- resourceState:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// newHTTPCheck returns a check that requests the url of the health check. The idle connections of the check are
+// closed when the context is done.
+func newHTTPCheck(ctx context.Context, healthCheck types.HealthCheck) healthCheckFunc {
+	method := healthCheck.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var bodyMatch *regexp.Regexp
+	if healthCheck.BodyMatch != "" {
+		bodyMatch = regexp.MustCompile(healthCheck.BodyMatch)
+	}
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: healthCheck.InsecureSkipVerify},
+	}
+	context.AfterFunc(ctx, transport.CloseIdleConnections)
+
+	client := &http.Client{
+		Transport: transport,
+		// redirects are not followed so that 3xx status codes can be expected
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return func(ctx context.Context) (types.State, string, string) {
+		req, err := http.NewRequestWithContext(ctx, method, healthCheck.URL, nil)
+		if err != nil {
+			return types.StateUnavailable, "RequestFailed", err.Error()
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return types.StateUnavailable, "RequestFailed", err.Error()
+		}
+		defer resp.Body.Close()
+
+		if healthCheck.ExpectedStatus != 0 {
+			if resp.StatusCode != healthCheck.ExpectedStatus {
+				return types.StateUnavailable, "UnexpectedStatus", fmt.Sprintf("expected status %d, got %d", healthCheck.ExpectedStatus, resp.StatusCode)
+			}
+		} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return types.StateUnavailable, "UnexpectedStatus", fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		}
+
+		if bodyMatch != nil {
+			body, err := io.ReadAll(io.LimitReader(resp.Body, healthCheckMaxBodySize))
+			if err != nil {
+				return types.StateUnavailable, "RequestFailed", err.Error()
+			}
+			if !bodyMatch.Match(body) {
+				return types.StateUnavailable, "UnexpectedBody", fmt.Sprintf("response body does not match %q", healthCheck.BodyMatch)
+			}
+		}
+
+		return types.StateReady, "", ""
+	}
+}
+
+func newTCPCheck(healthCheck types.HealthCheck) healthCheckFunc {
+	return func(ctx context.Context) (types.State, string, string) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", healthCheck.Address)
+		if err != nil {
+			return types.StateUnavailable, "ConnectionFailed", err.Error()
+		}
+		conn.Close()
+		return types.StateReady, "", ""
+	}
+}
@@ -0,0 +1,182 @@
+package appstate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newHTTPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/moved":
+			http.Redirect(w, r, "/healthz", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		healthCheck types.HealthCheck
+		timeout     time.Duration
+		wantState   types.State
+		wantReason  string
+	}{
+		{
+			name:        "ready",
+			healthCheck: types.HealthCheck{URL: srv.URL + "/healthz", BodyMatch: `"status":\s*"ok"`},
+			wantState:   types.StateReady,
+		},
+		{
+			name:        "unexpected status",
+			healthCheck: types.HealthCheck{URL: srv.URL + "/down"},
+			wantState:   types.StateUnavailable,
+			wantReason:  "UnexpectedStatus",
+		},
+		{
+			name:        "expected status",
+			healthCheck: types.HealthCheck{URL: srv.URL + "/down", ExpectedStatus: http.StatusServiceUnavailable},
+			wantState:   types.StateReady,
+		},
+		{
+			name:        "redirects are not followed",
+			healthCheck: types.HealthCheck{URL: srv.URL + "/moved", ExpectedStatus: http.StatusOK},
+			wantState:   types.StateUnavailable,
+			wantReason:  "UnexpectedStatus",
+		},
+		{
+			name:        "unexpected body",
+			healthCheck: types.HealthCheck{URL: srv.URL + "/healthz", BodyMatch: "healthy"},
+			wantState:   types.StateUnavailable,
+			wantReason:  "UnexpectedBody",
+		},
+		{
+			name:        "timeout",
+			healthCheck: types.HealthCheck{URL: srv.URL + "/slow"},
+			timeout:     50 * time.Millisecond,
+			wantState:   types.StateUnavailable,
+			wantReason:  "RequestFailed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			state, reason, message := newHTTPCheck(ctx, tt.healthCheck)(ctx)
+			req.Equal(tt.wantState, state)
+			req.Equal(tt.wantReason, reason)
+			if tt.wantReason != "" {
+				req.NotEmpty(message)
+			}
+		})
+	}
+}
+
+func Test_newHTTPCheck_CloseIdleConnections(t *testing.T) {
+	req := require.New(t)
+
+	closed := make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			close(closed)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	state, _, _ := newHTTPCheck(ctx, types.HealthCheck{URL: srv.URL})(ctx)
+	req.Equal(types.StateReady, state)
+
+	// the kept-alive connection is closed once the check is stopped
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		req.Fail("idle connection was not closed")
+	}
+}
+
+func Test_newTCPCheck(t *testing.T) {
+	req := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	address := listener.Addr().String()
+
+	check := newTCPCheck(types.HealthCheck{Address: address})
+	state, reason, _ := check(context.Background())
+	req.Equal(types.StateReady, state)
+	req.Empty(reason)
+
+	listener.Close()
+	state, reason, message := check(context.Background())
+	req.Equal(types.StateUnavailable, state)
+	req.Equal("ConnectionFailed", reason)
+	req.NotEmpty(message)
+}
+
+func TestRunHTTPCheckController(t *testing.T) {
+	req := require.New(t)
+
+	healthy := make(chan bool, 1)
+	healthy <- true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := <-healthy
+		healthy <- ok
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	informers := []types.StatusInformer{
+		{Kind: HTTPCheckResourceKind, Name: "api", Namespace: "default", HealthCheck: &types.HealthCheck{URL: srv.URL, Interval: "10ms"}},
+		// ignored, a health check is required
+		{Kind: HTTPCheckResourceKind, Name: "web", Namespace: "default"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resourceStateCh := make(chan types.ResourceState)
+	done := make(chan struct{})
+	go func() {
+		runHTTPCheckController(ctx, nil, nil, "default", informers, resourceStateCh)
+		close(done)
+	}()
+
+	resourceState := <-resourceStateCh
+	req.Equal(types.ResourceState{Kind: HTTPCheckResourceKind, Name: "api", Namespace: "default", State: types.StateReady}, resourceState)
+
+	<-healthy
+	healthy <- false
+	req.Eventually(func() bool {
+		return (<-resourceStateCh).State == types.StateUnavailable
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		req.Fail("controller did not stop")
+	}
+}
@@ -2,6 +2,7 @@ package appstate
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
@@ -9,11 +10,12 @@ import (
 	"github.com/replicatedhq/replicated-sdk/pkg/logger"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
-	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
 )
 
 const (
@@ -25,44 +27,65 @@ func init() {
 }
 
 func runIngressController(
-	ctx context.Context, clientset kubernetes.Interface, targetNamespace string,
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
 	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
 ) {
-	listwatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.NetworkingV1().Ingresses(targetNamespace).List(context.TODO(), options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.NetworkingV1().Ingresses(targetNamespace).Watch(context.TODO(), options)
-		},
-	}
-	informer := cache.NewSharedInformer(
-		listwatch,
-		&networkingv1.Ingress{},
-		// NOTE: ingresses rely on endpoint and service status as well so unless we add
-		// additional informers, we have to resync more frequently.
-		10*time.Second,
-	)
+	ingresses := factory.Networking().V1().Ingresses()
+	services := factory.Core().V1().Services()
+	endpoints := factory.Core().V1().Endpoints()
+	secrets := tlsSecretInformer(factory, targetNamespace)
 
 	eventHandler := NewIngressEventHandler(
 		clientset,
+		targetNamespace,
+		ingresses.Lister(),
+		services.Lister(),
+		endpoints.Lister(),
+		corelisters.NewSecretLister(secrets.GetIndexer()),
 		filterStatusInformersByResourceKind(informers, IngressResourceKind),
 		resourceStateCh,
 	)
 
-	runInformer(ctx, informer, eventHandler)
+	// ingresses rely on the status of their backend services and endpoints, and on their TLS certificates as well,
+	// so they are recalculated when those change
+	runInformer(ctx, factory, ingresses.Informer(), eventHandler,
+		informerDependency{informer: services.Informer(), onChange: eventHandler.backendChanged},
+		informerDependency{informer: endpoints.Informer(), onChange: eventHandler.backendChanged},
+		informerDependency{informer: secrets, onChange: eventHandler.secretChanged},
+	)
 	return
 }
 
 type ingressEventHandler struct {
-	clientset       kubernetes.Interface
+	ingressLister   networkinglisters.IngressLister
+	backends        serviceBackends
+	secretLister    corelisters.SecretLister
+	k8sMinorVersion int
 	informers       []types.StatusInformer
 	resourceStateCh chan<- types.ResourceState
 }
 
-func NewIngressEventHandler(clientset kubernetes.Interface, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *ingressEventHandler {
+// NewIngressEventHandler returns an event handler for the ingresses in the namespace, which looks up the backend services
+// and endpoints, and the TLS secrets in the namespace from the listers.
+func NewIngressEventHandler(
+	clientset kubernetes.Interface, namespace string, ingressLister networkinglisters.IngressLister,
+	serviceLister corelisters.ServiceLister, endpointsLister corelisters.EndpointsLister, secretLister corelisters.SecretLister,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) *ingressEventHandler {
+	k8sMinorVersion, err := k8sutil.GetK8sMinorVersion(clientset)
+	if err != nil {
+		logger.Errorf("failed to get k8s minor version: %v", err)
+	}
 	return &ingressEventHandler{
-		clientset:       clientset,
+		ingressLister: ingressLister,
+		backends: serviceBackends{
+			clientset:       clientset,
+			namespace:       namespace,
+			serviceLister:   serviceLister,
+			endpointsLister: endpointsLister,
+		},
+		secretLister:    secretLister,
+		k8sMinorVersion: k8sMinorVersion,
 		informers:       informers,
 		resourceStateCh: resourceStateCh,
 	}
@@ -70,18 +93,24 @@ func NewIngressEventHandler(clientset kubernetes.Interface, informers []types.St
 
 func (h *ingressEventHandler) ObjectCreated(obj interface{}) {
 	r := h.cast(obj)
-	if _, ok := h.getInformer(r); !ok {
+	informer, ok := h.getInformer(r)
+	if !ok {
 		return
 	}
-	h.resourceStateCh <- makeIngressResourceState(r, CalculateIngressState(h.clientset, r))
+	h.resourceStateCh <- h.makeResourceState(r, informer)
 }
 
 func (h *ingressEventHandler) ObjectUpdated(obj interface{}) {
 	r := h.cast(obj)
-	if _, ok := h.getInformer(r); !ok {
+	informer, ok := h.getInformer(r)
+	if !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeIngressResourceState(r, types.StateMissing)
+		}
 		return
 	}
-	h.resourceStateCh <- makeIngressResourceState(r, CalculateIngressState(h.clientset, r))
+	h.resourceStateCh <- h.makeResourceState(r, informer)
 }
 
 func (h *ingressEventHandler) ObjectDeleted(obj interface{}) {
@@ -92,6 +121,32 @@ func (h *ingressEventHandler) ObjectDeleted(obj interface{}) {
 	h.resourceStateCh <- makeIngressResourceState(r, types.StateMissing)
 }
 
+// backendChanged recalculates the states of the ingresses with a backend service of the name.
+func (h *ingressEventHandler) backendChanged(namespace string, name string) {
+	ingresses, err := h.ingressLister.Ingresses(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, r := range ingresses {
+		if ingressHasBackendService(r, name) {
+			h.ObjectUpdated(r)
+		}
+	}
+}
+
+// secretChanged recalculates the states of the ingresses that serve the TLS secret of the name.
+func (h *ingressEventHandler) secretChanged(namespace string, name string) {
+	ingresses, err := h.ingressLister.Ingresses(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, r := range ingresses {
+		if slices.Contains(ingressTLSSecretNames(r), name) {
+			h.ObjectUpdated(r)
+		}
+	}
+}
+
 func (h *ingressEventHandler) cast(obj interface{}) *networkingv1.Ingress {
 	r, _ := obj.(*networkingv1.Ingress)
 	return r
@@ -100,7 +155,7 @@ func (h *ingressEventHandler) cast(obj interface{}) *networkingv1.Ingress {
 func (h *ingressEventHandler) getInformer(r *networkingv1.Ingress) (types.StatusInformer, bool) {
 	if r != nil {
 		for _, informer := range h.informers {
-			if r.Namespace == informer.Namespace && r.Name == informer.Name {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
 				return informer, true
 			}
 		}
@@ -113,18 +168,25 @@ func makeIngressResourceState(r *networkingv1.Ingress, state types.State) types.
 		Kind:      IngressResourceKind,
 		Name:      r.Name,
 		Namespace: r.Namespace,
+		Labels:    r.Labels,
 		State:     state,
 	}
 }
 
-func CalculateIngressState(clientset kubernetes.Interface, r *networkingv1.Ingress) types.State {
+// makeResourceState returns the state of the ingress, which includes the expiry of its TLS certificates.
+func (h *ingressEventHandler) makeResourceState(r *networkingv1.Ingress, informer types.StatusInformer) types.ResourceState {
+	resourceState := makeIngressResourceState(r, h.calculateIngressState(r))
+	if secretNames := ingressTLSSecretNames(r); len(secretNames) > 0 {
+		checkCertificates(h.secretLister, r.Namespace, secretNames, informer.GetCertificateExpiryWarning(), time.Now()).apply(&resourceState)
+	}
+	return resourceState
+}
+
+func (h *ingressEventHandler) calculateIngressState(r *networkingv1.Ingress) types.State {
 	ns := r.Namespace
 	backend := r.Spec.DefaultBackend
 
-	k8sMinorVersion, err := k8sutil.GetK8sMinorVersion(clientset)
-	if err != nil {
-		logger.Errorf("failed to get k8s minor version: %v", err)
-	} else if k8sMinorVersion < 22 && backend == nil {
+	if h.k8sMinorVersion > 0 && h.k8sMinorVersion < 22 && backend == nil {
 		// https://github.com/kubernetes/kubectl/blob/6b77b0790ab40d2a692ad80e9e4c962e784bb9b8/pkg/describe/versioned/describe.go#L2367
 		// Ingresses that don't specify a default backend inherit the default backend in the kube-system namespace.
 		// This behavior is applicable to Kubernetes versions prior to 1.22 (i.e. Ingress versions before networking.k8s.io/v1).
@@ -141,12 +203,12 @@ func CalculateIngressState(clientset kubernetes.Interface, r *networkingv1.Ingre
 
 	var states []types.State
 	if backend != nil {
-		states = append(states, ingressGetStateFromBackend(clientset, ns, *backend))
+		states = append(states, h.getStateFromBackend(ns, *backend))
 	}
 
 	for _, rules := range r.Spec.Rules {
 		for _, path := range rules.HTTP.Paths {
-			states = append(states, ingressGetStateFromBackend(clientset, r.Namespace, path.Backend))
+			states = append(states, h.getStateFromBackend(r.Namespace, path.Backend))
 		}
 	}
 	// https://github.com/kubernetes/kubernetes/blob/badcd4af3f592376ce891b7c1b7a43ed6a18a348/pkg/printers/internalversion/printers.go#L1067
@@ -154,15 +216,40 @@ func CalculateIngressState(clientset kubernetes.Interface, r *networkingv1.Ingre
 	return types.MinState(states...)
 }
 
-func ingressGetStateFromBackend(clientset kubernetes.Interface, namespace string, backend networkingv1.IngressBackend) (minState types.State) {
+func (h *ingressEventHandler) getStateFromBackend(namespace string, backend networkingv1.IngressBackend) types.State {
 	if backend.Service == nil {
 		return types.StateUnavailable
 	}
-	service, _ := clientset.CoreV1().Services(namespace).Get(context.TODO(), backend.Service.Name, metav1.GetOptions{})
-	if service == nil {
-		return types.StateUnavailable
+
+	return h.backends.getState(namespace, backend.Service.Name)
+}
+
+func ingressHasBackendService(r *networkingv1.Ingress, name string) bool {
+	if r.Spec.DefaultBackend != nil && r.Spec.DefaultBackend.Service != nil && r.Spec.DefaultBackend.Service.Name == name {
+		return true
+	}
+	for _, rule := range r.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && path.Backend.Service.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ingressTLSSecretNames returns the names of the secrets with the TLS certificates of the ingress.
+func ingressTLSSecretNames(r *networkingv1.Ingress) []string {
+	var names []string
+	for _, tls := range r.Spec.TLS {
+		if tls.SecretName != "" && !slices.Contains(names, tls.SecretName) {
+			names = append(names, tls.SecretName)
+		}
 	}
-	return serviceGetStateFromEndpoints(clientset, service)
+	return names
 }
 
 func ingressGetStateFromExternalIP(ing *networkingv1.Ingress) types.State {
@@ -10,8 +10,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/version"
 	discoveryfake "k8s.io/client-go/discovery/fake"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
 )
 
 func mockClientsetK8sVersion(expectedMajor string, expectedMinor string) kubernetes.Interface {
@@ -60,7 +62,31 @@ func mockClientsetK8sVersion(expectedMajor string, expectedMinor string) kuberne
 	return clientset
 }
 
-func TestCalculateIngressState(t *testing.T) {
+func newTestIngressEventHandler(t *testing.T, clientset kubernetes.Interface) *ingressEventHandler {
+	factory := kubeinformers.NewSharedInformerFactoryWithOptions(clientset, 0, kubeinformers.WithNamespace("default"))
+	h := NewIngressEventHandler(
+		clientset,
+		"default",
+		factory.Networking().V1().Ingresses().Lister(),
+		factory.Core().V1().Services().Lister(),
+		factory.Core().V1().Endpoints().Lister(),
+		corelisters.NewSecretLister(tlsSecretInformer(factory, "default").GetIndexer()),
+		nil,
+		nil,
+	)
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() {
+		close(stopCh)
+		factory.Shutdown()
+	})
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	return h
+}
+
+func Test_calculateIngressState(t *testing.T) {
 	type args struct {
 		clientset kubernetes.Interface
 		r         *networkingv1.Ingress
@@ -120,8 +146,9 @@ func TestCalculateIngressState(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := CalculateIngressState(tt.args.clientset, tt.args.r); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("CalculateIngressState() = %v, want %v", got, tt.want)
+			h := newTestIngressEventHandler(t, tt.args.clientset)
+			if got := h.calculateIngressState(tt.args.r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calculateIngressState() = %v, want %v", got, tt.want)
 			}
 		})
 	}
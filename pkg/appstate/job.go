@@ -0,0 +1,116 @@
+package appstate
+
+import (
+	"context"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	JobResourceKind = "job"
+)
+
+func init() {
+	registerResourceKindNames(JobResourceKind, "jobs")
+}
+
+func runJobController(
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	informer := factory.Batch().V1().Jobs().Informer()
+
+	eventHandler := NewJobEventHandler(
+		filterStatusInformersByResourceKind(informers, JobResourceKind),
+		resourceStateCh,
+	)
+
+	runInformer(ctx, factory, informer, eventHandler)
+	return
+}
+
+type jobEventHandler struct {
+	informers       []types.StatusInformer
+	resourceStateCh chan<- types.ResourceState
+}
+
+func NewJobEventHandler(informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *jobEventHandler {
+	return &jobEventHandler{
+		informers:       informers,
+		resourceStateCh: resourceStateCh,
+	}
+}
+
+func (h *jobEventHandler) ObjectCreated(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- makeJobResourceState(r, CalculateJobState(r))
+}
+
+func (h *jobEventHandler) ObjectUpdated(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeJobResourceState(r, types.StateMissing)
+		}
+		return
+	}
+	h.resourceStateCh <- makeJobResourceState(r, CalculateJobState(r))
+}
+
+func (h *jobEventHandler) ObjectDeleted(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- makeJobResourceState(r, types.StateMissing)
+}
+
+func (h *jobEventHandler) cast(obj interface{}) *batchv1.Job {
+	r, _ := obj.(*batchv1.Job)
+	return r
+}
+
+func (h *jobEventHandler) getInformer(r *batchv1.Job) (types.StatusInformer, bool) {
+	if r != nil {
+		for _, informer := range h.informers {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
+				return informer, true
+			}
+		}
+	}
+	return types.StatusInformer{}, false
+}
+
+func makeJobResourceState(r *batchv1.Job, state types.State) types.ResourceState {
+	return types.ResourceState{
+		Kind:      JobResourceKind,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Labels:    r.Labels,
+		State:     state,
+	}
+}
+
+// CalculateJobState returns ready for a completed job, degraded for a failed job and updating while the job is running.
+func CalculateJobState(r *batchv1.Job) types.State {
+	for _, condition := range r.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobFailed:
+			return types.StateDegraded
+		case batchv1.JobComplete:
+			return types.StateReady
+		}
+	}
+	return types.StateUpdating
+}
@@ -0,0 +1,52 @@
+package appstate
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCalculateJobState(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []batchv1.JobCondition
+		want       types.State
+	}{
+		{
+			name: "running",
+			want: types.StateUpdating,
+		},
+		{
+			name: "complete",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			},
+			want: types.StateReady,
+		},
+		{
+			name: "failed",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+			},
+			want: types.StateDegraded,
+		},
+		{
+			name: "suspended",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobSuspended, Status: corev1.ConditionTrue},
+				{Type: batchv1.JobFailed, Status: corev1.ConditionFalse},
+			},
+			want: types.StateUpdating,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &batchv1.Job{Status: batchv1.JobStatus{Conditions: tt.conditions}}
+			if got := CalculateJobState(job); got != tt.want {
+				t.Errorf("CalculateJobState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
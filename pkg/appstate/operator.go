@@ -9,11 +9,10 @@ import (
 	"github.com/mitchellh/hashstructure"
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
-	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
-	"github.com/replicatedhq/replicated-sdk/pkg/logger"
 	"github.com/replicatedhq/replicated-sdk/pkg/report"
 	"github.com/replicatedhq/replicated-sdk/pkg/store"
 	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -24,18 +23,29 @@ var (
 type Operator struct {
 	targetNamespace string
 	clientset       kubernetes.Interface
-	appStateMonitor *Monitor
+	dynamicClient   dynamic.Interface
+
+	// the monitor is replaced when the operator is started and shut down, which can happen concurrently
+	// (e.g. on shutdown and when leadership is lost)
+	appStateMonitorMtx sync.Mutex
+	appStateMonitor    *Monitor
 }
 
 // NewOperator creates and initializes a new Operator.
-func InitOperator(clientset kubernetes.Interface, targetNamespace string) *Operator {
+func InitOperator(clientset kubernetes.Interface, dynamicClient dynamic.Interface, targetNamespace string) *Operator {
 	operator = &Operator{
 		clientset:       clientset,
+		dynamicClient:   dynamicClient,
 		targetNamespace: targetNamespace,
 	}
 	return operator
 }
 
+// GetOperator returns the operator, or nil if it has not been initialized.
+func GetOperator() *Operator {
+	return operator
+}
+
 func MustGetOperator() *Operator {
 	if operator != nil {
 		return operator
@@ -44,24 +54,37 @@ func MustGetOperator() *Operator {
 }
 
 func (o *Operator) Start() {
-	o.appStateMonitor = NewMonitor(o.clientset, o.targetNamespace)
-	go o.runAppStateMonitor()
+	o.appStateMonitorMtx.Lock()
+	defer o.appStateMonitorMtx.Unlock()
+
+	o.appStateMonitor = NewMonitor(o.clientset, o.dynamicClient, o.targetNamespace)
+	go o.runAppStateMonitor(o.appStateMonitor)
 }
 
 func (o *Operator) Shutdown() {
 	log.Println("Shutting down the status informers client")
 
+	o.appStateMonitorMtx.Lock()
+	defer o.appStateMonitorMtx.Unlock()
+
 	if o.appStateMonitor != nil {
 		o.appStateMonitor.Shutdown()
+		o.appStateMonitor = nil
 	}
 }
 
-func (o *Operator) runAppStateMonitor() error {
+func (o *Operator) getAppStateMonitor() *Monitor {
+	o.appStateMonitorMtx.Lock()
+	defer o.appStateMonitorMtx.Unlock()
+	return o.appStateMonitor
+}
+
+func (o *Operator) runAppStateMonitor(appStateMonitor *Monitor) error {
 	m := map[string]func(f func()){}
 	hash := map[string]uint64{}
 	var mtx sync.Mutex
 
-	for appStatus := range o.appStateMonitor.AppStatusChan() {
+	for appStatus := range appStateMonitor.AppStatusChan() {
 		throttled, ok := m[appStatus.AppSlug]
 		if !ok {
 			throttled = util.NewThrottle(time.Second)
@@ -87,17 +110,24 @@ func (o *Operator) runAppStateMonitor() error {
 }
 
 func (o *Operator) ApplyAppInformers(args types.AppInformersArgs) {
+	appStateMonitor := o.getAppStateMonitor()
+	if appStateMonitor == nil {
+		// the informers are applied when the operator is started
+		log.Printf("ignoring inform event for app %s, the operator is not running", args.AppSlug)
+		return
+	}
+
 	log.Printf("received an inform event: %#v", args)
 
 	appSlug := args.AppSlug
 	sequence := args.Sequence
-	informerStrings := args.Informers
+	informerConfigs := args.Informers
 
 	var informers []types.StatusInformer
-	for _, str := range informerStrings {
-		informer, err := str.Parse()
+	for _, config := range informerConfigs {
+		informer, err := config.Parse()
 		if err != nil {
-			log.Printf("failed to parse informer %s: %s", str, err.Error())
+			log.Printf("failed to parse informer %s: %s", config.Informer, err.Error())
 			continue // don't stop
 		}
 		informers = append(informers, informer)
@@ -118,26 +148,41 @@ func (o *Operator) ApplyAppInformers(args types.AppInformersArgs) {
 		return
 	}
 
-	o.appStateMonitor.Apply(appSlug, sequence, informers)
+	aggregation := args.Aggregation
+	if err := aggregation.Validate(); err != nil {
+		log.Printf("ignoring invalid status aggregation policy: %s", err.Error())
+		aggregation = nil
+	}
+
+	damping := args.Damping
+	if err := damping.Validate(); err != nil {
+		log.Printf("ignoring invalid status damping policy: %s", err.Error())
+		damping = nil
+	}
+
+	if args.CertificateExpiryWarningDays < 0 {
+		log.Printf("ignoring invalid certificate expiry warning of %d days", args.CertificateExpiryWarningDays)
+	} else if args.CertificateExpiryWarningDays > 0 {
+		for i := range informers {
+			informers[i].CertificateExpiryWarning = time.Duration(args.CertificateExpiryWarningDays) * 24 * time.Hour
+		}
+	}
+
+	appStateMonitor.Apply(appSlug, sequence, informers, aggregation, damping)
 }
 
 func (o *Operator) setAppStatus(newAppStatus types.AppStatus) error {
 	currentAppStatus := store.GetStore().GetAppStatus()
-	store.GetStore().SetAppStatus(newAppStatus)
-
-	if newAppStatus.State != currentAppStatus.State {
-		log.Printf("app state changed from %q to %q", currentAppStatus.State, newAppStatus.State)
-		go func() {
-			clientset, err := k8sutil.GetClientset()
-			if err != nil {
-				logger.Error(errors.Wrap(err, "failed to get clientset"))
-				return
-			}
-			if err := report.SendInstanceData(clientset, store.GetStore()); err != nil {
-				logger.Error(errors.Wrap(err, "failed to send instance data"))
-			}
-		}()
+
+	if newAppStatus.State == currentAppStatus.State {
+		store.GetStore().SetAppStatus(newAppStatus)
+		return nil
 	}
 
+	log.Printf("app state changed from %q to %q", currentAppStatus.State, newAppStatus.State)
+	// the status and the transition to it are checkpointed together
+	store.GetStore().SetAppStatusWithTransition(newAppStatus, buildAppStatusTransition(currentAppStatus, newAppStatus))
+	report.SendInstanceDataAsync(store.GetStore())
+
 	return nil
 }
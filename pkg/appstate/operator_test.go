@@ -0,0 +1,69 @@
+package appstate
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestOperator_ApplyAppInformersNotStarted(t *testing.T) {
+	store.InitInMemory(store.InitInMemoryStoreOptions{
+		License: &kotsv1beta1.License{Spec: kotsv1beta1.LicenseSpec{AppSlug: "app-slug"}},
+	})
+
+	// informers that are applied before the operator is started, or after it is shut down, are ignored
+	o := InitOperator(nil, nil, "default")
+	o.ApplyAppInformers(types.AppInformersArgs{AppSlug: "app-slug"})
+	o.Shutdown()
+	o.ApplyAppInformers(types.AppInformersArgs{AppSlug: "app-slug"})
+
+	require.Equal(t, types.AppStatus{}, store.GetStore().GetAppStatus())
+}
+
+func TestOperator_ConcurrentShutdown(t *testing.T) {
+	store.InitInMemory(store.InitInMemoryStoreOptions{
+		License: &kotsv1beta1.License{Spec: kotsv1beta1.LicenseSpec{AppSlug: "app-slug"}},
+	})
+
+	o := InitOperator(fake.NewSimpleClientset(), nil, "default")
+	o.Start()
+
+	// the operator is shut down on shutdown and when leadership is lost at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.Shutdown()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.ApplyAppInformers(types.AppInformersArgs{AppSlug: "app-slug"})
+	}()
+	wg.Wait()
+}
+
+func TestMonitor_ApplyAfterShutdown(t *testing.T) {
+	m := NewMonitor(fake.NewSimpleClientset(), nil, "default")
+	m.Shutdown()
+
+	// the run loop has exited, applying informers does not block
+	applied := make(chan struct{})
+	go func() {
+		m.Apply("app-slug", 1, []types.StatusInformer{{Kind: "deployment", Name: "web", Namespace: "default"}}, nil, nil)
+		close(applied)
+	}()
+	select {
+	case <-applied:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "apply blocked after shutdown")
+	}
+}
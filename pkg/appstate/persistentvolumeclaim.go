@@ -2,15 +2,11 @@ package appstate
 
 import (
 	"context"
-	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 const (
@@ -22,29 +18,17 @@ func init() {
 }
 
 func runPersistentVolumeClaimController(
-	ctx context.Context, clientset kubernetes.Interface, targetNamespace string,
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
 	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
 ) {
-	listwatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.CoreV1().PersistentVolumeClaims(targetNamespace).List(context.TODO(), options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.CoreV1().PersistentVolumeClaims(targetNamespace).Watch(context.TODO(), options)
-		},
-	}
-	informer := cache.NewSharedInformer(
-		listwatch,
-		&corev1.PersistentVolumeClaim{},
-		time.Minute,
-	)
+	informer := factory.Core().V1().PersistentVolumeClaims().Informer()
 
 	eventHandler := NewPersistentVolumeClaimEventHandler(
 		filterStatusInformersByResourceKind(informers, PersistentVolumeClaimResourceKind),
 		resourceStateCh,
 	)
 
-	runInformer(ctx, informer, eventHandler)
+	runInformer(ctx, factory, informer, eventHandler)
 	return
 }
 
@@ -71,6 +55,10 @@ func (h *persistentVolumeClaimEventHandler) ObjectCreated(obj interface{}) {
 func (h *persistentVolumeClaimEventHandler) ObjectUpdated(obj interface{}) {
 	r := h.cast(obj)
 	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makePersistentVolumeClaimResourceState(r, types.StateMissing)
+		}
 		return
 	}
 	h.resourceStateCh <- makePersistentVolumeClaimResourceState(r, CalculatePersistentVolumeClaimState(r))
@@ -92,7 +80,7 @@ func (h *persistentVolumeClaimEventHandler) cast(obj interface{}) *corev1.Persis
 func (h *persistentVolumeClaimEventHandler) getInformer(r *corev1.PersistentVolumeClaim) (types.StatusInformer, bool) {
 	if r != nil {
 		for _, informer := range h.informers {
-			if r.Namespace == informer.Namespace && r.Name == informer.Name {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
 				return informer, true
 			}
 		}
@@ -105,6 +93,7 @@ func makePersistentVolumeClaimResourceState(r *corev1.PersistentVolumeClaim, sta
 		Kind:      PersistentVolumeClaimResourceKind,
 		Name:      r.Name,
 		Namespace: r.Namespace,
+		Labels:    r.Labels,
 		State:     state,
 	}
 }
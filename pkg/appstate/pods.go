@@ -0,0 +1,169 @@
+package appstate
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	PodReasonOOMKilled        = "OOMKilled"
+	PodReasonCrashLoopBackOff = "CrashLoopBackOff"
+	PodReasonUnschedulable    = "Unschedulable"
+)
+
+// podReasonSeverity ranks the reasons so that the most relevant problem is reported when pods have different problems.
+// Container waiting reasons that are not listed (e.g. CreateContainerConfigError) rank below the image pull errors.
+var podReasonSeverity = map[string]int{
+	PodReasonOOMKilled:        6,
+	PodReasonCrashLoopBackOff: 5,
+	"ImagePullBackOff":        4,
+	"ErrImagePull":            4,
+	"InvalidImageName":        4,
+	PodReasonUnschedulable:    2,
+}
+
+const (
+	defaultPodReasonSeverity = 3
+)
+
+// ignoredWaitingReasons are the reasons of containers that are starting normally
+var ignoredWaitingReasons = map[string]bool{
+	"ContainerCreating": true,
+	"PodInitializing":   true,
+}
+
+type podProblem struct {
+	reason  string
+	message string
+}
+
+func (p podProblem) severity() int {
+	if severity, ok := podReasonSeverity[p.reason]; ok {
+		return severity
+	}
+	return defaultPodReasonSeverity
+}
+
+// addPodDiagnostics sets the reason and message of a workload that is not ready to the most severe problem of its pods.
+func addPodDiagnostics(podLister corelisters.PodLister, resourceState types.ResourceState, selector *metav1.LabelSelector) types.ResourceState {
+	if resourceState.State == types.StateReady || resourceState.State == types.StateMissing || selector == nil {
+		return resourceState
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		log.Printf("failed to parse label selector for %s %s: %s", resourceState.Kind, resourceState.Name, err)
+		return resourceState
+	}
+
+	pods, err := podLister.Pods(resourceState.Namespace).List(labelSelector)
+	if err != nil {
+		log.Printf("failed to list pods for %s %s: %s", resourceState.Kind, resourceState.Name, err)
+		return resourceState
+	}
+
+	podItems := make([]corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		podItems = append(podItems, *pod)
+	}
+	if problem, ok := diagnosePods(podItems); ok {
+		resourceState.Reason = problem.reason
+		resourceState.Message = problem.message
+	}
+
+	return resourceState
+}
+
+// selectsPod returns true if the selector of a workload matches the labels of the pod. A pod that is no longer
+// in the cache because it was deleted may have belonged to any workload.
+func selectsPod(selector *metav1.LabelSelector, pod *corev1.Pod) bool {
+	if pod == nil {
+		return true
+	}
+	if selector == nil {
+		return false
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return labelSelector.Matches(labels.Set(pod.Labels))
+}
+
+func diagnosePods(pods []corev1.Pod) (podProblem, bool) {
+	var worst podProblem
+	found := false
+	for _, pod := range pods {
+		for _, problem := range diagnosePod(pod) {
+			if !found || problem.severity() > worst.severity() {
+				worst = problem
+				found = true
+			}
+		}
+	}
+	return worst, found
+}
+
+func diagnosePod(pod corev1.Pod) []podProblem {
+	problems := []podProblem{}
+
+	if pod.Status.Phase == corev1.PodPending {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+				problems = append(problems, podProblem{
+					reason:  PodReasonUnschedulable,
+					message: fmt.Sprintf("pod %s: %s", pod.Name, condition.Message),
+				})
+			}
+		}
+	}
+
+	containerStatuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	containerStatuses = append(containerStatuses, pod.Status.ContainerStatuses...)
+	for _, status := range containerStatuses {
+		if problem, ok := diagnoseContainer(pod.Name, status); ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+func diagnoseContainer(podName string, status corev1.ContainerStatus) (podProblem, bool) {
+	if terminated := status.State.Terminated; terminated != nil && terminated.Reason == PodReasonOOMKilled {
+		return podProblem{
+			reason:  PodReasonOOMKilled,
+			message: fmt.Sprintf("container %s in pod %s was OOMKilled", status.Name, podName),
+		}, true
+	}
+
+	waiting := status.State.Waiting
+	if waiting == nil || waiting.Reason == "" || ignoredWaitingReasons[waiting.Reason] {
+		return podProblem{}, false
+	}
+
+	// a container that is crash looping because it runs out of memory is reported as OOMKilled
+	if waiting.Reason == PodReasonCrashLoopBackOff {
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.Reason == PodReasonOOMKilled {
+			return podProblem{
+				reason:  PodReasonOOMKilled,
+				message: fmt.Sprintf("container %s in pod %s was OOMKilled (restarted %d times)", status.Name, podName, status.RestartCount),
+			}, true
+		}
+	}
+
+	message := fmt.Sprintf("container %s in pod %s is waiting: %s", status.Name, podName, waiting.Reason)
+	if waiting.Message != "" {
+		message = fmt.Sprintf("%s: %s", message, waiting.Message)
+	}
+	return podProblem{
+		reason:  waiting.Reason,
+		message: message,
+	}, true
+}
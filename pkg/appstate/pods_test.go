@@ -0,0 +1,190 @@
+package appstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func waitingPod(name string, reason string, message string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "web", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message}}},
+			},
+		},
+	}
+}
+
+func Test_diagnosePods(t *testing.T) {
+	oomKilledPod := waitingPod("web-oom", PodReasonCrashLoopBackOff, "back-off restarting failed container")
+	oomKilledPod.Status.ContainerStatuses[0].RestartCount = 3
+	oomKilledPod.Status.ContainerStatuses[0].LastTerminationState.Terminated = &corev1.ContainerStateTerminated{Reason: PodReasonOOMKilled}
+
+	unschedulablePod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-pending"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable, Message: "0/3 nodes are available: 3 Insufficient cpu."},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		pods     []corev1.Pod
+		want     podProblem
+		wantNone bool
+	}{
+		{
+			name: "healthy and starting pods",
+			pods: []corev1.Pod{
+				waitingPod("web-1", "ContainerCreating", ""),
+				{ObjectMeta: metav1.ObjectMeta{Name: "web-2"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			},
+			wantNone: true,
+		},
+		{
+			name: "image pull backoff",
+			pods: []corev1.Pod{waitingPod("web-1", "ImagePullBackOff", `Back-off pulling image "web:missing"`)},
+			want: podProblem{
+				reason:  "ImagePullBackOff",
+				message: `container web in pod web-1 is waiting: ImagePullBackOff: Back-off pulling image "web:missing"`,
+			},
+		},
+		{
+			name: "unschedulable",
+			pods: []corev1.Pod{unschedulablePod},
+			want: podProblem{
+				reason:  PodReasonUnschedulable,
+				message: "pod web-pending: 0/3 nodes are available: 3 Insufficient cpu.",
+			},
+		},
+		{
+			name: "crash loop ranks above unschedulable",
+			pods: []corev1.Pod{unschedulablePod, waitingPod("web-1", PodReasonCrashLoopBackOff, "")},
+			want: podProblem{
+				reason:  PodReasonCrashLoopBackOff,
+				message: "container web in pod web-1 is waiting: CrashLoopBackOff",
+			},
+		},
+		{
+			name: "crash loop caused by oom",
+			pods: []corev1.Pod{waitingPod("web-1", PodReasonCrashLoopBackOff, ""), oomKilledPod},
+			want: podProblem{
+				reason:  PodReasonOOMKilled,
+				message: "container web in pod web-oom was OOMKilled (restarted 3 times)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := diagnosePods(tt.pods)
+			if tt.wantNone {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_addPodDiagnostics(t *testing.T) {
+	req := require.New(t)
+
+	pod := waitingPod("web-1", PodReasonCrashLoopBackOff, "")
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	req.NoError(indexer.Add(&pod))
+	podLister := corelisters.NewPodLister(indexer)
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+
+	resourceState := types.ResourceState{Kind: "deployment", Name: "web", Namespace: "default", State: types.StateUnavailable}
+	got := addPodDiagnostics(podLister, resourceState, selector)
+	req.Equal(PodReasonCrashLoopBackOff, got.Reason)
+	req.Equal("container web in pod web-1 is waiting: CrashLoopBackOff", got.Message)
+
+	// ready resources are not diagnosed
+	resourceState.State = types.StateReady
+	got = addPodDiagnostics(podLister, resourceState, selector)
+	req.Empty(got.Reason)
+	req.Empty(got.Message)
+}
+
+func TestRunDeploymentController_PodChanged(t *testing.T) {
+	req := require.New(t)
+
+	pod := waitingPod("web-1", "ContainerCreating", "")
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "default", Labels: map[string]string{"app": "api"}},
+		},
+		&pod,
+	)
+
+	factory := kubeinformers.NewSharedInformerFactoryWithOptions(clientset, 0, kubeinformers.WithNamespace("default"))
+	defer factory.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resourceStateCh := make(chan types.ResourceState)
+	go runDeploymentController(ctx, clientset, factory, "default", []types.StatusInformer{
+		{Kind: DeploymentResourceKind, Name: "web", Namespace: "default"},
+	}, resourceStateCh)
+
+	nextResourceState := func() types.ResourceState {
+		select {
+		case resourceState := <-resourceStateCh:
+			return resourceState
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for resource state")
+			return types.ResourceState{}
+		}
+	}
+
+	resourceState := nextResourceState()
+	req.Equal(types.StateUnavailable, resourceState.State)
+	req.Empty(resourceState.Reason)
+
+	// pods of other workloads do not recalculate the deployment
+	_, err := clientset.CoreV1().Pods("default").Update(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "default", Labels: map[string]string{"app": "api"}, ResourceVersion: "2"},
+	}, metav1.UpdateOptions{})
+	req.NoError(err)
+
+	// the deployment is recalculated when its pods change, without waiting for a resync
+	crashingPod := waitingPod("web-1", PodReasonCrashLoopBackOff, "")
+	crashingPod.ResourceVersion = "2"
+	_, err = clientset.CoreV1().Pods("default").Update(context.TODO(), &crashingPod, metav1.UpdateOptions{})
+	req.NoError(err)
+	resourceState = nextResourceState()
+	req.Equal(types.StateUnavailable, resourceState.State)
+	req.Equal(PodReasonCrashLoopBackOff, resourceState.Reason)
+
+	// and when they are deleted
+	err = clientset.CoreV1().Pods("default").Delete(context.TODO(), "web-1", metav1.DeleteOptions{})
+	req.NoError(err)
+	resourceState = nextResourceState()
+	req.Equal(types.StateUnavailable, resourceState.State)
+	req.Empty(resourceState.Reason)
+}
@@ -0,0 +1,251 @@
+package appstate
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	corev1 "k8s.io/api/core/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	SecretResourceKind = "secret"
+)
+
+func init() {
+	registerResourceKindNames(SecretResourceKind, "secrets")
+}
+
+func runSecretController(
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
+	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
+) {
+	informer := tlsSecretInformer(factory, targetNamespace)
+
+	eventHandler := NewSecretEventHandler(
+		filterStatusInformersByResourceKind(informers, SecretResourceKind),
+		resourceStateCh,
+	)
+
+	runInformer(ctx, factory, informer, eventHandler)
+	return
+}
+
+// tlsSecretInformer returns the informer of the secrets in the namespace. Secrets of any type can hold a TLS certificate
+// in their tls.crt key (e.g. Opaque secrets referenced by ingresses), so all secrets are watched, but only the tls.crt key
+// is cached so that the other secrets in the namespace, such as helm releases, do not use memory.
+func tlsSecretInformer(factory kubeinformers.SharedInformerFactory, namespace string) cache.SharedIndexInformer {
+	return factory.InformerFor(&corev1.Secret{}, func(clientset kubernetes.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+		informer := coreinformers.NewFilteredSecretInformer(
+			clientset,
+			namespace,
+			resyncPeriod,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+			nil,
+		)
+		// the informer has not been started yet, so setting the transform cannot fail
+		_ = informer.SetTransform(stripSecretData)
+		return informer
+	})
+}
+
+// stripSecretData removes everything but the tls.crt key from the data of a secret before it is cached.
+func stripSecretData(obj interface{}) (interface{}, error) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return obj, nil
+	}
+	cert, hasCert := secret.Data[corev1.TLSCertKey]
+	secret.Data = nil
+	secret.StringData = nil
+	secret.ManagedFields = nil
+	if hasCert {
+		secret.Data = map[string][]byte{corev1.TLSCertKey: cert}
+	}
+	return secret, nil
+}
+
+type secretEventHandler struct {
+	informers       []types.StatusInformer
+	resourceStateCh chan<- types.ResourceState
+}
+
+func NewSecretEventHandler(informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *secretEventHandler {
+	return &secretEventHandler{
+		informers:       informers,
+		resourceStateCh: resourceStateCh,
+	}
+}
+
+func (h *secretEventHandler) ObjectCreated(obj interface{}) {
+	r := h.cast(obj)
+	informer, ok := h.getInformer(r)
+	if !ok {
+		return
+	}
+	h.resourceStateCh <- makeSecretResourceState(r, informer.GetCertificateExpiryWarning(), time.Now())
+}
+
+func (h *secretEventHandler) ObjectUpdated(obj interface{}) {
+	r := h.cast(obj)
+	informer, ok := h.getInformer(r)
+	if !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- types.ResourceState{Kind: SecretResourceKind, Name: r.Name, Namespace: r.Namespace, State: types.StateMissing}
+		}
+		return
+	}
+	h.resourceStateCh <- makeSecretResourceState(r, informer.GetCertificateExpiryWarning(), time.Now())
+}
+
+func (h *secretEventHandler) ObjectDeleted(obj interface{}) {
+	r := h.cast(obj)
+	if _, ok := h.getInformer(r); !ok {
+		return
+	}
+	h.resourceStateCh <- types.ResourceState{Kind: SecretResourceKind, Name: r.Name, Namespace: r.Namespace, State: types.StateMissing}
+}
+
+func (h *secretEventHandler) cast(obj interface{}) *corev1.Secret {
+	r, _ := obj.(*corev1.Secret)
+	return r
+}
+
+func (h *secretEventHandler) getInformer(r *corev1.Secret) (types.StatusInformer, bool) {
+	if r != nil {
+		for _, informer := range h.informers {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
+				return informer, true
+			}
+		}
+	}
+	return types.StatusInformer{}, false
+}
+
+func makeSecretResourceState(r *corev1.Secret, warning time.Duration, now time.Time) types.ResourceState {
+	check := newCertificateCheck()
+	check.addSecret(r.Name, r, warning, now)
+
+	resourceState := types.ResourceState{
+		Kind:      SecretResourceKind,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Labels:    r.Labels,
+		State:     types.StateReady,
+	}
+	check.apply(&resourceState)
+	return resourceState
+}
+
+// certificateCheck is the result of checking the TLS certificates in the secrets that a resource serves.
+// The state, reason and message are those of the certificate that is closest to, or furthest past, its expiry.
+type certificateCheck struct {
+	state        types.State
+	reason       string
+	message      string
+	certificates []types.CertificateStatus
+}
+
+func newCertificateCheck() *certificateCheck {
+	return &certificateCheck{state: types.StateReady}
+}
+
+// checkCertificates checks the certificates in the secrets of the names in the namespace.
+func checkCertificates(secretLister corelisters.SecretLister, namespace string, secretNames []string, warning time.Duration, now time.Time) *certificateCheck {
+	check := newCertificateCheck()
+	for _, name := range secretNames {
+		secret, err := secretLister.Secrets(namespace).Get(name)
+		if err != nil {
+			secret = nil
+		}
+		check.addSecret(name, secret, warning, now)
+	}
+	return check
+}
+
+// addSecret checks the certificate in the secret of the name, the secret is nil if it does not exist.
+// Certificates are degraded within the warning window before their expiry, and unavailable once they have expired.
+func (c *certificateCheck) addSecret(name string, secret *corev1.Secret, warning time.Duration, now time.Time) {
+	if secret == nil {
+		c.add(types.StateDegraded, "CertificateNotFound", fmt.Sprintf("TLS secret %s was not found", name))
+		return
+	}
+
+	cert, err := parseSecretCertificate(secret)
+	if err != nil {
+		c.add(types.StateDegraded, "InvalidCertificate", fmt.Sprintf("TLS secret %s: %v", name, err))
+		return
+	}
+
+	status := types.CertificateStatus{
+		SecretName:   name,
+		DNSNames:     cert.DNSNames,
+		NotAfter:     cert.NotAfter,
+		DaysToExpiry: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+	}
+	c.certificates = append(c.certificates, status)
+
+	switch {
+	case !now.Before(cert.NotAfter):
+		c.add(types.StateUnavailable, "CertificateExpired", fmt.Sprintf("TLS certificate in secret %s expired on %s", name, cert.NotAfter.UTC().Format(time.RFC3339)))
+	case now.Add(warning).After(cert.NotAfter):
+		c.add(types.StateDegraded, "CertificateExpiring", fmt.Sprintf("TLS certificate in secret %s expires in %d days", name, status.DaysToExpiry))
+	}
+}
+
+func (c *certificateCheck) add(state types.State, reason string, message string) {
+	if c.reason == "" || types.MinState(c.state, state) != c.state {
+		c.reason = reason
+		c.message = message
+	}
+	c.state = types.MinState(c.state, state)
+}
+
+// apply sets the certificates of the resource, and lowers its state if a certificate is expiring.
+// The reason of the resource is kept if it is already less ready than the certificates.
+func (c *certificateCheck) apply(resourceState *types.ResourceState) {
+	resourceState.Certificates = c.certificates
+	if c.state == types.StateReady {
+		return
+	}
+	next := types.MinState(resourceState.State, c.state)
+	if resourceState.Reason == "" || next != resourceState.State {
+		resourceState.Reason = c.reason
+		resourceState.Message = c.message
+	}
+	resourceState.State = next
+}
+
+// parseSecretCertificate returns the first certificate in the tls.crt key of the secret, which is the certificate that is served.
+func parseSecretCertificate(secret *corev1.Secret) (*x509.Certificate, error) {
+	data, ok := secret.Data[corev1.TLSCertKey]
+	if !ok || len(data) == 0 {
+		return nil, errors.Errorf("%s is empty", corev1.TLSCertKey)
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.Errorf("%s does not contain a certificate", corev1.TLSCertKey)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse certificate")
+		}
+		return cert, nil
+	}
+}
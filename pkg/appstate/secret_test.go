@@ -0,0 +1,173 @@
+package appstate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestTLSSecret(t *testing.T, name string, notAfter time.Time) *corev1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "app.example.com"},
+		DNSNames:     []string{"app.example.com"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
+
+func Test_makeSecretResourceState(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	warning := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name             string
+		secret           *corev1.Secret
+		wantState        types.State
+		wantReason       string
+		wantDaysToExpiry int
+	}{
+		{
+			name:             "valid",
+			secret:           newTestTLSSecret(t, "tls", now.Add(90*24*time.Hour)),
+			wantState:        types.StateReady,
+			wantDaysToExpiry: 90,
+		},
+		{
+			name:             "expiring",
+			secret:           newTestTLSSecret(t, "tls", now.Add(10*24*time.Hour+time.Hour)),
+			wantState:        types.StateDegraded,
+			wantReason:       "CertificateExpiring",
+			wantDaysToExpiry: 10,
+		},
+		{
+			name:             "expired",
+			secret:           newTestTLSSecret(t, "tls", now.Add(-time.Hour)),
+			wantState:        types.StateUnavailable,
+			wantReason:       "CertificateExpired",
+			wantDaysToExpiry: -1,
+		},
+		{
+			name: "invalid",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+				Data:       map[string][]byte{corev1.TLSCertKey: []byte("not a certificate")},
+			},
+			wantState:  types.StateDegraded,
+			wantReason: "InvalidCertificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			resourceState := makeSecretResourceState(tt.secret, warning, now)
+			req.Equal(tt.wantState, resourceState.State)
+			req.Equal(tt.wantReason, resourceState.Reason)
+			if tt.wantReason == "InvalidCertificate" {
+				req.Empty(resourceState.Certificates)
+				return
+			}
+			req.Len(resourceState.Certificates, 1)
+			req.Equal("tls", resourceState.Certificates[0].SecretName)
+			req.Equal([]string{"app.example.com"}, resourceState.Certificates[0].DNSNames)
+			req.Equal(tt.wantDaysToExpiry, resourceState.Certificates[0].DaysToExpiry)
+		})
+	}
+}
+
+func Test_ingressCertificates(t *testing.T) {
+	req := require.New(t)
+
+	expiring := newTestTLSSecret(t, "expiring-tls", time.Now().Add(7*24*time.Hour+time.Hour))
+	valid := newTestTLSSecret(t, "valid-tls", time.Now().Add(365*24*time.Hour))
+	// certificates are also read from secrets that are not of the kubernetes.io/tls type
+	opaque := newTestTLSSecret(t, "opaque-tls", time.Now().Add(30*24*time.Hour+time.Hour))
+	opaque.Type = corev1.SecretTypeOpaque
+	clientset := fake.NewSimpleClientset(expiring, valid, opaque)
+
+	h := newTestIngressEventHandler(t, clientset)
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"app.example.com"}, SecretName: "valid-tls"},
+				{Hosts: []string{"api.example.com"}, SecretName: "expiring-tls"},
+				{Hosts: []string{"www.example.com"}, SecretName: "valid-tls"},
+				{Hosts: []string{"web.example.com"}, SecretName: "opaque-tls"},
+			},
+		},
+		Status: networkingv1.IngressStatus{
+			LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+				Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "192.0.0.1"}},
+			},
+		},
+	}
+
+	resourceState := h.makeResourceState(ingress, types.StatusInformer{Kind: IngressResourceKind, Name: "web", Namespace: "default"})
+	req.Equal(types.StateDegraded, resourceState.State)
+	req.Equal("CertificateExpiring", resourceState.Reason)
+	req.Equal("TLS certificate in secret expiring-tls expires in 7 days", resourceState.Message)
+	req.Len(resourceState.Certificates, 3)
+	req.Equal(364, resourceState.Certificates[0].DaysToExpiry)
+	req.Equal(7, resourceState.Certificates[1].DaysToExpiry)
+	req.Equal(30, resourceState.Certificates[2].DaysToExpiry)
+
+	// a shorter warning window does not degrade the ingress
+	resourceState = h.makeResourceState(ingress, types.StatusInformer{Kind: IngressResourceKind, Name: "web", Namespace: "default", CertificateExpiryWarning: 24 * time.Hour})
+	req.Equal(types.StateReady, resourceState.State)
+	req.Empty(resourceState.Reason)
+
+	// a missing secret degrades the ingress
+	ingress.Spec.TLS = append(ingress.Spec.TLS, networkingv1.IngressTLS{SecretName: "missing-tls"})
+	resourceState = h.makeResourceState(ingress, types.StatusInformer{Kind: IngressResourceKind, Name: "web", Namespace: "default", CertificateExpiryWarning: 24 * time.Hour})
+	req.Equal(types.StateDegraded, resourceState.State)
+	req.Equal("CertificateNotFound", resourceState.Reason)
+}
+
+func Test_stripSecretData(t *testing.T) {
+	req := require.New(t)
+
+	secret := newTestTLSSecret(t, "tls", time.Now().Add(365*24*time.Hour))
+	cert := secret.Data[corev1.TLSCertKey]
+	secret.Data[corev1.TLSPrivateKeyKey] = []byte("key")
+
+	obj, err := stripSecretData(secret)
+	req.NoError(err)
+	req.Equal(map[string][]byte{corev1.TLSCertKey: cert}, obj.(*corev1.Secret).Data)
+
+	// secrets without a certificate, such as helm releases, are cached without their data
+	obj, err = stripSecretData(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1.app.v1", Namespace: "default"},
+		Type:       "helm.sh/release.v1",
+		Data:       map[string][]byte{"release": []byte("release")},
+	})
+	req.NoError(err)
+	req.Nil(obj.(*corev1.Secret).Data)
+}
@@ -2,16 +2,14 @@ package appstate
 
 import (
 	"context"
-	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
-	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	corelisters "k8s.io/client-go/listers/core/v1"
 )
 
 const (
@@ -23,44 +21,38 @@ func init() {
 }
 
 func runServiceController(
-	ctx context.Context, clientset kubernetes.Interface, targetNamespace string,
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
 	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
 ) {
-	listwatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.CoreV1().Services(targetNamespace).List(context.TODO(), options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.CoreV1().Services(targetNamespace).Watch(context.TODO(), options)
-		},
-	}
-	informer := cache.NewSharedInformer(
-		listwatch,
-		&corev1.Service{},
-		// NOTE: services rely on endpoint status as well so unless we add additional
-		// informers, we have to resync more frequently.
-		10*time.Second,
-	)
+	services := factory.Core().V1().Services()
+	endpoints := factory.Core().V1().Endpoints()
 
 	eventHandler := NewServiceEventHandler(
-		clientset,
+		services.Lister(),
+		endpoints.Lister(),
 		filterStatusInformersByResourceKind(informers, ServiceResourceKind),
 		resourceStateCh,
 	)
 
-	runInformer(ctx, informer, eventHandler)
+	// services rely on endpoint status as well, so they are recalculated when their endpoints change
+	runInformer(ctx, factory, services.Informer(), eventHandler, informerDependency{
+		informer: endpoints.Informer(),
+		onChange: eventHandler.endpointsChanged,
+	})
 	return
 }
 
 type serviceEventHandler struct {
-	clientset       kubernetes.Interface
+	serviceLister   corelisters.ServiceLister
+	endpointsLister corelisters.EndpointsLister
 	informers       []types.StatusInformer
 	resourceStateCh chan<- types.ResourceState
 }
 
-func NewServiceEventHandler(clientset kubernetes.Interface, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *serviceEventHandler {
+func NewServiceEventHandler(serviceLister corelisters.ServiceLister, endpointsLister corelisters.EndpointsLister, informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState) *serviceEventHandler {
 	return &serviceEventHandler{
-		clientset:       clientset,
+		serviceLister:   serviceLister,
+		endpointsLister: endpointsLister,
 		informers:       informers,
 		resourceStateCh: resourceStateCh,
 	}
@@ -71,15 +63,19 @@ func (h *serviceEventHandler) ObjectCreated(obj interface{}) {
 	if _, ok := h.getInformer(r); !ok {
 		return
 	}
-	h.resourceStateCh <- makeServiceResourceState(r, CalculateServiceState(h.clientset, r))
+	h.resourceStateCh <- makeServiceResourceState(r, CalculateServiceState(r, h.getEndpoints(r)))
 }
 
 func (h *serviceEventHandler) ObjectUpdated(obj interface{}) {
 	r := h.cast(obj)
 	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeServiceResourceState(r, types.StateMissing)
+		}
 		return
 	}
-	h.resourceStateCh <- makeServiceResourceState(r, CalculateServiceState(h.clientset, r))
+	h.resourceStateCh <- makeServiceResourceState(r, CalculateServiceState(r, h.getEndpoints(r)))
 }
 
 func (h *serviceEventHandler) ObjectDeleted(obj interface{}) {
@@ -90,6 +86,24 @@ func (h *serviceEventHandler) ObjectDeleted(obj interface{}) {
 	h.resourceStateCh <- makeServiceResourceState(r, types.StateMissing)
 }
 
+// endpointsChanged recalculates the state of the service of the endpoints, which has the same name.
+func (h *serviceEventHandler) endpointsChanged(namespace string, name string) {
+	r, err := h.serviceLister.Services(namespace).Get(name)
+	if err != nil {
+		return
+	}
+	h.ObjectUpdated(r)
+}
+
+// getEndpoints returns the endpoints of the service from the cache, or nil if they do not exist.
+func (h *serviceEventHandler) getEndpoints(r *corev1.Service) *corev1.Endpoints {
+	endpoints, err := h.endpointsLister.Endpoints(r.Namespace).Get(r.Name)
+	if err != nil {
+		return nil
+	}
+	return endpoints
+}
+
 func (h *serviceEventHandler) cast(obj interface{}) *corev1.Service {
 	r, _ := obj.(*corev1.Service)
 	return r
@@ -98,7 +112,7 @@ func (h *serviceEventHandler) cast(obj interface{}) *corev1.Service {
 func (h *serviceEventHandler) getInformer(r *corev1.Service) (types.StatusInformer, bool) {
 	if r != nil {
 		for _, informer := range h.informers {
-			if r.Namespace == informer.Namespace && r.Name == informer.Name {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
 				return informer, true
 			}
 		}
@@ -111,21 +125,21 @@ func makeServiceResourceState(r *corev1.Service, state types.State) types.Resour
 		Kind:      ServiceResourceKind,
 		Name:      r.Name,
 		Namespace: r.Namespace,
+		Labels:    r.Labels,
 		State:     state,
 	}
 }
 
-func CalculateServiceState(clientset kubernetes.Interface, r *corev1.Service) types.State {
+func CalculateServiceState(r *corev1.Service, endpoints *corev1.Endpoints) types.State {
 	var states []types.State
 	// https://github.com/kubernetes/kubectl/blob/6b77b0790ab40d2a692ad80e9e4c962e784bb9b8/pkg/describe/versioned/describe.go#L4617
-	states = append(states, serviceGetStateFromEndpoints(clientset, r))
+	states = append(states, serviceGetStateFromEndpoints(r, endpoints))
 	// https://github.com/kubernetes/kubernetes/blob/badcd4af3f592376ce891b7c1b7a43ed6a18a348/pkg/printers/internalversion/printers.go#L1003
 	states = append(states, serviceGetStateFromExternalIP(r))
 	return types.MinState(states...)
 }
 
-func serviceGetStateFromEndpoints(clientset kubernetes.Interface, svc *corev1.Service) (minState types.State) {
-	endpoints, _ := clientset.CoreV1().Endpoints(svc.Namespace).Get(context.TODO(), svc.Name, metav1.GetOptions{})
+func serviceGetStateFromEndpoints(svc *corev1.Service, endpoints *corev1.Endpoints) (minState types.State) {
 	if endpoints == nil {
 		// I'm unsure of the state for this case
 		return types.StateUnavailable
@@ -195,3 +209,31 @@ func loadBalancerStatusIPs(s corev1.LoadBalancerStatus) sets.String {
 	}
 	return result
 }
+
+// serviceBackends looks up the states of the services that are the backends of other resources, such as ingresses.
+// Services in the watched namespace are looked up from the listers, services in other namespaces from the api server.
+type serviceBackends struct {
+	clientset       kubernetes.Interface
+	namespace       string
+	serviceLister   corelisters.ServiceLister
+	endpointsLister corelisters.EndpointsLister
+}
+
+func (b serviceBackends) getState(namespace string, name string) types.State {
+	if namespace != b.namespace {
+		// e.g. the default backend of older clusters is in the kube-system namespace, which is not watched
+		service, err := b.clientset.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return types.StateUnavailable
+		}
+		endpoints, _ := b.clientset.CoreV1().Endpoints(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		return serviceGetStateFromEndpoints(service, endpoints)
+	}
+
+	service, err := b.serviceLister.Services(namespace).Get(name)
+	if err != nil {
+		return types.StateUnavailable
+	}
+	endpoints, _ := b.endpointsLister.Endpoints(namespace).Get(name)
+	return serviceGetStateFromEndpoints(service, endpoints)
+}
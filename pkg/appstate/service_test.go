@@ -0,0 +1,68 @@
+package appstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunServiceController(t *testing.T) {
+	req := require.New(t)
+
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}
+	clientset := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+		},
+		endpoints,
+	)
+
+	factory := kubeinformers.NewSharedInformerFactoryWithOptions(clientset, 0, kubeinformers.WithNamespace("default"))
+	defer factory.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resourceStateCh := make(chan types.ResourceState)
+	go runServiceController(ctx, clientset, factory, "default", []types.StatusInformer{
+		{Kind: ServiceResourceKind, Name: "web", Namespace: "default"},
+	}, resourceStateCh)
+
+	nextState := func() types.State {
+		select {
+		case resourceState := <-resourceStateCh:
+			return resourceState.State
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for resource state")
+			return ""
+		}
+	}
+
+	req.Equal(types.StateUnavailable, nextState())
+
+	// the service is recalculated when its endpoints change, without waiting for a resync
+	endpoints = endpoints.DeepCopy()
+	endpoints.ResourceVersion = "2"
+	endpoints.Subsets = []corev1.EndpointSubset{{
+		Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+		Ports:     []corev1.EndpointPort{{Name: "http", Port: 8080}},
+	}}
+	_, err := clientset.CoreV1().Endpoints("default").Update(context.TODO(), endpoints, metav1.UpdateOptions{})
+	req.NoError(err)
+	req.Equal(types.StateReady, nextState())
+
+	// the endpoints are looked up from the informer cache instead of the api server
+	for _, action := range clientset.Actions() {
+		req.Contains([]string{"list", "watch", "update"}, action.GetVerb(), "unexpected %s %s request", action.GetVerb(), action.GetResource().Resource)
+	}
+}
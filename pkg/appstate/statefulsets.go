@@ -3,16 +3,14 @@ package appstate
 import (
 	"context"
 	"log"
-	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 )
 
 const (
@@ -22,10 +20,10 @@ const (
 )
 
 type statefulSetEventHandler struct {
-	informers       []types.StatusInformer
-	resourceStateCh chan<- types.ResourceState
-	clientset       kubernetes.Interface
-	targetNamespace string
+	informers         []types.StatusInformer
+	resourceStateCh   chan<- types.ResourceState
+	statefulSetLister appslisters.StatefulSetLister
+	podLister         corelisters.PodLister
 }
 
 func init() {
@@ -33,31 +31,25 @@ func init() {
 }
 
 func runStatefulSetController(
-	ctx context.Context, clientset kubernetes.Interface, targetNamespace string,
+	ctx context.Context, clientset kubernetes.Interface, factory kubeinformers.SharedInformerFactory, targetNamespace string,
 	informers []types.StatusInformer, resourceStateCh chan<- types.ResourceState,
 ) {
-	listwatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.AppsV1().StatefulSets(targetNamespace).List(context.TODO(), options)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.AppsV1().StatefulSets(targetNamespace).Watch(context.TODO(), options)
-		},
-	}
-	informer := cache.NewSharedInformer(
-		listwatch,
-		&appsv1.StatefulSet{},
-		time.Minute,
-	)
+	statefulSets := factory.Apps().V1().StatefulSets()
+
+	pods := factory.Core().V1().Pods()
 
 	eventHandler := &statefulSetEventHandler{
-		informers:       informers,
-		resourceStateCh: resourceStateCh,
-		clientset:       clientset,
-		targetNamespace: targetNamespace,
+		informers:         informers,
+		resourceStateCh:   resourceStateCh,
+		statefulSetLister: statefulSets.Lister(),
+		podLister:         pods.Lister(),
 	}
 
-	runInformer(ctx, informer, eventHandler)
+	// the state, reason and message of statefulsets are derived from their pods, so they are recalculated when their pods change
+	runInformer(ctx, factory, statefulSets.Informer(), eventHandler, informerDependency{
+		informer: pods.Informer(),
+		onChange: eventHandler.podChanged,
+	})
 	return
 }
 
@@ -66,15 +58,19 @@ func (h *statefulSetEventHandler) ObjectCreated(obj interface{}) {
 	if _, ok := h.getInformer(r); !ok {
 		return
 	}
-	h.resourceStateCh <- makeStatefulSetResourceState(r, h.calculateStatefulSetState(h.clientset, h.targetNamespace, r))
+	h.resourceStateCh <- addPodDiagnostics(h.podLister, makeStatefulSetResourceState(r, h.calculateStatefulSetState(r)), r.Spec.Selector)
 }
 
 func (h *statefulSetEventHandler) ObjectUpdated(obj interface{}) {
 	r := h.cast(obj)
 	if _, ok := h.getInformer(r); !ok {
+		if r != nil && hasSelectorStatusInformer(h.informers, r.Namespace) {
+			// the labels may have changed so that the resource no longer matches a selector
+			h.resourceStateCh <- makeStatefulSetResourceState(r, types.StateMissing)
+		}
 		return
 	}
-	h.resourceStateCh <- makeStatefulSetResourceState(r, h.calculateStatefulSetState(h.clientset, h.targetNamespace, r))
+	h.resourceStateCh <- addPodDiagnostics(h.podLister, makeStatefulSetResourceState(r, h.calculateStatefulSetState(r)), r.Spec.Selector)
 }
 
 func (h *statefulSetEventHandler) ObjectDeleted(obj interface{}) {
@@ -85,6 +81,20 @@ func (h *statefulSetEventHandler) ObjectDeleted(obj interface{}) {
 	h.resourceStateCh <- makeStatefulSetResourceState(r, types.StateMissing)
 }
 
+// podChanged recalculates the states of the statefulsets that select the pod.
+func (h *statefulSetEventHandler) podChanged(namespace string, name string) {
+	pod, _ := h.podLister.Pods(namespace).Get(name)
+	statefulSets, err := h.statefulSetLister.StatefulSets(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, r := range statefulSets {
+		if _, ok := h.getInformer(r); ok && selectsPod(r.Spec.Selector, pod) {
+			h.ObjectUpdated(r)
+		}
+	}
+}
+
 func (h *statefulSetEventHandler) cast(obj interface{}) *appsv1.StatefulSet {
 	r, _ := obj.(*appsv1.StatefulSet)
 	return r
@@ -93,7 +103,7 @@ func (h *statefulSetEventHandler) cast(obj interface{}) *appsv1.StatefulSet {
 func (h *statefulSetEventHandler) getInformer(r *appsv1.StatefulSet) (types.StatusInformer, bool) {
 	if r != nil {
 		for _, informer := range h.informers {
-			if r.Namespace == informer.Namespace && r.Name == informer.Name {
+			if informer.Matches(r.Namespace, r.Name, r.Labels) {
 				return informer, true
 			}
 		}
@@ -102,15 +112,23 @@ func (h *statefulSetEventHandler) getInformer(r *appsv1.StatefulSet) (types.Stat
 }
 
 func makeStatefulSetResourceState(r *appsv1.StatefulSet, state types.State) types.ResourceState {
+	readyReplicas := r.Status.ReadyReplicas
+	desiredReplicas := int32(1)
+	if r.Spec.Replicas != nil {
+		desiredReplicas = *r.Spec.Replicas
+	}
 	return types.ResourceState{
-		Kind:      StatefulSetResourceKind,
-		Name:      r.Name,
-		Namespace: r.Namespace,
-		State:     state,
+		Kind:            StatefulSetResourceKind,
+		Name:            r.Name,
+		Namespace:       r.Namespace,
+		Labels:          r.Labels,
+		State:           state,
+		ReadyReplicas:   &readyReplicas,
+		DesiredReplicas: &desiredReplicas,
 	}
 }
 
-func (h *statefulSetEventHandler) calculateStatefulSetState(clientset kubernetes.Interface, targetNamespace string, r *appsv1.StatefulSet) types.State {
+func (h *statefulSetEventHandler) calculateStatefulSetState(r *appsv1.StatefulSet) types.State {
 	if r == nil {
 		return types.StateMissing
 	}
@@ -119,9 +137,7 @@ func (h *statefulSetEventHandler) calculateStatefulSetState(clientset kubernetes
 		return types.StateUpdating
 	}
 
-	listOptions := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(r.Spec.Selector.MatchLabels).String()}
-
-	pods, err := clientset.CoreV1().Pods(targetNamespace).List(context.TODO(), listOptions)
+	pods, err := h.podLister.Pods(r.Namespace).List(labels.SelectorFromSet(r.Spec.Selector.MatchLabels))
 	if err != nil {
 		log.Printf("failed to get statefulset pod list: %s", err)
 		return types.StateUnavailable
@@ -129,7 +145,7 @@ func (h *statefulSetEventHandler) calculateStatefulSetState(clientset kubernetes
 
 	// If the pod version labels are not all the same, then the statefulset is updating.
 	currentVersion := ""
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		validOwner := false
 		for _, owner := range pod.ObjectMeta.OwnerReferences {
 			if owner.Kind == StatefulSetOwnerKind && owner.Name == r.ObjectMeta.Name {
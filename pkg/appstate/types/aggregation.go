@@ -0,0 +1,185 @@
+package types
+
+import (
+	"fmt"
+)
+
+type InformerRole string
+
+const (
+	// InformerRoleCritical resources determine the app state, this is the default
+	InformerRoleCritical InformerRole = "critical"
+	// InformerRoleOptional resources only degrade the app when too few of them are ready
+	InformerRoleOptional InformerRole = "optional"
+	// InformerRoleWeighted resources only degrade the app when too little of their total weight is ready
+	InformerRoleWeighted InformerRole = "weighted"
+
+	DefaultOptionalReadyPercent = 0
+	DefaultWeightedReadyPercent = 100
+)
+
+func (r InformerRole) IsValid() bool {
+	switch r {
+	case "", InformerRoleCritical, InformerRoleOptional, InformerRoleWeighted:
+		return true
+	}
+	return false
+}
+
+// AggregationPolicy determines how the states of the optional and weighted resources are aggregated into the app state.
+// The app is ready if all critical resources are ready and the required percentage of the optional resources
+// and of the weight of the weighted resources is ready. Otherwise, optional and weighted resources degrade the app,
+// but never make it unavailable.
+type AggregationPolicy struct {
+	// OptionalReadyPercent is the percentage of the optional resources that must be ready, defaults to 0
+	OptionalReadyPercent *int `yaml:"optionalReadyPercent,omitempty" json:"optionalReadyPercent,omitempty"`
+	// WeightedReadyPercent is the percentage of the total weight of the weighted resources that must be ready, defaults to 100
+	WeightedReadyPercent *int `yaml:"weightedReadyPercent,omitempty" json:"weightedReadyPercent,omitempty"`
+}
+
+func (p *AggregationPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.OptionalReadyPercent != nil && (*p.OptionalReadyPercent < 0 || *p.OptionalReadyPercent > 100) {
+		return fmt.Errorf("optionalReadyPercent must be between 0 and 100, got %d", *p.OptionalReadyPercent)
+	}
+	if p.WeightedReadyPercent != nil && (*p.WeightedReadyPercent < 0 || *p.WeightedReadyPercent > 100) {
+		return fmt.Errorf("weightedReadyPercent must be between 0 and 100, got %d", *p.WeightedReadyPercent)
+	}
+	return nil
+}
+
+func (p *AggregationPolicy) withDefaults() AggregationPolicy {
+	optionalReadyPercent := DefaultOptionalReadyPercent
+	weightedReadyPercent := DefaultWeightedReadyPercent
+	if p != nil && p.OptionalReadyPercent != nil {
+		optionalReadyPercent = *p.OptionalReadyPercent
+	}
+	if p != nil && p.WeightedReadyPercent != nil {
+		weightedReadyPercent = *p.WeightedReadyPercent
+	}
+	return AggregationPolicy{
+		OptionalReadyPercent: &optionalReadyPercent,
+		WeightedReadyPercent: &weightedReadyPercent,
+	}
+}
+
+// StateAggregation explains how the app state was aggregated from the resource states.
+type StateAggregation struct {
+	// Policy is the policy that applied, including the defaults
+	Policy        AggregationPolicy `json:"policy"`
+	CriticalState State             `json:"criticalState,omitempty"`
+	Optional      *AggregationGroup `json:"optional,omitempty"`
+	Weighted      *AggregationGroup `json:"weighted,omitempty"`
+	// Reason is set when the optional or weighted resources degrade the app
+	Reason string `json:"reason,omitempty"`
+}
+
+// AggregationGroup counts the ready resources of a role. For weighted resources, Ready and Total are weights.
+type AggregationGroup struct {
+	Ready           int `json:"ready"`
+	Total           int `json:"total"`
+	ReadyPercent    int `json:"readyPercent"`
+	RequiredPercent int `json:"requiredPercent"`
+}
+
+func (g *AggregationGroup) add(state State, weight int) {
+	g.Total += weight
+	if state == StateReady {
+		g.Ready += weight
+	}
+	g.ReadyPercent = g.Ready * 100 / g.Total
+}
+
+func (g *AggregationGroup) isSatisfied() bool {
+	return g.Ready*100 >= g.RequiredPercent*g.Total
+}
+
+// AggregateState returns the app state for the resource states of the informers. If all informers are critical
+// and there is no policy, this is the minimum of the resource states and no aggregation is returned.
+func AggregateState(resourceStates []ResourceState, informers []StatusInformer, policy *AggregationPolicy) (State, *StateAggregation) {
+	if len(resourceStates) == 0 {
+		return StateMissing, nil
+	}
+
+	informersByResource := map[string]StatusInformer{}
+	selectorInformers := []StatusInformer{}
+	hasRoles := false
+	for _, informer := range informers {
+		if informer.Selector != nil {
+			selectorInformers = append(selectorInformers, informer)
+		} else {
+			informersByResource[resourceKey(informer.Kind, informer.Namespace, informer.Name)] = informer
+		}
+		if informer.Role != "" && informer.Role != InformerRoleCritical {
+			hasRoles = true
+		}
+	}
+	if policy == nil && !hasRoles {
+		return GetState(resourceStates), nil
+	}
+
+	p := policy.withDefaults()
+	aggregation := &StateAggregation{
+		Policy: p,
+	}
+	optional := &AggregationGroup{RequiredPercent: *p.OptionalReadyPercent}
+	weighted := &AggregationGroup{RequiredPercent: *p.WeightedReadyPercent}
+
+	critical := []ResourceState{}
+	for _, resourceState := range resourceStates {
+		informer, ok := informersByResource[resourceKey(resourceState.Kind, resourceState.Namespace, resourceState.Name)]
+		if !ok {
+			informer = getSelectorInformer(selectorInformers, resourceState)
+		}
+		switch informer.Role {
+		case InformerRoleOptional:
+			optional.add(resourceState.State, 1)
+		case InformerRoleWeighted:
+			weighted.add(resourceState.State, informer.GetWeight())
+		default:
+			critical = append(critical, resourceState)
+		}
+	}
+
+	state := StateReady
+	if len(critical) > 0 {
+		state = GetState(critical)
+		aggregation.CriticalState = state
+	}
+	if optional.Total > 0 {
+		aggregation.Optional = optional
+		if !optional.isSatisfied() {
+			state = MinState(state, StateDegraded)
+			aggregation.Reason = fmt.Sprintf("%d of %d optional resources are ready, %d%% required", optional.Ready, optional.Total, optional.RequiredPercent)
+		}
+	}
+	if weighted.Total > 0 {
+		aggregation.Weighted = weighted
+		if !weighted.isSatisfied() {
+			state = MinState(state, StateDegraded)
+			reason := fmt.Sprintf("%d%% of the weighted resources are ready, %d%% required", weighted.ReadyPercent, weighted.RequiredPercent)
+			if aggregation.Reason != "" {
+				reason = aggregation.Reason + "; " + reason
+			}
+			aggregation.Reason = reason
+		}
+	}
+
+	return state, aggregation
+}
+
+// getSelectorInformer returns the first label selector informer that matches the labels of the resource.
+func getSelectorInformer(informers []StatusInformer, resourceState ResourceState) StatusInformer {
+	for _, informer := range informers {
+		if informer.Kind == resourceState.Kind && informer.Matches(resourceState.Namespace, resourceState.Name, resourceState.Labels) {
+			return informer
+		}
+	}
+	return StatusInformer{}
+}
+
+func resourceKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, kind, name)
+}
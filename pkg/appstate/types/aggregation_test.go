@@ -0,0 +1,147 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestAggregateState(t *testing.T) {
+	percent := func(p int) *int { return &p }
+
+	informers := []StatusInformer{
+		{Kind: "deployment", Namespace: "default", Name: "api"},
+		{Kind: "deployment", Namespace: "default", Name: "search", Role: InformerRoleOptional},
+		{Kind: "deployment", Namespace: "default", Name: "reports", Role: InformerRoleOptional},
+		{Kind: "statefulset", Namespace: "default", Name: "cache-a", Role: InformerRoleWeighted, Weight: 3},
+		{Kind: "statefulset", Namespace: "default", Name: "cache-b", Role: InformerRoleWeighted},
+	}
+	resourceStates := func(api, search, reports, cacheA, cacheB State) []ResourceState {
+		return []ResourceState{
+			{Kind: "deployment", Namespace: "default", Name: "api", State: api},
+			{Kind: "deployment", Namespace: "default", Name: "search", State: search},
+			{Kind: "deployment", Namespace: "default", Name: "reports", State: reports},
+			{Kind: "statefulset", Namespace: "default", Name: "cache-a", State: cacheA},
+			{Kind: "statefulset", Namespace: "default", Name: "cache-b", State: cacheB},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		resourceStates  []ResourceState
+		informers       []StatusInformer
+		policy          *AggregationPolicy
+		wantState       State
+		wantAggregation bool
+		wantReason      string
+	}{
+		{
+			name:           "no roles or policy is the least ready state",
+			resourceStates: resourceStates(StateReady, StateUnavailable, StateReady, StateReady, StateReady),
+			informers:      []StatusInformer{{Kind: "deployment", Namespace: "default", Name: "api"}},
+			wantState:      StateUnavailable,
+		},
+		{
+			name:      "no resources",
+			informers: informers,
+			wantState: StateMissing,
+		},
+		{
+			name:            "optional resources do not degrade the app by default",
+			resourceStates:  resourceStates(StateReady, StateUnavailable, StateUnavailable, StateReady, StateReady),
+			informers:       informers,
+			wantState:       StateReady,
+			wantAggregation: true,
+		},
+		{
+			name:            "enough optional resources are ready",
+			resourceStates:  resourceStates(StateReady, StateReady, StateUnavailable, StateReady, StateReady),
+			informers:       informers,
+			policy:          &AggregationPolicy{OptionalReadyPercent: percent(50)},
+			wantState:       StateReady,
+			wantAggregation: true,
+		},
+		{
+			name:            "too few optional resources are ready",
+			resourceStates:  resourceStates(StateReady, StateUpdating, StateUnavailable, StateReady, StateReady),
+			informers:       informers,
+			policy:          &AggregationPolicy{OptionalReadyPercent: percent(50)},
+			wantState:       StateDegraded,
+			wantAggregation: true,
+			wantReason:      "0 of 2 optional resources are ready, 50% required",
+		},
+		{
+			name:            "critical resources determine the state",
+			resourceStates:  resourceStates(StateUnavailable, StateReady, StateReady, StateReady, StateReady),
+			informers:       informers,
+			policy:          &AggregationPolicy{OptionalReadyPercent: percent(50)},
+			wantState:       StateUnavailable,
+			wantAggregation: true,
+		},
+		{
+			name:            "weighted resources require all weight by default",
+			resourceStates:  resourceStates(StateReady, StateReady, StateReady, StateReady, StateUnavailable),
+			informers:       informers,
+			wantState:       StateDegraded,
+			wantAggregation: true,
+			wantReason:      "75% of the weighted resources are ready, 100% required",
+		},
+		{
+			name:            "enough weight is ready",
+			resourceStates:  resourceStates(StateReady, StateReady, StateReady, StateReady, StateUnavailable),
+			informers:       informers,
+			policy:          &AggregationPolicy{WeightedReadyPercent: percent(75)},
+			wantState:       StateReady,
+			wantAggregation: true,
+		},
+		{
+			name:            "not enough weight is ready",
+			resourceStates:  resourceStates(StateReady, StateReady, StateReady, StateUnavailable, StateReady),
+			informers:       informers,
+			policy:          &AggregationPolicy{WeightedReadyPercent: percent(75)},
+			wantState:       StateDegraded,
+			wantAggregation: true,
+			wantReason:      "25% of the weighted resources are ready, 75% required",
+		},
+		{
+			name: "resources have the role of the selector informer that matched their labels",
+			resourceStates: []ResourceState{
+				{Kind: "deployment", Namespace: "default", Name: "docs", State: StateUnavailable, Labels: map[string]string{"tier": "docs"}},
+				{Kind: "deployment", Namespace: "default", Name: "web", State: StateUnavailable, Labels: map[string]string{"tier": "web"}},
+			},
+			informers: []StatusInformer{
+				{Kind: "deployment", Namespace: "default", Selector: labels.SelectorFromSet(labels.Set{"tier": "docs"}), Role: InformerRoleOptional},
+				{Kind: "deployment", Namespace: "default", Selector: labels.SelectorFromSet(labels.Set{"tier": "web"}), Role: InformerRoleWeighted, Weight: 2},
+			},
+			wantState:       StateDegraded,
+			wantAggregation: true,
+			wantReason:      "0% of the weighted resources are ready, 100% required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			state, aggregation := AggregateState(tt.resourceStates, tt.informers, tt.policy)
+			req.Equal(tt.wantState, state)
+			if !tt.wantAggregation {
+				req.Nil(aggregation)
+				return
+			}
+			req.NotNil(aggregation)
+			req.Equal(tt.wantReason, aggregation.Reason)
+			req.NotNil(aggregation.Policy.OptionalReadyPercent)
+			req.NotNil(aggregation.Policy.WeightedReadyPercent)
+		})
+	}
+}
+
+func TestAggregationPolicy_Validate(t *testing.T) {
+	percent := func(p int) *int { return &p }
+
+	require.NoError(t, (*AggregationPolicy)(nil).Validate())
+	require.NoError(t, (&AggregationPolicy{OptionalReadyPercent: percent(50), WeightedReadyPercent: percent(100)}).Validate())
+	require.Error(t, (&AggregationPolicy{OptionalReadyPercent: percent(101)}).Validate())
+	require.Error(t, (&AggregationPolicy{WeightedReadyPercent: percent(-1)}).Validate())
+}
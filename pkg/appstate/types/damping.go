@@ -0,0 +1,34 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// DampingPolicy is how long a resource must stay in a state before the change to that state is reported, by state.
+// For example, {degraded: 30s, ready: 10s} reports a ready resource as degraded once it has been degraded for 30 seconds,
+// and as ready again once it has been ready for 10 seconds. Changes to states without a duration are reported immediately.
+type DampingPolicy map[State]string
+
+func (p DampingPolicy) Validate() error {
+	for state, duration := range p {
+		if !state.IsValid() {
+			return fmt.Errorf("invalid damping state %q", state)
+		}
+		if d, err := time.ParseDuration(duration); err != nil || d < 0 {
+			return fmt.Errorf("invalid damping duration %q for state %s", duration, state)
+		}
+	}
+	return nil
+}
+
+// Durations returns the parsed durations of the policy, skipping any that are invalid.
+func (p DampingPolicy) Durations() map[State]time.Duration {
+	durations := map[State]time.Duration{}
+	for state, duration := range p {
+		if d, err := time.ParseDuration(duration); err == nil && d > 0 {
+			durations[state] = d
+		}
+	}
+	return durations
+}
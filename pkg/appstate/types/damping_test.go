@@ -0,0 +1,20 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDampingPolicy(t *testing.T) {
+	req := require.New(t)
+
+	policy := DampingPolicy{StateDegraded: "30s", StateReady: "1m", StateUnavailable: "0s"}
+	req.NoError(policy.Validate())
+	req.Equal(map[State]time.Duration{StateDegraded: 30 * time.Second, StateReady: time.Minute}, policy.Durations())
+
+	req.Error(DampingPolicy{"flapping": "30s"}.Validate())
+	req.Error(DampingPolicy{StateDegraded: "30"}.Validate())
+	req.Error(DampingPolicy{StateDegraded: "-1s"}.Validate())
+}
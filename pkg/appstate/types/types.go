@@ -2,8 +2,14 @@ package types
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"regexp"
+	"strings"
 	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 var (
@@ -16,10 +22,20 @@ var (
 	StatusInformerRegexp = regexp.MustCompile(`^(?:([^\/]+)\/)?([^\/]+)\/([^\/]+)$`)
 )
 
+const (
+	// StatusInformerSelectorPrefix replaces the name of status informers that match resources by label selector,
+	// e.g. "deployment/selector:app.kubernetes.io/part-of=myapp"
+	StatusInformerSelectorPrefix = "selector:"
+)
+
 type AppInformersArgs struct {
-	AppSlug   string
-	Sequence  int64
-	Informers []StatusInformerString
+	AppSlug     string
+	Sequence    int64
+	Informers   []StatusInformerConfig
+	Aggregation *AggregationPolicy
+	Damping     DampingPolicy
+	// CertificateExpiryWarningDays is how many days before their expiry TLS certificates degrade the resources that serve them
+	CertificateExpiryWarningDays int
 }
 
 type StatusInformerString string
@@ -28,9 +44,280 @@ type StatusInformer struct {
 	Kind      string
 	Name      string
 	Namespace string
+	// Selector is set instead of the name for informers that match all resources of the kind with matching labels
+	Selector labels.Selector
+	// APIVersion is set for informers of arbitrary kinds (e.g. custom resources) that are watched with the dynamic client
+	APIVersion string
+	// StateRules and DefaultState determine the state of resources watched with the dynamic client
+	StateRules   []StateRule
+	DefaultState State
+	// Role and Weight determine how the state of the resource is aggregated into the app state
+	Role   InformerRole
+	Weight int
+	// HealthCheck configures the synthetic checks of the httpcheck and tcpcheck kinds
+	HealthCheck *HealthCheck
+	// CertificateExpiryWarning is how long before their expiry the TLS certificates of the resource degrade it
+	CertificateExpiryWarning time.Duration
+}
+
+// Matches returns true if the informer matches the resource, by name or by label selector.
+func (i StatusInformer) Matches(namespace, name string, resourceLabels map[string]string) bool {
+	if namespace != i.Namespace {
+		return false
+	}
+	if i.Selector != nil {
+		return i.Selector.Matches(labels.Set(resourceLabels))
+	}
+	return name == i.Name
+}
+
+// GetWeight returns the weight of a weighted informer, which defaults to 1.
+func (i StatusInformer) GetWeight() int {
+	if i.Weight <= 0 {
+		return 1
+	}
+	return i.Weight
+}
+
+// GetCertificateExpiryWarning returns the certificate expiry warning window of the informer, which defaults to 30 days.
+func (i StatusInformer) GetCertificateExpiryWarning() time.Duration {
+	if i.CertificateExpiryWarning <= 0 {
+		return DefaultCertificateExpiryWarningDays * 24 * time.Hour
+	}
+	return i.CertificateExpiryWarning
+}
+
+// StatusInformerConfig is a status informer from the config. It is either a "[namespace/]kind/name" string,
+// or an object that also sets the api version and the state rules for arbitrary kinds, or the role of the resource:
+//
+//	statusInformers:
+//	- deployment/web
+//	- informer: postgres/main
+//	  apiVersion: acid.zalan.do/v1
+//	  stateRules:
+//	  - condition: {type: Ready, status: "True"}
+//	    state: ready
+//	  - jsonPath: "{.status.phase}"
+//	    value: Creating
+//	    state: updating
+//	  defaultState: unavailable
+//	- informer: deployment/reporting
+//	  role: weighted
+//	  weight: 2
+//	- informer: httpcheck/api
+//	  healthCheck:
+//	    url: http://api:8080/healthz
+//	    bodyMatch: '"status":\s*"ok"'
+type StatusInformerConfig struct {
+	Informer     StatusInformerString `yaml:"informer" json:"informer"`
+	APIVersion   string               `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	StateRules   []StateRule          `yaml:"stateRules,omitempty" json:"stateRules,omitempty"`
+	DefaultState State                `yaml:"defaultState,omitempty" json:"defaultState,omitempty"`
+	Role         InformerRole         `yaml:"role,omitempty" json:"role,omitempty"`
+	Weight       int                  `yaml:"weight,omitempty" json:"weight,omitempty"`
+	HealthCheck  *HealthCheck         `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
+}
+
+// HealthCheck is a synthetic check of an endpoint. The httpcheck kind requests the URL, the tcpcheck kind connects to the address.
+type HealthCheck struct {
+	URL    string `yaml:"url,omitempty" json:"url,omitempty"`
+	Method string `yaml:"method,omitempty" json:"method,omitempty"`
+	// ExpectedStatus is the expected status code of the response, any 2xx or 3xx status code is accepted by default
+	ExpectedStatus int `yaml:"expectedStatus,omitempty" json:"expectedStatus,omitempty"`
+	// BodyMatch is a regular expression the response body must match
+	BodyMatch          string `yaml:"bodyMatch,omitempty" json:"bodyMatch,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+	// Address is the "host:port" to connect to
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+	// Interval and Timeout are durations such as "30s", they default to 30 and 5 seconds
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+const (
+	DefaultCertificateExpiryWarningDays = 30
+
+	DefaultHealthCheckInterval = 30 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+
+	// the kinds of the informers that run health checks, their plural names are accepted as well
+	httpCheckKind = "httpcheck"
+	tcpCheckKind  = "tcpcheck"
+)
+
+func (c HealthCheck) Validate() error {
+	if (c.URL == "") == (c.Address == "") {
+		return errors.New("health check must specify exactly one of url or address")
+	}
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("health check url %q must be an http or https url", c.URL)
+		}
+	}
+	if c.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("health check address %q must be in the host:port format", c.Address)
+		}
+	}
+	if c.ExpectedStatus != 0 && (c.ExpectedStatus < 100 || c.ExpectedStatus > 599) {
+		return fmt.Errorf("invalid health check expected status %d", c.ExpectedStatus)
+	}
+	if c.BodyMatch != "" {
+		if _, err := regexp.Compile(c.BodyMatch); err != nil {
+			return fmt.Errorf("invalid health check body match: %w", err)
+		}
+	}
+	if _, err := c.GetInterval(); err != nil {
+		return err
+	}
+	if _, err := c.GetTimeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c HealthCheck) GetInterval() (time.Duration, error) {
+	return parseHealthCheckDuration(c.Interval, DefaultHealthCheckInterval, "interval")
+}
+
+func (c HealthCheck) GetTimeout() (time.Duration, error) {
+	return parseHealthCheckDuration(c.Timeout, DefaultHealthCheckTimeout, "timeout")
+}
+
+func parseHealthCheckDuration(value string, defaultValue time.Duration, name string) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid health check %s %q", name, value)
+	}
+	return d, nil
+}
+
+// StateRule maps a resource to a state. Exactly one of Condition or JSONPath must be set.
+type StateRule struct {
+	// Condition matches an entry of .status.conditions by type and status
+	Condition *ConditionMatch `yaml:"condition,omitempty" json:"condition,omitempty"`
+	// JSONPath matches when the result of the expression (e.g. "{.status.phase}") equals Value
+	JSONPath string `yaml:"jsonPath,omitempty" json:"jsonPath,omitempty"`
+	Value    string `yaml:"value,omitempty" json:"value,omitempty"`
+	State    State  `yaml:"state" json:"state"`
+}
+
+type ConditionMatch struct {
+	Type   string `yaml:"type" json:"type"`
+	Status string `yaml:"status" json:"status"`
+}
+
+func (c *StatusInformerConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var informer string
+	if err := unmarshal(&informer); err == nil {
+		*c = StatusInformerConfig{Informer: StatusInformerString(informer)}
+		return nil
+	}
+
+	type statusInformerConfig StatusInformerConfig
+	var config statusInformerConfig
+	if err := unmarshal(&config); err != nil {
+		return err
+	}
+	*c = StatusInformerConfig(config)
+	return nil
+}
+
+func NewStatusInformerConfigs(informers []StatusInformerString) []StatusInformerConfig {
+	if informers == nil {
+		return nil
+	}
+	configs := []StatusInformerConfig{}
+	for _, informer := range informers {
+		configs = append(configs, StatusInformerConfig{Informer: informer})
+	}
+	return configs
+}
+
+func (c StatusInformerConfig) Parse() (StatusInformer, error) {
+	i, err := c.Informer.Parse()
+	if err != nil {
+		return i, err
+	}
+
+	if !c.Role.IsValid() {
+		return i, fmt.Errorf("invalid role %q", c.Role)
+	}
+	if c.Weight < 0 || c.Weight > 0 && c.Role != InformerRoleWeighted {
+		return i, errors.New("weight must be a positive number and requires the weighted role")
+	}
+	i.Role = c.Role
+	i.Weight = c.Weight
+
+	if err := validateHealthCheckKind(i, c.HealthCheck); err != nil {
+		return i, err
+	}
+	if c.HealthCheck != nil {
+		if c.APIVersion != "" {
+			return i, errors.New("health checks cannot be combined with an api version")
+		}
+		if err := c.HealthCheck.Validate(); err != nil {
+			return i, err
+		}
+		i.HealthCheck = c.HealthCheck
+	}
+
+	if c.APIVersion == "" {
+		if len(c.StateRules) > 0 || c.DefaultState != "" {
+			return i, errors.New("state rules require an api version")
+		}
+		return i, nil
+	}
+
+	for _, rule := range c.StateRules {
+		if (rule.Condition == nil) == (rule.JSONPath == "") {
+			return i, errors.New("state rule must specify exactly one of condition or jsonPath")
+		}
+		if !rule.State.IsValid() {
+			return i, fmt.Errorf("state rule has invalid state %q", rule.State)
+		}
+	}
+	if c.DefaultState != "" && !c.DefaultState.IsValid() {
+		return i, fmt.Errorf("invalid default state %q", c.DefaultState)
+	}
+
+	i.APIVersion = c.APIVersion
+	i.StateRules = c.StateRules
+	i.DefaultState = c.DefaultState
+	return i, nil
+}
+
+// validateHealthCheckKind checks that health checks are only set for, and set with the field required by, the health check kinds.
+func validateHealthCheckKind(i StatusInformer, healthCheck *HealthCheck) error {
+	kind := strings.TrimSuffix(strings.ToLower(i.Kind), "s")
+	isHealthCheckKind := kind == httpCheckKind || kind == tcpCheckKind
+
+	switch {
+	case healthCheck == nil:
+		if isHealthCheckKind {
+			return fmt.Errorf("%s informers require a health check", kind)
+		}
+	case !isHealthCheckKind:
+		return fmt.Errorf("health checks require the %s or %s kind", httpCheckKind, tcpCheckKind)
+	case i.Selector != nil:
+		return fmt.Errorf("%s informers require a name instead of a selector", kind)
+	case kind == httpCheckKind && healthCheck.URL == "":
+		return fmt.Errorf("%s informers require a health check url", kind)
+	case kind == tcpCheckKind && healthCheck.Address == "":
+		return fmt.Errorf("%s informers require a health check address", kind)
+	}
+	return nil
 }
 
 func (s StatusInformerString) Parse() (i StatusInformer, err error) {
+	if idx := strings.Index(string(s), "/"+StatusInformerSelectorPrefix); idx != -1 {
+		return s.parseSelector(idx)
+	}
+
 	matches := StatusInformerRegexp.FindStringSubmatch(string(s))
 	if len(matches) != 4 {
 		err = errors.New("status informer format string incorrect")
@@ -42,25 +329,100 @@ func (s StatusInformerString) Parse() (i StatusInformer, err error) {
 	return
 }
 
+// parseSelector parses a "[namespace/]kind/selector:<label selector>" informer, idx is the index of "/selector:".
+func (s StatusInformerString) parseSelector(idx int) (i StatusInformer, err error) {
+	selector, err := labels.Parse(string(s)[idx+len(StatusInformerSelectorPrefix)+1:])
+	if err != nil {
+		err = fmt.Errorf("status informer label selector invalid: %w", err)
+		return
+	}
+	if selector.Empty() {
+		err = errors.New("status informer label selector is empty")
+		return
+	}
+
+	parts := strings.Split(string(s)[:idx], "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		i.Kind = parts[0]
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		i.Namespace = parts[0]
+		i.Kind = parts[1]
+	default:
+		err = errors.New("status informer format string incorrect")
+		return
+	}
+	i.Selector = selector
+	return
+}
+
 type AppStatus struct {
 	AppSlug        string         `json:"appSlug"`
 	ResourceStates ResourceStates `json:"resourceStates" hash:"set"`
 	UpdatedAt      time.Time      `json:"updatedAt" hash:"ignore"`
 	State          State          `json:"state"`
 	Sequence       int64          `json:"sequence"`
+	// Aggregation is set when the app state is aggregated using informer roles or an aggregation policy
+	Aggregation *StateAggregation `json:"aggregation,omitempty"`
 }
 
 type ResourceStates []ResourceState
 
+// AppStatusTransition records a change of the app state and the resources that changed state with it
+type AppStatusTransition struct {
+	Timestamp        time.Time                 `json:"timestamp"`
+	PreviousState    State                     `json:"previousState,omitempty"`
+	State            State                     `json:"state"`
+	Sequence         int64                     `json:"sequence"`
+	ChangedResources []ResourceStateTransition `json:"changedResources,omitempty"`
+}
+
+type ResourceStateTransition struct {
+	Kind          string `json:"kind"`
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace"`
+	PreviousState State  `json:"previousState,omitempty"`
+	State         State  `json:"state"`
+	Reason        string `json:"reason,omitempty"`
+	Message       string `json:"message,omitempty"`
+}
+
 type ResourceState struct {
 	Kind      string `json:"kind"`
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	State     State  `json:"state"`
+	// Reason and Message explain why a resource is not ready, e.g. CrashLoopBackOff, derived from its pods
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// ReadyReplicas and DesiredReplicas are set for workloads
+	ReadyReplicas   *int32 `json:"readyReplicas,omitempty"`
+	DesiredReplicas *int32 `json:"desiredReplicas,omitempty"`
+	// Certificates are set for ingresses and secrets that serve TLS certificates
+	Certificates []CertificateStatus `json:"certificates,omitempty"`
+	// Labels are the labels of the resource, used to find the label selector informer that matched it
+	Labels map[string]string `json:"-" hash:"ignore"`
+}
+
+// CertificateStatus is the expiry of a TLS certificate from a secret
+type CertificateStatus struct {
+	SecretName string    `json:"secretName"`
+	DNSNames   []string  `json:"dnsNames,omitempty"`
+	NotAfter   time.Time `json:"notAfter"`
+	// DaysToExpiry is the number of full days until the certificate expires, it is negative once the certificate has expired
+	DaysToExpiry int `json:"daysToExpiry"`
 }
 
 type State string
 
+func (s State) IsValid() bool {
+	switch s {
+	case StateReady, StateUpdating, StateDegraded, StateUnavailable, StateMissing:
+		return true
+	}
+	return false
+}
+
 func GetState(resourceStates []ResourceState) State {
 	if len(resourceStates) == 0 {
 		return StateMissing
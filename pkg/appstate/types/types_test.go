@@ -2,6 +2,9 @@ package types
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 )
 
 func TestMinState(t *testing.T) {
@@ -196,3 +199,227 @@ func TestGetState(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusInformerConfig_UnmarshalYAML(t *testing.T) {
+	req := require.New(t)
+
+	var informers []StatusInformerConfig
+	err := yaml.Unmarshal([]byte(`
+- deployment/web
+- informer: default/postgres/main
+  apiVersion: acid.zalan.do/v1
+  stateRules:
+  - condition:
+      type: Ready
+      status: "True"
+    state: ready
+  - jsonPath: "{.status.phase}"
+    value: Creating
+    state: updating
+  defaultState: unavailable
+`), &informers)
+	req.NoError(err)
+
+	req.Equal([]StatusInformerConfig{
+		{Informer: "deployment/web"},
+		{
+			Informer:   "default/postgres/main",
+			APIVersion: "acid.zalan.do/v1",
+			StateRules: []StateRule{
+				{Condition: &ConditionMatch{Type: "Ready", Status: "True"}, State: StateReady},
+				{JSONPath: "{.status.phase}", Value: "Creating", State: StateUpdating},
+			},
+			DefaultState: StateUnavailable,
+		},
+	}, informers)
+
+	informer, err := informers[1].Parse()
+	req.NoError(err)
+	req.Equal("default", informer.Namespace)
+	req.Equal("postgres", informer.Kind)
+	req.Equal("main", informer.Name)
+	req.Equal("acid.zalan.do/v1", informer.APIVersion)
+	req.Len(informer.StateRules, 2)
+}
+
+func TestStatusInformerConfig_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  StatusInformerConfig
+		wantErr bool
+	}{
+		{
+			name:   "string informer",
+			config: StatusInformerConfig{Informer: "deployment/web"},
+		},
+		{
+			name:    "rules without api version",
+			config:  StatusInformerConfig{Informer: "postgres/main", StateRules: []StateRule{{JSONPath: ".status.phase", State: StateReady}}},
+			wantErr: true,
+		},
+		{
+			name:    "rule with condition and jsonpath",
+			config:  StatusInformerConfig{Informer: "postgres/main", APIVersion: "v1", StateRules: []StateRule{{Condition: &ConditionMatch{Type: "Ready"}, JSONPath: ".status.phase", State: StateReady}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid state",
+			config:  StatusInformerConfig{Informer: "postgres/main", APIVersion: "v1", StateRules: []StateRule{{JSONPath: ".status.phase", State: "ok"}}},
+			wantErr: true,
+		},
+		{
+			name:   "weighted role",
+			config: StatusInformerConfig{Informer: "deployment/web", Role: InformerRoleWeighted, Weight: 3},
+		},
+		{
+			name:    "invalid role",
+			config:  StatusInformerConfig{Informer: "deployment/web", Role: "nice-to-have"},
+			wantErr: true,
+		},
+		{
+			name:    "weight without weighted role",
+			config:  StatusInformerConfig{Informer: "deployment/web", Role: InformerRoleOptional, Weight: 3},
+			wantErr: true,
+		},
+		{
+			name:   "http check",
+			config: StatusInformerConfig{Informer: "httpcheck/api", HealthCheck: &HealthCheck{URL: "https://api:8443/healthz", BodyMatch: "ok", Interval: "10s", Timeout: "2s"}},
+		},
+		{
+			name:   "tcp check",
+			config: StatusInformerConfig{Informer: "tcpcheck/db", HealthCheck: &HealthCheck{Address: "postgres:5432"}},
+		},
+		{
+			name:    "health check with url and address",
+			config:  StatusInformerConfig{Informer: "httpcheck/api", HealthCheck: &HealthCheck{URL: "http://api/healthz", Address: "api:80"}},
+			wantErr: true,
+		},
+		{
+			name:    "health check with invalid interval",
+			config:  StatusInformerConfig{Informer: "httpcheck/api", HealthCheck: &HealthCheck{URL: "http://api/healthz", Interval: "often"}},
+			wantErr: true,
+		},
+		{
+			name:    "health check with invalid body match",
+			config:  StatusInformerConfig{Informer: "httpcheck/api", HealthCheck: &HealthCheck{URL: "http://api/healthz", BodyMatch: "("}},
+			wantErr: true,
+		},
+		{
+			name:    "http check with an address",
+			config:  StatusInformerConfig{Informer: "httpcheck/api", HealthCheck: &HealthCheck{Address: "api:80"}},
+			wantErr: true,
+		},
+		{
+			name:    "tcp check with a url",
+			config:  StatusInformerConfig{Informer: "tcpchecks/db", HealthCheck: &HealthCheck{URL: "http://postgres:5432"}},
+			wantErr: true,
+		},
+		{
+			name:    "http check without a health check",
+			config:  StatusInformerConfig{Informer: "httpcheck/api"},
+			wantErr: true,
+		},
+		{
+			name:    "tcp check without a health check",
+			config:  StatusInformerConfig{Informer: "tcpcheck/db"},
+			wantErr: true,
+		},
+		{
+			name:    "http check with a selector",
+			config:  StatusInformerConfig{Informer: "httpcheck/selector:app=api", HealthCheck: &HealthCheck{URL: "http://api/healthz"}},
+			wantErr: true,
+		},
+		{
+			name:    "health check on another kind",
+			config:  StatusInformerConfig{Informer: "deployment/api", HealthCheck: &HealthCheck{URL: "http://api/healthz"}},
+			wantErr: true,
+		},
+		{
+			name:    "health check with api version",
+			config:  StatusInformerConfig{Informer: "httpcheck/api", APIVersion: "v1", HealthCheck: &HealthCheck{URL: "http://api/healthz"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.config.Parse()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStatusInformerString_ParseSelector(t *testing.T) {
+	tests := []struct {
+		name          string
+		informer      StatusInformerString
+		wantNamespace string
+		wantKind      string
+		wantSelector  string
+		wantErr       bool
+	}{
+		{
+			name:         "selector",
+			informer:     "deployment/selector:app.kubernetes.io/part-of=myapp",
+			wantKind:     "deployment",
+			wantSelector: "app.kubernetes.io/part-of=myapp",
+		},
+		{
+			name:          "selector with namespace",
+			informer:      "tenants/statefulset/selector:app.kubernetes.io/part-of=myapp,tier in (db,cache)",
+			wantNamespace: "tenants",
+			wantKind:      "statefulset",
+			wantSelector:  "app.kubernetes.io/part-of=myapp,tier in (cache,db)",
+		},
+		{
+			name:     "empty selector",
+			informer: "deployment/selector:",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid selector",
+			informer: "deployment/selector:app in (",
+			wantErr:  true,
+		},
+		{
+			name:     "too many parts",
+			informer: "a/b/deployment/selector:app=myapp",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			informer, err := tt.informer.Parse()
+			if tt.wantErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			req.Equal(tt.wantNamespace, informer.Namespace)
+			req.Equal(tt.wantKind, informer.Kind)
+			req.Empty(informer.Name)
+			req.Equal(tt.wantSelector, informer.Selector.String())
+		})
+	}
+}
+
+func TestStatusInformer_Matches(t *testing.T) {
+	req := require.New(t)
+
+	named, err := StatusInformerString("default/deployment/web").Parse()
+	req.NoError(err)
+	req.True(named.Matches("default", "web", nil))
+	req.False(named.Matches("default", "api", nil))
+	req.False(named.Matches("other", "web", nil))
+
+	selector, err := StatusInformerString("default/deployment/selector:app.kubernetes.io/part-of=myapp").Parse()
+	req.NoError(err)
+	req.True(selector.Matches("default", "tenant-a", map[string]string{"app.kubernetes.io/part-of": "myapp"}))
+	req.False(selector.Matches("default", "tenant-a", map[string]string{"app.kubernetes.io/part-of": "other"}))
+	req.False(selector.Matches("other", "tenant-a", map[string]string{"app.kubernetes.io/part-of": "myapp"}))
+}
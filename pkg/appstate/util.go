@@ -2,8 +2,10 @@ package appstate
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/logger"
@@ -32,9 +34,51 @@ func filterStatusInformersByResourceKind(informers []types.StatusInformer, kind
 	return
 }
 
+func filterDynamicStatusInformers(informers []types.StatusInformer) (next []types.StatusInformer) {
+	for _, informer := range informers {
+		if informer.APIVersion != "" {
+			next = append(next, informer)
+		}
+	}
+	return
+}
+
+func filterBuiltinStatusInformers(informers []types.StatusInformer) (next []types.StatusInformer) {
+	for _, informer := range informers {
+		if informer.APIVersion == "" {
+			next = append(next, informer)
+		}
+	}
+	return
+}
+
+// hasSelectorStatusInformer returns true if any of the informers matches resources in the namespace by label selector.
+func hasSelectorStatusInformer(informers []types.StatusInformer, namespace string) bool {
+	for _, informer := range informers {
+		if informer.Selector != nil && informer.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func isNamedByStatusInformer(informers []types.StatusInformer, resourceState types.ResourceState) bool {
+	for _, informer := range informers {
+		if informer.Selector == nil && informer.Kind == resourceState.Kind && informer.Namespace == resourceState.Namespace && informer.Name == resourceState.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// buildResourceStatesFromStatusInformers returns the initial resource states. Resources matched by a label selector
+// are not known until they are reported by the informers.
 func buildResourceStatesFromStatusInformers(informers []types.StatusInformer) types.ResourceStates {
 	next := types.ResourceStates{}
 	for _, informer := range informers {
+		if informer.Selector != nil {
+			continue
+		}
 		next = append(next, types.ResourceState{
 			Kind:      informer.Kind,
 			Name:      informer.Name,
@@ -46,21 +90,82 @@ func buildResourceStatesFromStatusInformers(informers []types.StatusInformer) ty
 	return next
 }
 
-func resourceStatesApplyNew(resourceStates types.ResourceStates, resourceState types.ResourceState) (next types.ResourceStates) {
+func resourceStatesContain(resourceStates types.ResourceStates, resourceState types.ResourceState) bool {
+	_, ok := resourceStatesGetState(resourceStates, resourceState)
+	return ok
+}
+
+// resourceStatesGetState returns the current state of the resource, if it is in the resource states.
+func resourceStatesGetState(resourceStates types.ResourceStates, resourceState types.ResourceState) (types.State, bool) {
+	for _, r := range resourceStates {
+		if resourceState.Kind == r.Kind && resourceState.Namespace == r.Namespace && resourceState.Name == r.Name {
+			return r.State, true
+		}
+	}
+	return "", false
+}
+
+// resourceStatesApplyNew updates the resource states with a new resource state. Resources that are matched by a label selector
+// are added when they appear and removed when they are missing, while resources named by an informer are always reported.
+func resourceStatesApplyNew(resourceStates types.ResourceStates, resourceState types.ResourceState, informers []types.StatusInformer) (next types.ResourceStates) {
+	found := false
 	for _, r := range resourceStates {
 		if resourceState.Kind == r.Kind &&
 			resourceState.Namespace == r.Namespace &&
-			resourceState.Name == r.Name &&
-			resourceState.State != r.State {
-			next = append(next, resourceState)
-		} else {
-			next = append(next, r)
+			resourceState.Name == r.Name {
+			found = true
+			if resourceState.State == types.StateMissing && !isNamedByStatusInformer(informers, resourceState) {
+				continue
+			}
+			if !reflect.DeepEqual(resourceState, r) {
+				next = append(next, resourceState)
+				continue
+			}
 		}
+		next = append(next, r)
+	}
+	if !found && resourceState.State != types.StateMissing {
+		next = append(next, resourceState)
 	}
 	sort.Sort(next)
 	return
 }
 
+// buildAppStatusTransition returns the transition between two app statuses, including the resources whose state changed
+func buildAppStatusTransition(previous types.AppStatus, next types.AppStatus) types.AppStatusTransition {
+	transition := types.AppStatusTransition{
+		Timestamp:     next.UpdatedAt,
+		PreviousState: previous.State,
+		State:         next.State,
+		Sequence:      next.Sequence,
+	}
+	if transition.Timestamp.IsZero() {
+		transition.Timestamp = time.Now()
+	}
+
+	previousStates := map[string]types.State{}
+	for _, r := range previous.ResourceStates {
+		previousStates[fmt.Sprintf("%s/%s/%s", r.Namespace, r.Kind, r.Name)] = r.State
+	}
+	for _, r := range next.ResourceStates {
+		previousState := previousStates[fmt.Sprintf("%s/%s/%s", r.Namespace, r.Kind, r.Name)]
+		if previousState == r.State {
+			continue
+		}
+		transition.ChangedResources = append(transition.ChangedResources, types.ResourceStateTransition{
+			Kind:          r.Kind,
+			Name:          r.Name,
+			Namespace:     r.Namespace,
+			PreviousState: previousState,
+			State:         r.State,
+			Reason:        r.Reason,
+			Message:       r.Message,
+		})
+	}
+
+	return transition
+}
+
 func GenerateStatusInformersForManifest(manifest string) []types.StatusInformerString {
 	logger.Info("Generating status informers from Helm release")
 
@@ -90,12 +195,22 @@ func GenerateStatusInformersForManifest(manifest string) []types.StatusInformerS
 		name := unstructured.GetName()
 
 		switch kind {
-		case "deployment", "statefulset", "daemonset", "service", "ingress", "persistentvolumeclaim":
+		case "deployment", "statefulset", "daemonset", "service", "ingress", "persistentvolumeclaim", "job", "cronjob":
 			informer := fmt.Sprintf("%s/%s", strings.ToLower(gvk.Kind), name)
 			if namespace != "" {
 				informer = fmt.Sprintf("%s/%s", namespace, informer)
 			}
 			informers = append(informers, types.StatusInformerString(informer))
+		case GatewayResourceKind, HTTPRouteResourceKind:
+			if gvk.Group != GatewayAPIGroup {
+				logger.Debugf("unsupported informer for %s/%s/%s in group %s", namespace, kind, name, gvk.Group)
+				continue
+			}
+			informer := fmt.Sprintf("%s/%s", kind, name)
+			if namespace != "" {
+				informer = fmt.Sprintf("%s/%s", namespace, informer)
+			}
+			informers = append(informers, types.StatusInformerString(informer))
 		default:
 			logger.Debugf("unsupported informer for %s/%s/%s", namespace, kind, name)
 		}
@@ -3,6 +3,7 @@ package appstate
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 )
@@ -132,6 +133,16 @@ metadata:
 ---
 apiVersion: networking.k8s.io/v1
 kind: Ingress
+metadata:
+  name: test
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test
+---
+apiVersion: batch/v1
+kind: CronJob
 metadata:
   name: test
 `,
@@ -143,6 +154,8 @@ metadata:
 				"service/test",
 				"persistentvolumeclaim/test",
 				"ingress/test",
+				"job/test",
+				"cronjob/test",
 			},
 		},
 		{
@@ -199,6 +212,31 @@ metadata:
 				"ingress/test",
 			},
 		},
+		{
+			name: "gateway api",
+			args: args{
+				manifest: `apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: test
+  namespace: default
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: test
+---
+apiVersion: example.com/v1
+kind: Gateway
+metadata:
+  name: other
+---`,
+			},
+			want: []types.StatusInformerString{
+				"default/gateway/test",
+				"httproute/test",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -209,3 +247,37 @@ metadata:
 		})
 	}
 }
+
+func Test_buildAppStatusTransition(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 3, 10, 0, 0, time.UTC)
+
+	previous := types.AppStatus{
+		State: types.StateReady,
+		ResourceStates: types.ResourceStates{
+			{Kind: "deployment", Name: "web", Namespace: "default", State: types.StateReady},
+			{Kind: "service", Name: "web", Namespace: "default", State: types.StateReady},
+		},
+	}
+	next := types.AppStatus{
+		State:     types.StateDegraded,
+		Sequence:  2,
+		UpdatedAt: updatedAt,
+		ResourceStates: types.ResourceStates{
+			{Kind: "deployment", Name: "web", Namespace: "default", State: types.StateDegraded, Reason: "CrashLoopBackOff"},
+			{Kind: "service", Name: "web", Namespace: "default", State: types.StateReady},
+		},
+	}
+
+	want := types.AppStatusTransition{
+		Timestamp:     updatedAt,
+		PreviousState: types.StateReady,
+		State:         types.StateDegraded,
+		Sequence:      2,
+		ChangedResources: []types.ResourceStateTransition{
+			{Kind: "deployment", Name: "web", Namespace: "default", PreviousState: types.StateReady, State: types.StateDegraded, Reason: "CrashLoopBackOff"},
+		},
+	}
+	if got := buildAppStatusTransition(previous, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildAppStatusTransition() = %v, want %v", got, want)
+	}
+}
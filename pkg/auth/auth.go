@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/auth/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	tokenReviewCacheTTL = time.Minute
+)
+
+var (
+	authenticator *Authenticator
+
+	DefaultModes = []types.AuthMode{types.AuthModeLicenseID}
+
+	ErrMissingAuthorization = errors.New("missing authorization header")
+)
+
+// UnavailableError is returned when a request cannot be authenticated because the token review api or the
+// bearer token file cannot be reached, as opposed to the request not being authorized.
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnavailable returns true if the error is returned because the request could not be authenticated.
+func IsUnavailable(err error) bool {
+	var unavailableErr *UnavailableError
+	return errors.As(err, &unavailableErr)
+}
+
+type Authenticator struct {
+	config    types.AuthConfig
+	clientset kubernetes.Interface
+	namespace string
+
+	tokenReviewCache map[string]tokenReviewResult
+	mtx              sync.Mutex
+}
+
+type tokenReviewResult struct {
+	username  string
+	err       error
+	expiresAt time.Time
+}
+
+// Init validates the auth config and initializes the authenticator used by the api
+func Init(config types.AuthConfig, clientset kubernetes.Interface, namespace string) error {
+	a, err := NewAuthenticator(config, clientset, namespace)
+	if err != nil {
+		return err
+	}
+	authenticator = a
+	return nil
+}
+
+// GetAuthenticator returns the authenticator used by the api.
+// If it has not been initialized, the default config (license id authentication) is used.
+func GetAuthenticator() *Authenticator {
+	if authenticator == nil {
+		return &Authenticator{}
+	}
+	return authenticator
+}
+
+func NewAuthenticator(config types.AuthConfig, clientset kubernetes.Interface, namespace string) (*Authenticator, error) {
+	modes := append([]types.AuthMode{}, config.DefaultModes...)
+	for _, route := range config.Routes {
+		if route.Path == "" {
+			return nil, errors.New("auth route path is required")
+		}
+		if len(route.Modes) == 0 {
+			return nil, errors.Errorf("auth route %s must specify at least one mode", route.Path)
+		}
+		modes = append(modes, route.Modes...)
+	}
+
+	for _, mode := range modes {
+		switch mode {
+		case types.AuthModeLicenseID, types.AuthModeTokenReview:
+		case types.AuthModeBearerToken:
+			if config.BearerTokenFile == "" {
+				return nil, errors.Errorf("auth mode %q requires a bearer token file", mode)
+			}
+		default:
+			return nil, errors.Errorf("unknown auth mode %q", mode)
+		}
+	}
+
+	return &Authenticator{
+		config:           config,
+		clientset:        clientset,
+		namespace:        namespace,
+		tokenReviewCache: map[string]tokenReviewResult{},
+	}, nil
+}
+
+// GetModes returns the auth modes accepted for the given route path template and method
+func (a *Authenticator) GetModes(route string, method string) []types.AuthMode {
+	for _, rule := range a.config.Routes {
+		if !routeMatches(rule, route, method) {
+			continue
+		}
+		return rule.Modes
+	}
+	if len(a.config.DefaultModes) > 0 {
+		return a.config.DefaultModes
+	}
+	return DefaultModes
+}
+
+func routeMatches(rule types.RouteAuthConfig, route string, method string) bool {
+	if strings.HasSuffix(rule.Path, "*") {
+		if !strings.HasPrefix(route, strings.TrimSuffix(rule.Path, "*")) {
+			return false
+		}
+	} else if rule.Path != route {
+		return false
+	}
+
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, m := range rule.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns an error if the request is not authenticated by any of the modes accepted for the route
+func (a *Authenticator) Authenticate(r *http.Request, route string) error {
+	authorization := r.Header.Get("authorization")
+	if authorization == "" {
+		return ErrMissingAuthorization
+	}
+
+	modes := a.GetModes(route, r.Method)
+
+	var errs []string
+	unavailable := false
+	for _, mode := range modes {
+		var err error
+		switch mode {
+		case types.AuthModeLicenseID:
+			err = a.authenticateLicenseID(authorization)
+		case types.AuthModeBearerToken:
+			err = a.authenticateBearerToken(authorization)
+		case types.AuthModeTokenReview:
+			err = a.authenticateTokenReview(r.Context(), authorization)
+		default:
+			err = errors.Errorf("unknown auth mode %q", mode)
+		}
+		if err == nil {
+			return nil
+		}
+		if IsUnavailable(err) {
+			unavailable = true
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", mode, err))
+	}
+
+	if unavailable {
+		// the request may have been authorized by the mode that could not be checked
+		return &UnavailableError{Err: errors.Errorf("request could not be authenticated (%s)", strings.Join(errs, ", "))}
+	}
+	return errors.Errorf("request is not authorized (%s)", strings.Join(errs, ", "))
+}
+
+func (a *Authenticator) authenticateLicenseID(authorization string) error {
+	license := store.GetStore().GetLicense()
+	if license == nil || license.Spec.LicenseID != authorization {
+		return errors.New("license ID is not valid")
+	}
+	return nil
+}
+
+func (a *Authenticator) authenticateBearerToken(authorization string) error {
+	token, ok := getBearerToken(authorization)
+	if !ok {
+		return errors.New("bearer token is missing")
+	}
+
+	// read the token on every request so that it is picked up when the secret is rotated
+	expected, err := os.ReadFile(a.config.BearerTokenFile)
+	if err != nil {
+		return &UnavailableError{Err: errors.Wrap(err, "failed to read bearer token file")}
+	}
+	expectedToken := strings.TrimSpace(string(expected))
+	if expectedToken == "" {
+		return errors.New("bearer token is not configured")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expectedToken)) != 1 {
+		return errors.New("bearer token is not valid")
+	}
+	return nil
+}
+
+func (a *Authenticator) authenticateTokenReview(ctx context.Context, authorization string) error {
+	token, ok := getBearerToken(authorization)
+	if !ok {
+		return errors.New("bearer token is missing")
+	}
+
+	result := a.reviewToken(ctx, token)
+	if result.err != nil {
+		return result.err
+	}
+
+	namespace, name, err := serviceaccount.SplitUsername(result.username)
+	if err != nil {
+		return errors.Errorf("%s is not a service account", result.username)
+	}
+	if !a.isServiceAccountAllowed(namespace, name) {
+		return errors.Errorf("service account %s/%s is not allowed", namespace, name)
+	}
+
+	return nil
+}
+
+func (a *Authenticator) reviewToken(ctx context.Context, token string) tokenReviewResult {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	a.mtx.Lock()
+	cached, ok := a.tokenReviewCache[key]
+	a.mtx.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached
+	}
+
+	result := tokenReviewResult{
+		expiresAt: time.Now().Add(tokenReviewCacheTTL),
+	}
+
+	tokenReview, err := a.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.config.TokenReview.Audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		// don't cache errors from the api server
+		return tokenReviewResult{err: &UnavailableError{Err: errors.Wrap(err, "failed to create token review")}}
+	}
+
+	if !tokenReview.Status.Authenticated {
+		result.err = errors.New("token is not authenticated")
+		if tokenReview.Status.Error != "" {
+			result.err = errors.Errorf("token is not authenticated: %s", tokenReview.Status.Error)
+		}
+	} else {
+		result.username = tokenReview.Status.User.Username
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for k, v := range a.tokenReviewCache {
+		if time.Now().After(v.expiresAt) {
+			delete(a.tokenReviewCache, k)
+		}
+	}
+	a.tokenReviewCache[key] = result
+
+	return result
+}
+
+func (a *Authenticator) isServiceAccountAllowed(namespace string, name string) bool {
+	if len(a.config.TokenReview.AllowedServiceAccounts) == 0 {
+		return namespace == a.namespace
+	}
+	for _, allowed := range a.config.TokenReview.AllowedServiceAccounts {
+		if allowed == fmt.Sprintf("%s/%s", namespace, name) || allowed == fmt.Sprintf("%s/*", namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+func getBearerToken(authorization string) (string, bool) {
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return "", false
+	}
+	token := strings.TrimSpace(parts[1])
+	return token, token != ""
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/replicated-sdk/pkg/auth/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAuthenticator_GetModes(t *testing.T) {
+	a, err := NewAuthenticator(types.AuthConfig{
+		DefaultModes: []types.AuthMode{types.AuthModeTokenReview},
+		Routes: []types.RouteAuthConfig{
+			{
+				Path:    "/api/v1/app/custom-metrics",
+				Methods: []string{"POST"},
+				Modes:   []types.AuthMode{types.AuthModeLicenseID},
+			},
+			{
+				Path:  "/api/v1/license/*",
+				Modes: []types.AuthMode{types.AuthModeLicenseID, types.AuthModeTokenReview},
+			},
+		},
+	}, nil, "default")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		route  string
+		method string
+		want   []types.AuthMode
+	}{
+		{
+			name:   "exact path and method",
+			route:  "/api/v1/app/custom-metrics",
+			method: "post",
+			want:   []types.AuthMode{types.AuthModeLicenseID},
+		},
+		{
+			name:   "exact path with other method",
+			route:  "/api/v1/app/custom-metrics",
+			method: "GET",
+			want:   []types.AuthMode{types.AuthModeTokenReview},
+		},
+		{
+			name:   "prefix",
+			route:  "/api/v1/license/fields/{fieldName}",
+			method: "GET",
+			want:   []types.AuthMode{types.AuthModeLicenseID, types.AuthModeTokenReview},
+		},
+		{
+			name:   "default",
+			route:  "/api/v1/app/info",
+			method: "GET",
+			want:   []types.AuthMode{types.AuthModeTokenReview},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, a.GetModes(tt.route, tt.method))
+		})
+	}
+
+	// the license id is the default mode if none are configured
+	a, err = NewAuthenticator(types.AuthConfig{}, nil, "default")
+	require.NoError(t, err)
+	require.Equal(t, DefaultModes, a.GetModes("/api/v1/app/info", "GET"))
+}
+
+func TestNewAuthenticator_Validation(t *testing.T) {
+	_, err := NewAuthenticator(types.AuthConfig{DefaultModes: []types.AuthMode{"basic"}}, nil, "default")
+	require.Error(t, err)
+
+	_, err = NewAuthenticator(types.AuthConfig{DefaultModes: []types.AuthMode{types.AuthModeBearerToken}}, nil, "default")
+	require.Error(t, err, "bearer token mode requires a token file")
+
+	_, err = NewAuthenticator(types.AuthConfig{Routes: []types.RouteAuthConfig{{Path: "/api/v1/app/info"}}}, nil, "default")
+	require.Error(t, err, "route rules require a mode")
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	store.InitInMemory(store.InitInMemoryStoreOptions{
+		License: &kotsv1beta1.License{
+			Spec: kotsv1beta1.LicenseSpec{
+				LicenseID: "license-id",
+			},
+		},
+	})
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("static-token\n"), 0600))
+
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tokenReview := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch tokenReview.Spec.Token {
+		case "api-error-token":
+			return true, nil, errors.New("connection refused")
+		case "sa-token":
+			tokenReview.Status.Authenticated = true
+			tokenReview.Status.User.Username = "system:serviceaccount:default:my-app"
+		case "other-ns-sa-token":
+			tokenReview.Status.Authenticated = true
+			tokenReview.Status.User.Username = "system:serviceaccount:other:my-app"
+		}
+		return true, tokenReview, nil
+	})
+
+	a, err := NewAuthenticator(types.AuthConfig{
+		DefaultModes:    []types.AuthMode{types.AuthModeLicenseID, types.AuthModeBearerToken, types.AuthModeTokenReview},
+		BearerTokenFile: tokenFile,
+	}, clientset, "default")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		authorization   string
+		wantErr         bool
+		wantUnavailable bool
+	}{
+		{
+			name:          "missing header",
+			authorization: "",
+			wantErr:       true,
+		},
+		{
+			name:          "license id",
+			authorization: "license-id",
+		},
+		{
+			name:          "invalid license id",
+			authorization: "other-license-id",
+			wantErr:       true,
+		},
+		{
+			name:          "static bearer token",
+			authorization: "Bearer static-token",
+		},
+		{
+			name:          "service account token",
+			authorization: "Bearer sa-token",
+		},
+		{
+			name:          "service account token from another namespace",
+			authorization: "Bearer other-ns-sa-token",
+			wantErr:       true,
+		},
+		{
+			name:          "invalid bearer token",
+			authorization: "Bearer invalid",
+			wantErr:       true,
+		},
+		{
+			name:            "token review api failure",
+			authorization:   "Bearer api-error-token",
+			wantErr:         true,
+			wantUnavailable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest("GET", "/api/v1/app/info", nil)
+			require.NoError(t, err)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			err = a.Authenticate(r, "/api/v1/app/info")
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantUnavailable, IsUnavailable(err))
+		})
+	}
+}
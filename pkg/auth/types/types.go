@@ -0,0 +1,38 @@
+package types
+
+type AuthMode string
+
+const (
+	// AuthModeLicenseID authenticates requests that set the authorization header to the license ID
+	AuthModeLicenseID AuthMode = "license-id"
+	// AuthModeBearerToken authenticates requests with a bearer token that matches the token mounted from a secret
+	AuthModeBearerToken AuthMode = "bearer-token"
+	// AuthModeTokenReview authenticates requests with the caller's service account token using a TokenReview
+	AuthModeTokenReview AuthMode = "token-review"
+)
+
+type AuthConfig struct {
+	// DefaultModes are the modes accepted by routes that don't match any of the route rules
+	DefaultModes []AuthMode `yaml:"defaultModes"`
+	// Routes override the accepted modes for specific routes. The first matching rule applies.
+	Routes          []RouteAuthConfig `yaml:"routes"`
+	BearerTokenFile string            `yaml:"bearerTokenFile"`
+	TokenReview     TokenReviewConfig `yaml:"tokenReview"`
+}
+
+type RouteAuthConfig struct {
+	// Path is the route's path template (e.g. /api/v1/license/fields/{fieldName}).
+	// A trailing "*" matches all routes with the given prefix.
+	Path string `yaml:"path"`
+	// Methods the rule applies to, all methods if empty
+	Methods []string   `yaml:"methods"`
+	Modes   []AuthMode `yaml:"modes"`
+}
+
+type TokenReviewConfig struct {
+	Audiences []string `yaml:"audiences"`
+	// AllowedServiceAccounts are the service accounts that are allowed to call the api in the "namespace/name" format.
+	// The name can be "*" to allow all service accounts in a namespace.
+	// If empty, all service accounts in the sdk's namespace are allowed.
+	AllowedServiceAccounts []string `yaml:"allowedServiceAccounts"`
+}
@@ -2,26 +2,41 @@ package config
 
 import (
 	"github.com/pkg/errors"
+	apiservertypes "github.com/replicatedhq/replicated-sdk/pkg/apiserver/types"
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	authtypes "github.com/replicatedhq/replicated-sdk/pkg/auth/types"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
 	sdklicensetypes "github.com/replicatedhq/replicated-sdk/pkg/license/types"
+	reporttypes "github.com/replicatedhq/replicated-sdk/pkg/report/types"
+	webhooktypes "github.com/replicatedhq/replicated-sdk/pkg/webhook/types"
 	"gopkg.in/yaml.v2"
 )
 
 type ReplicatedConfig struct {
-	License               string                               `yaml:"license"`
-	LicenseFields         sdklicensetypes.LicenseFields        `yaml:"licenseFields"`
-	AppName               string                               `yaml:"appName"`
-	ChannelID             string                               `yaml:"channelID"`
-	ChannelName           string                               `yaml:"channelName"`
-	ChannelSequence       int64                                `yaml:"channelSequence"`
-	ReleaseSequence       int64                                `yaml:"releaseSequence"`
-	ReleaseCreatedAt      string                               `yaml:"releaseCreatedAt"`
-	ReleaseNotes          string                               `yaml:"releaseNotes"`
-	VersionLabel          string                               `yaml:"versionLabel"`
-	ReplicatedAppEndpoint string                               `yaml:"replicatedAppEndpoint"`
-	StatusInformers       []appstatetypes.StatusInformerString `yaml:"statusInformers"`
-	ReplicatedID          string                               `yaml:"replicatedID"`
-	AppID                 string                               `yaml:"appID"`
+	License                      string                                `yaml:"license"`
+	LicenseFields                sdklicensetypes.LicenseFields         `yaml:"licenseFields"`
+	AppName                      string                                `yaml:"appName"`
+	ChannelID                    string                                `yaml:"channelID"`
+	ChannelName                  string                                `yaml:"channelName"`
+	ChannelSequence              int64                                 `yaml:"channelSequence"`
+	ReleaseSequence              int64                                 `yaml:"releaseSequence"`
+	ReleaseCreatedAt             string                                `yaml:"releaseCreatedAt"`
+	ReleaseNotes                 string                                `yaml:"releaseNotes"`
+	VersionLabel                 string                                `yaml:"versionLabel"`
+	ReplicatedAppEndpoint        string                                `yaml:"replicatedAppEndpoint"`
+	StatusInformers              []appstatetypes.StatusInformerConfig  `yaml:"statusInformers"`
+	StatusAggregation            *appstatetypes.AggregationPolicy      `yaml:"statusAggregation"`
+	StatusDamping                appstatetypes.DampingPolicy           `yaml:"statusDamping"`
+	CertificateExpiryWarningDays int                                   `yaml:"certificateExpiryWarningDays"`
+	ReplicatedID                 string                                `yaml:"replicatedID"`
+	AppID                        string                                `yaml:"appID"`
+	Auth                         authtypes.AuthConfig                  `yaml:"auth"`
+	ListenAddress                string                                `yaml:"listenAddress"`
+	TLS                          apiservertypes.TLSConfig              `yaml:"tls"`
+	Webhooks                     []webhooktypes.WebhookConfig          `yaml:"webhooks"`
+	CustomMetrics                []custommetricstypes.MetricDefinition `yaml:"customMetrics"`
+	CustomMetricsBatching        custommetricstypes.BatchingConfig     `yaml:"customMetricsBatching"`
+	ClusterInventory             reporttypes.ClusterInventoryConfig    `yaml:"clusterInventory"`
 }
 
 func ParseReplicatedConfig(config []byte) (*ReplicatedConfig, error) {
@@ -0,0 +1,162 @@
+package custommetrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+)
+
+var (
+	definitionsMtx sync.RWMutex
+	definitions    map[string]types.MetricDefinition
+)
+
+// Init sets the declared custom metrics that the reported metrics are validated against.
+// If no metrics are declared, any metric with a scalar value is accepted.
+func Init(metrics []types.MetricDefinition) error {
+	next := map[string]types.MetricDefinition{}
+	for _, metric := range metrics {
+		if err := metric.Validate(); err != nil {
+			return err
+		}
+		if _, ok := next[metric.Name]; ok {
+			return errors.Errorf("custom metric %q is declared more than once", metric.Name)
+		}
+		next[metric.Name] = metric
+	}
+
+	definitionsMtx.Lock()
+	defer definitionsMtx.Unlock()
+	definitions = next
+
+	return nil
+}
+
+// GetSchema returns the declared custom metrics, sorted by name.
+func GetSchema() types.Schema {
+	definitionsMtx.RLock()
+	defer definitionsMtx.RUnlock()
+
+	schema := types.Schema{
+		Strict:  len(definitions) > 0,
+		Metrics: []types.MetricDefinition{},
+	}
+	for _, definition := range definitions {
+		schema.Metrics = append(schema.Metrics, definition)
+	}
+	sort.Slice(schema.Metrics, func(i, j int) bool {
+		return schema.Metrics[i].Name < schema.Metrics[j].Name
+	})
+	return schema
+}
+
+// Validate returns the errors of the metrics that are not declared, or whose values do not match their declaration.
+// The errors are sorted by metric name. Nothing is validated if no metrics are declared.
+func Validate(data map[string]interface{}) []types.ValidationError {
+	definitionsMtx.RLock()
+	defer definitionsMtx.RUnlock()
+
+	if len(definitions) == 0 {
+		return nil
+	}
+
+	var validationErrors []types.ValidationError
+	for name, value := range data {
+		definition, ok := definitions[name]
+		if !ok {
+			validationErrors = append(validationErrors, types.ValidationError{
+				Metric:  name,
+				Code:    types.ValidationErrorUnknownMetric,
+				Message: fmt.Sprintf("%s is not a declared custom metric", name),
+			})
+			continue
+		}
+		if err := validateValue(definition, value); err != nil {
+			validationErrors = append(validationErrors, *err)
+		}
+	}
+
+	sort.Slice(validationErrors, func(i, j int) bool {
+		return validationErrors[i].Metric < validationErrors[j].Metric
+	})
+	return validationErrors
+}
+
+// Merge merges the metrics into the merged metrics. Declared counters are increments so their values are summed, the last value of other metrics wins.
+func Merge(merged map[string]interface{}, data map[string]interface{}) {
+	definitionsMtx.RLock()
+	defer definitionsMtx.RUnlock()
+
+	for name, value := range data {
+		if definition, ok := definitions[name]; ok && definition.Type == types.MetricTypeCounter {
+			previous, previousOk := toFloat64(merged[name])
+			next, nextOk := toFloat64(value)
+			if previousOk && nextOk {
+				merged[name] = previous + next
+				continue
+			}
+		}
+		merged[name] = value
+	}
+}
+
+func validateValue(definition types.MetricDefinition, value interface{}) *types.ValidationError {
+	number, ok := toFloat64(value)
+	if !ok {
+		return &types.ValidationError{
+			Metric:  definition.Name,
+			Code:    types.ValidationErrorInvalidType,
+			Message: fmt.Sprintf("%s is a %s and must be a number", definition.Name, definition.Type),
+		}
+	}
+
+	if definition.Type == types.MetricTypeCounter && number < 0 {
+		return &types.ValidationError{
+			Metric:  definition.Name,
+			Code:    types.ValidationErrorOutOfRange,
+			Message: fmt.Sprintf("%s is a counter and cannot be negative", definition.Name),
+		}
+	}
+	if definition.Min != nil && number < *definition.Min {
+		return &types.ValidationError{
+			Metric:  definition.Name,
+			Code:    types.ValidationErrorOutOfRange,
+			Message: fmt.Sprintf("%s must be at least %v", definition.Name, *definition.Min),
+		}
+	}
+	if definition.Max != nil && number > *definition.Max {
+		return &types.ValidationError{
+			Metric:  definition.Name,
+			Code:    types.ValidationErrorOutOfRange,
+			Message: fmt.Sprintf("%s must be at most %v", definition.Name, *definition.Max),
+		}
+	}
+
+	return nil
+}
+
+// toFloat64 returns the value as a number, values decoded from json are float64 but values of other numeric types are accepted too.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,165 @@
+package custommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/stretchr/testify/require"
+)
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []types.MetricDefinition
+		wantErr bool
+	}{
+		{
+			name: "no metrics",
+		},
+		{
+			name: "valid metrics",
+			metrics: []types.MetricDefinition{
+				{Name: "activeUsers", Type: types.MetricTypeGauge, Min: float64Ptr(0), Max: float64Ptr(100)},
+				{Name: "requests", Type: types.MetricTypeCounter},
+			},
+		},
+		{
+			name:    "missing name",
+			metrics: []types.MetricDefinition{{Type: types.MetricTypeGauge}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			metrics: []types.MetricDefinition{{Name: "activeUsers", Type: "histogram"}},
+			wantErr: true,
+		},
+		{
+			name:    "min greater than max",
+			metrics: []types.MetricDefinition{{Name: "activeUsers", Type: types.MetricTypeGauge, Min: float64Ptr(10), Max: float64Ptr(1)}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate name",
+			metrics: []types.MetricDefinition{
+				{Name: "activeUsers", Type: types.MetricTypeGauge},
+				{Name: "activeUsers", Type: types.MetricTypeCounter},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { Init(nil) })
+
+			err := Init(tt.metrics)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, GetSchema().Metrics, len(tt.metrics))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	req := require.New(t)
+	t.Cleanup(func() { Init(nil) })
+
+	// any metric is accepted when no metrics are declared
+	req.Empty(Validate(map[string]interface{}{"anything": "value"}))
+	req.False(GetSchema().Strict)
+
+	req.NoError(Init([]types.MetricDefinition{
+		{Name: "requests", Type: types.MetricTypeCounter, Unit: "requests"},
+		{Name: "activeUsers", Type: types.MetricTypeGauge, Unit: "users", Min: float64Ptr(0), Max: float64Ptr(100)},
+	}))
+
+	schema := GetSchema()
+	req.True(schema.Strict)
+	req.Equal("activeUsers", schema.Metrics[0].Name)
+	req.Equal("requests", schema.Metrics[1].Name)
+
+	req.Empty(Validate(map[string]interface{}{"activeUsers": float64(10), "requests": 5}))
+
+	req.Equal([]types.ValidationError{
+		{Metric: "activeUsers", Code: types.ValidationErrorOutOfRange, Message: "activeUsers must be at most 100"},
+		{Metric: "diskUsage", Code: types.ValidationErrorUnknownMetric, Message: "diskUsage is not a declared custom metric"},
+		{Metric: "requests", Code: types.ValidationErrorInvalidType, Message: "requests is a counter and must be a number"},
+	}, Validate(map[string]interface{}{"activeUsers": float64(101), "requests": "5", "diskUsage": 1}))
+
+	req.Equal([]types.ValidationError{
+		{Metric: "activeUsers", Code: types.ValidationErrorOutOfRange, Message: "activeUsers must be at least 0"},
+		{Metric: "requests", Code: types.ValidationErrorOutOfRange, Message: "requests is a counter and cannot be negative"},
+	}, Validate(map[string]interface{}{"activeUsers": float64(-1), "requests": float64(-1)}))
+}
+
+func TestMerge(t *testing.T) {
+	req := require.New(t)
+	t.Cleanup(func() { Init(nil) })
+
+	req.NoError(Init([]types.MetricDefinition{
+		{Name: "requests", Type: types.MetricTypeCounter},
+		{Name: "activeUsers", Type: types.MetricTypeGauge},
+	}))
+
+	merged := map[string]interface{}{}
+	Merge(merged, map[string]interface{}{"requests": float64(10), "activeUsers": float64(5), "plan": "free"})
+	Merge(merged, map[string]interface{}{"requests": 3, "activeUsers": float64(7)})
+	Merge(merged, map[string]interface{}{"plan": "pro"})
+
+	req.Equal(map[string]interface{}{
+		"requests":    float64(13),
+		"activeUsers": float64(7),
+		"plan":        "pro",
+	}, merged)
+}
+
+func TestBatchingConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       types.BatchingConfig
+		wantErr      bool
+		wantInterval time.Duration
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:         "interval and max size",
+			config:       types.BatchingConfig{Interval: "30s", MaxSize: 100},
+			wantInterval: 30 * time.Second,
+		},
+		{
+			name:    "invalid interval",
+			config:  types.BatchingConfig{Interval: "often"},
+			wantErr: true,
+		},
+		{
+			name:    "negative max size",
+			config:  types.BatchingConfig{Interval: "30s", MaxSize: -1},
+			wantErr: true,
+		},
+		{
+			name:    "max size without interval",
+			config:  types.BatchingConfig{MaxSize: 100},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantInterval, tt.config.GetInterval())
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package custommetrics
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+)
+
+type QueryOptions struct {
+	// Keys limits the series to the given metrics, all metrics are returned if empty
+	Keys []string
+	From time.Time
+	To   time.Time
+	// Aggregation reduces the values of each series to a single value, the values are returned if empty
+	Aggregation types.Aggregation
+}
+
+// Query returns the reported values of each custom metric in the history, sorted by key.
+func Query(history []types.Submission, options QueryOptions) ([]types.Series, error) {
+	switch options.Aggregation {
+	case "", types.AggregationLast, types.AggregationMin, types.AggregationMax, types.AggregationAvg:
+	default:
+		return nil, errors.Errorf("unknown aggregation %q, expected %q, %q, %q or %q", options.Aggregation, types.AggregationLast, types.AggregationMin, types.AggregationMax, types.AggregationAvg)
+	}
+
+	samples := map[string][]types.Sample{}
+	for _, submission := range history {
+		if !options.From.IsZero() && submission.Timestamp.Before(options.From) {
+			continue
+		}
+		if !options.To.IsZero() && submission.Timestamp.After(options.To) {
+			continue
+		}
+		for key, value := range submission.Data {
+			if len(options.Keys) > 0 && !slices.Contains(options.Keys, key) {
+				continue
+			}
+			samples[key] = append(samples[key], types.Sample{Timestamp: submission.Timestamp, Value: value})
+		}
+	}
+
+	series := []types.Series{}
+	for key, keySamples := range samples {
+		s := types.Series{
+			Key:   key,
+			Count: len(keySamples),
+		}
+		if options.Aggregation == "" {
+			s.Samples = keySamples
+		} else {
+			s.Aggregation = options.Aggregation
+			s.Value = aggregate(keySamples, options.Aggregation)
+		}
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Key < series[j].Key
+	})
+
+	return series, nil
+}
+
+func aggregate(samples []types.Sample, aggregation types.Aggregation) interface{} {
+	if aggregation == types.AggregationLast {
+		return samples[len(samples)-1].Value
+	}
+
+	var result float64
+	count := 0
+	for _, sample := range samples {
+		value, ok := toFloat64(sample.Value)
+		if !ok {
+			continue
+		}
+		switch {
+		case count == 0:
+			result = value
+		case aggregation == types.AggregationMin:
+			result = min(result, value)
+		case aggregation == types.AggregationMax:
+			result = max(result, value)
+		case aggregation == types.AggregationAvg:
+			result += value
+		}
+		count++
+	}
+
+	if count == 0 {
+		return nil
+	}
+	if aggregation == types.AggregationAvg {
+		return result / float64(count)
+	}
+	return result
+}
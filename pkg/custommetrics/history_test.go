@@ -0,0 +1,99 @@
+package custommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	start := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	history := []types.Submission{
+		{Timestamp: start, Data: map[string]interface{}{"activeUsers": float64(10), "plan": "free"}},
+		{Timestamp: start.Add(10 * time.Minute), Data: map[string]interface{}{"activeUsers": float64(30)}},
+		{Timestamp: start.Add(20 * time.Minute), Data: map[string]interface{}{"activeUsers": float64(20), "plan": "pro"}},
+	}
+
+	tests := []struct {
+		name    string
+		options QueryOptions
+		want    []types.Series
+		wantErr bool
+	}{
+		{
+			name:    "all values",
+			options: QueryOptions{},
+			want: []types.Series{
+				{Key: "activeUsers", Count: 3, Samples: []types.Sample{
+					{Timestamp: start, Value: float64(10)},
+					{Timestamp: start.Add(10 * time.Minute), Value: float64(30)},
+					{Timestamp: start.Add(20 * time.Minute), Value: float64(20)},
+				}},
+				{Key: "plan", Count: 2, Samples: []types.Sample{
+					{Timestamp: start, Value: "free"},
+					{Timestamp: start.Add(20 * time.Minute), Value: "pro"},
+				}},
+			},
+		},
+		{
+			name:    "key and time range",
+			options: QueryOptions{Keys: []string{"activeUsers"}, From: start.Add(5 * time.Minute), To: start.Add(15 * time.Minute)},
+			want: []types.Series{
+				{Key: "activeUsers", Count: 1, Samples: []types.Sample{{Timestamp: start.Add(10 * time.Minute), Value: float64(30)}}},
+			},
+		},
+		{
+			name:    "no values in range",
+			options: QueryOptions{To: start.Add(-time.Minute)},
+			want:    []types.Series{},
+		},
+		{
+			name:    "last",
+			options: QueryOptions{Aggregation: types.AggregationLast},
+			want: []types.Series{
+				{Key: "activeUsers", Count: 3, Aggregation: types.AggregationLast, Value: float64(20)},
+				{Key: "plan", Count: 2, Aggregation: types.AggregationLast, Value: "pro"},
+			},
+		},
+		{
+			name:    "min",
+			options: QueryOptions{Aggregation: types.AggregationMin},
+			want: []types.Series{
+				{Key: "activeUsers", Count: 3, Aggregation: types.AggregationMin, Value: float64(10)},
+				{Key: "plan", Count: 2, Aggregation: types.AggregationMin},
+			},
+		},
+		{
+			name:    "max",
+			options: QueryOptions{Keys: []string{"activeUsers"}, Aggregation: types.AggregationMax},
+			want: []types.Series{
+				{Key: "activeUsers", Count: 3, Aggregation: types.AggregationMax, Value: float64(30)},
+			},
+		},
+		{
+			name:    "avg",
+			options: QueryOptions{Keys: []string{"activeUsers"}, Aggregation: types.AggregationAvg},
+			want: []types.Series{
+				{Key: "activeUsers", Count: 3, Aggregation: types.AggregationAvg, Value: float64(20)},
+			},
+		},
+		{
+			name:    "unknown aggregation",
+			options: QueryOptions{Aggregation: "sum"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Query(history, tt.options)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
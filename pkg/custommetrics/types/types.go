@@ -0,0 +1,128 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type MetricType string
+
+const (
+	// MetricTypeGauge is a value that can go up and down, e.g. the number of active users
+	MetricTypeGauge MetricType = "gauge"
+	// MetricTypeCounter is an increment since the previous submission, e.g. the number of requests served since the metric was last reported.
+	// It cannot be negative, and the increments are summed when submissions are batched. Report cumulative totals as gauges instead.
+	MetricTypeCounter MetricType = "counter"
+)
+
+// MetricDefinition declares a custom app metric. When metrics are declared, only the declared metrics are accepted.
+type MetricDefinition struct {
+	Name string     `yaml:"name" json:"name"`
+	Type MetricType `yaml:"type" json:"type"`
+	// Unit is informational, e.g. "bytes" or "requests"
+	Unit string `yaml:"unit,omitempty" json:"unit,omitempty"`
+	// Min and Max are the inclusive range of the values that are accepted
+	Min         *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         *float64 `yaml:"max,omitempty" json:"max,omitempty"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+func (d MetricDefinition) Validate() error {
+	if d.Name == "" {
+		return errors.New("custom metric name is required")
+	}
+	if d.Type != MetricTypeGauge && d.Type != MetricTypeCounter {
+		return errors.Errorf("custom metric %q has an unknown type %q, expected %q or %q", d.Name, d.Type, MetricTypeGauge, MetricTypeCounter)
+	}
+	if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+		return errors.Errorf("custom metric %q has a min greater than its max", d.Name)
+	}
+	return nil
+}
+
+type ValidationErrorCode string
+
+const (
+	ValidationErrorUnknownMetric ValidationErrorCode = "UnknownMetric"
+	ValidationErrorInvalidType   ValidationErrorCode = "InvalidType"
+	ValidationErrorOutOfRange    ValidationErrorCode = "OutOfRange"
+)
+
+// ValidationError is the reason a custom metric value was rejected.
+type ValidationError struct {
+	Metric  string              `json:"metric"`
+	Code    ValidationErrorCode `json:"code"`
+	Message string              `json:"message"`
+}
+
+// Schema is the set of custom metrics that are accepted.
+type Schema struct {
+	// Strict is true when metrics are declared, and only the declared metrics are accepted
+	Strict  bool               `json:"strict"`
+	Metrics []MetricDefinition `json:"metrics"`
+}
+
+// Submission is a set of custom metric values that the app reported at the same time.
+type Submission struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+type Aggregation string
+
+const (
+	AggregationLast Aggregation = "last"
+	AggregationMin  Aggregation = "min"
+	AggregationMax  Aggregation = "max"
+	AggregationAvg  Aggregation = "avg"
+)
+
+type Sample struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Value     interface{} `json:"value"`
+}
+
+// Series is the reported values of a custom metric, oldest first, or their aggregation if one was requested.
+type Series struct {
+	Key         string      `json:"key"`
+	Count       int         `json:"count"`
+	Samples     []Sample    `json:"samples,omitempty"`
+	Aggregation Aggregation `json:"aggregation,omitempty"`
+	// Value is the aggregated value, min, max and avg only consider the numeric values and are not set if there are none
+	Value interface{} `json:"value,omitempty"`
+}
+
+// BatchingConfig buffers the custom metrics that the app reports and sends them merged, instead of sending every submission.
+// Batching is disabled if no interval is set.
+type BatchingConfig struct {
+	// Interval is how often the buffered metrics are sent, e.g. "30s"
+	Interval string `yaml:"interval,omitempty"`
+	// MaxSize is the number of submissions after which the buffered metrics are sent before the interval elapses
+	MaxSize int `yaml:"maxSize,omitempty"`
+}
+
+func (c BatchingConfig) Validate() error {
+	if c.Interval != "" {
+		if d, err := time.ParseDuration(c.Interval); err != nil || d < 0 {
+			return fmt.Errorf("invalid batching interval %q", c.Interval)
+		}
+	}
+	if c.MaxSize < 0 {
+		return fmt.Errorf("invalid batching max size %d", c.MaxSize)
+	}
+	if c.MaxSize > 0 && c.GetInterval() == 0 {
+		return fmt.Errorf("batching max size requires an interval")
+	}
+	return nil
+}
+
+// GetInterval returns the parsed interval, or 0 if batching is disabled or the interval is invalid.
+func (c BatchingConfig) GetInterval() time.Duration {
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
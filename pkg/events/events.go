@@ -0,0 +1,182 @@
+package events
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/events/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+)
+
+const (
+	// ReplayBufferSize is the number of recent events that are kept so that subscribers can resume after reconnecting
+	ReplayBufferSize = 100
+	// subscriberBufferSize is the number of events that can be queued for a subscriber before it is dropped
+	subscriberBufferSize = 64
+)
+
+var defaultBus = NewBus()
+
+// Bus fans out events to subscribers. Publishing never blocks: a subscriber that falls behind
+// is dropped and is expected to reconnect and resume from the last event it received.
+type Bus struct {
+	mtx         sync.Mutex
+	lastID      uint64
+	replay      []types.Event
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+type Subscription struct {
+	events    chan types.Event
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Events returns the channel the events are delivered on.
+func (s *Subscription) Events() <-chan types.Event {
+	return s.events
+}
+
+// Done is closed when the subscription is dropped because it fell behind, or when the bus is shut down.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Subscription) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		// event ids start at the current time so that an id from before a restart is not mistaken for a recent one
+		lastID:      uint64(time.Now().UnixMilli()),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+func Publish(eventType types.EventType, data interface{}) {
+	defaultBus.Publish(eventType, data)
+}
+
+func Subscribe(lastEventID string) (*Subscription, []types.Event, bool) {
+	return defaultBus.Subscribe(lastEventID)
+}
+
+func Unsubscribe(sub *Subscription) {
+	defaultBus.Unsubscribe(sub)
+}
+
+func LastEventID() uint64 {
+	return defaultBus.LastEventID()
+}
+
+func Shutdown() {
+	defaultBus.Shutdown()
+}
+
+func (b *Bus) Publish(eventType types.EventType, data interface{}) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	d, err := json.Marshal(data)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to marshal %s event", eventType))
+		return
+	}
+
+	b.lastID++
+	event := types.Event{
+		ID:        b.lastID,
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Data:      d,
+	}
+
+	b.replay = append(b.replay, event)
+	if len(b.replay) > ReplayBufferSize {
+		b.replay = b.replay[len(b.replay)-ReplayBufferSize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			logger.Infof("dropping event subscriber that fell behind")
+			delete(b.subscribers, sub)
+			sub.close()
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. If lastEventID is the id of a recent event, the events that were
+// published after it are returned and the last return value is true. Otherwise the subscriber cannot be resumed
+// and should be sent the current state instead.
+func (b *Bus) Subscribe(lastEventID string) (*Subscription, []types.Event, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	sub := &Subscription{
+		events: make(chan types.Event, subscriberBufferSize),
+		done:   make(chan struct{}),
+	}
+	if b.closed {
+		sub.close()
+		return sub, nil, false
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, false
+	}
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return sub, nil, false
+	}
+	if id == b.lastID {
+		return sub, nil, true
+	}
+	for i, event := range b.replay {
+		if event.ID == id {
+			return sub, append([]types.Event{}, b.replay[i+1:]...), true
+		}
+	}
+
+	return sub, nil, false
+}
+
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	delete(b.subscribers, sub)
+	sub.close()
+}
+
+// LastEventID returns the id of the last published event.
+func (b *Bus) LastEventID() uint64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.lastID
+}
+
+// Shutdown closes all subscriptions so that long-lived streams end and the http server can shut down.
+func (b *Bus) Shutdown() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		sub.close()
+	}
+}
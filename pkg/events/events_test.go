@@ -0,0 +1,103 @@
+package events
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/events/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_PublishAndResume(t *testing.T) {
+	req := require.New(t)
+
+	b := NewBus()
+
+	sub, missed, resumed := b.Subscribe("")
+	req.False(resumed)
+	req.Empty(missed)
+
+	b.Publish(types.EventTypeAppStatus, map[string]string{"state": "ready"})
+	b.Publish(types.EventTypeAppStatus, map[string]string{"state": "degraded"})
+
+	first := <-sub.Events()
+	second := <-sub.Events()
+	req.Equal(types.EventTypeAppStatus, first.Type)
+	req.JSONEq(`{"state":"ready"}`, string(first.Data))
+	req.Equal(first.ID+1, second.ID)
+	b.Unsubscribe(sub)
+
+	// resume after the first event
+	_, missed, resumed = b.Subscribe(strconv.FormatUint(first.ID, 10))
+	req.True(resumed)
+	req.Len(missed, 1)
+	req.Equal(second.ID, missed[0].ID)
+
+	// resume after the last event
+	_, missed, resumed = b.Subscribe(strconv.FormatUint(second.ID, 10))
+	req.True(resumed)
+	req.Empty(missed)
+
+	// unknown ids cannot be resumed
+	_, _, resumed = b.Subscribe("1")
+	req.False(resumed)
+	_, _, resumed = b.Subscribe("invalid")
+	req.False(resumed)
+}
+
+func TestBus_ReplayBufferSize(t *testing.T) {
+	req := require.New(t)
+
+	b := NewBus()
+	first := b.LastEventID() + 1
+	for i := 0; i < ReplayBufferSize+1; i++ {
+		b.Publish(types.EventTypeAppStatus, i)
+	}
+
+	_, _, resumed := b.Subscribe(strconv.FormatUint(first, 10))
+	req.False(resumed, "the oldest event is no longer in the replay buffer")
+
+	_, missed, resumed := b.Subscribe(strconv.FormatUint(first+1, 10))
+	req.True(resumed)
+	req.Len(missed, ReplayBufferSize-1)
+}
+
+func TestBus_DropsSlowSubscribers(t *testing.T) {
+	req := require.New(t)
+
+	b := NewBus()
+	slow, _, _ := b.Subscribe("")
+	fast, _, _ := b.Subscribe("")
+
+	for i := 0; i < subscriberBufferSize+1; i++ {
+		b.Publish(types.EventTypeAppStatus, i)
+		<-fast.Events()
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		req.Fail("slow subscriber was not dropped")
+	}
+	select {
+	case <-fast.Done():
+		req.Fail("fast subscriber was dropped")
+	default:
+	}
+}
+
+func TestBus_Shutdown(t *testing.T) {
+	req := require.New(t)
+
+	b := NewBus()
+	sub, _, _ := b.Subscribe("")
+
+	b.Shutdown()
+	<-sub.Done()
+
+	late, _, _ := b.Subscribe("")
+	<-late.Done()
+
+	b.Publish(types.EventTypeAppStatus, "ignored")
+	req.Empty(sub.Events())
+}
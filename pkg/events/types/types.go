@@ -0,0 +1,35 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventTypeAppStatus is published when the app status changes, the data is the new app status
+	EventTypeAppStatus EventType = "app-status"
+	// EventTypeAppState is published when the app state changes, the data is the app status transition
+	EventTypeAppState EventType = "app-state"
+	// EventTypeUpdates is published when new updates become available, the data is the list of new updates
+	EventTypeUpdates EventType = "updates"
+	// EventTypeLicense is published when a new license sequence is stored, the data is a LicenseEvent
+	EventTypeLicense EventType = "license"
+)
+
+// EventTypes are the event types that are published.
+var EventTypes = []EventType{EventTypeAppStatus, EventTypeAppState, EventTypeUpdates, EventTypeLicense}
+
+type Event struct {
+	ID        uint64          `json:"id"`
+	Type      EventType       `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type LicenseEvent struct {
+	LicenseID       string `json:"licenseID"`
+	LicenseSequence int64  `json:"licenseSequence"`
+	LicenseType     string `json:"licenseType"`
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/pkg/errors"
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/config"
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	handlerstypes "github.com/replicatedhq/replicated-sdk/pkg/handlers/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/helm"
 	"github.com/replicatedhq/replicated-sdk/pkg/integration"
 	integrationtypes "github.com/replicatedhq/replicated-sdk/pkg/integration/types"
@@ -31,11 +34,14 @@ import (
 )
 
 type GetCurrentAppInfoResponse struct {
-	AppSlug        string              `json:"appSlug"`
-	AppName        string              `json:"appName"`
-	AppStatus      appstatetypes.State `json:"appStatus"`
-	HelmChartURL   string              `json:"helmChartURL,omitempty"`
-	CurrentRelease AppRelease          `json:"currentRelease"`
+	AppSlug        string                       `json:"appSlug"`
+	AppName        string                       `json:"appName"`
+	AppStatus      appstatetypes.State          `json:"appStatus"`
+	ResourceStates appstatetypes.ResourceStates `json:"resourceStates,omitempty"`
+	// StatusAggregation explains how the app status was aggregated when informer roles or an aggregation policy are configured
+	StatusAggregation *appstatetypes.StateAggregation `json:"statusAggregation,omitempty"`
+	HelmChartURL      string                          `json:"helmChartURL,omitempty"`
+	CurrentRelease    AppRelease                      `json:"currentRelease"`
 }
 
 type GetAppHistoryResponse struct {
@@ -58,6 +64,15 @@ type SendCustomAppMetricsRequest struct {
 
 type CustomAppMetricsData map[string]interface{}
 
+type GetCustomAppMetricsResponse struct {
+	Metrics []custommetricstypes.Series `json:"metrics"`
+}
+
+type SendCustomAppMetricsErrorResponse struct {
+	Error  string                               `json:"error"`
+	Errors []custommetricstypes.ValidationError `json:"errors"`
+}
+
 type SendAppInstanceTagsRequest struct {
 	Data types.InstanceTagData `json:"data"`
 }
@@ -101,11 +116,14 @@ func GetCurrentAppInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	appStatus := store.GetStore().GetAppStatus()
 	response := GetCurrentAppInfoResponse{
-		AppSlug:      store.GetStore().GetAppSlug(),
-		AppName:      store.GetStore().GetAppName(),
-		AppStatus:    store.GetStore().GetAppStatus().State,
-		HelmChartURL: helm.GetParentChartURL(),
+		AppSlug:           store.GetStore().GetAppSlug(),
+		AppName:           store.GetStore().GetAppName(),
+		AppStatus:         appStatus.State,
+		ResourceStates:    appStatus.ResourceStates,
+		StatusAggregation: appStatus.Aggregation,
+		HelmChartURL:      helm.GetParentChartURL(),
 		CurrentRelease: AppRelease{
 			VersionLabel: store.GetStore().GetVersionLabel(),
 			CreatedAt:    store.GetStore().GetReleaseCreatedAt(),
@@ -351,6 +369,14 @@ func SendCustomAppMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if validationErrors := custommetrics.Validate(request.Data); len(validationErrors) > 0 {
+		JSON(w, http.StatusBadRequest, SendCustomAppMetricsErrorResponse{
+			Error:  "invalid custom metrics",
+			Errors: validationErrors,
+		})
+		return
+	}
+
 	clientset, err := k8sutil.GetClientset()
 	if err != nil {
 		logger.Error(errors.Wrap(err, "failed to get clientset"))
@@ -358,13 +384,61 @@ func SendCustomAppMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	submission := custommetricstypes.Submission{
+		Timestamp: time.Now().UTC(),
+		Data:      request.Data,
+	}
+
+	status := http.StatusOK
 	if err := report.SendCustomAppMetrics(clientset, store.GetStore(), request.Data); err != nil {
-		logger.Error(errors.Wrap(err, "set application data"))
-		w.WriteHeader(http.StatusBadRequest)
+		switch {
+		case report.IsMetricsBuffered(err):
+			// the metrics will be sent with the next batch
+			status = http.StatusAccepted
+		case report.IsEventQueued(err):
+			// the metrics will be sent once replicated.app is reachable again
+			logger.Infof("custom app metrics were queued: %v", err)
+			status = http.StatusAccepted
+		default:
+			logger.Error(errors.Wrap(err, "set application data"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the history is checkpointed on an interval, not per submission, so that batching does not write a secret for every request
+	store.GetStore().AddCustomAppMetrics(submission)
+
+	JSON(w, status, "")
+}
+
+// GetCustomAppMetrics returns the custom app metrics that were recently reported, oldest first.
+// The optional "key" query parameter (repeatable) limits the metrics, and "from" and "to" (RFC 3339) limit the time range.
+// The optional "aggregation" query parameter (last, min, max or avg) reduces the values of each metric to a single value.
+func GetCustomAppMetrics(w http.ResponseWriter, r *http.Request) {
+	from, err := parseTimeQueryParam(r, "from")
+	if err != nil {
+		JSON(w, http.StatusBadRequest, handlerstypes.ErrorResponse{Error: err.Error()})
+		return
+	}
+	to, err := parseTimeQueryParam(r, "to")
+	if err != nil {
+		JSON(w, http.StatusBadRequest, handlerstypes.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	JSON(w, http.StatusOK, "")
+	series, err := custommetrics.Query(store.GetStore().GetCustomAppMetricsHistory(), custommetrics.QueryOptions{
+		Keys:        r.URL.Query()["key"],
+		From:        from,
+		To:          to,
+		Aggregation: custommetricstypes.Aggregation(r.URL.Query().Get("aggregation")),
+	})
+	if err != nil {
+		JSON(w, http.StatusBadRequest, handlerstypes.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	JSON(w, http.StatusOK, GetCustomAppMetricsResponse{Metrics: series})
 }
 
 func validateCustomAppMetricsData(data CustomAppMetricsData) error {
@@ -391,6 +465,11 @@ func validateCustomAppMetricsData(data CustomAppMetricsData) error {
 	return nil
 }
 
+// GetCustomAppMetricsSchema returns the declared custom metrics that the app can report.
+func GetCustomAppMetricsSchema(w http.ResponseWriter, r *http.Request) {
+	JSON(w, http.StatusOK, custommetrics.GetSchema())
+}
+
 func SendAppInstanceTags(w http.ResponseWriter, r *http.Request) {
 	request := SendAppInstanceTagsRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -420,7 +499,7 @@ func SendAppInstanceTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := report.SendInstanceData(clientset, store.GetStore()); err != nil {
+	if err := report.SendInstanceData(clientset, store.GetStore()); err != nil && !report.IsEventQueued(err) {
 		logger.Errorf("failed to send instance data: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/events"
+	eventstypes "github.com/replicatedhq/replicated-sdk/pkg/events/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/handlers/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+)
+
+// streamKeepAliveInterval is how often a comment is sent on idle streams so that proxies don't close the connection
+var streamKeepAliveInterval = 15 * time.Second
+
+type GetAppStatusHistoryResponse struct {
+	Transitions []appstatetypes.AppStatusTransition `json:"transitions"`
+}
+
+// GetAppStatusHistory returns the app state transitions, oldest first.
+// The optional "from" and "to" query parameters (RFC 3339) limit the transitions to a time range.
+func GetAppStatusHistory(w http.ResponseWriter, r *http.Request) {
+	from, err := parseTimeQueryParam(r, "from")
+	if err != nil {
+		JSON(w, http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	to, err := parseTimeQueryParam(r, "to")
+	if err != nil {
+		JSON(w, http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response := GetAppStatusHistoryResponse{
+		Transitions: []appstatetypes.AppStatusTransition{},
+	}
+	for _, transition := range store.GetStore().GetAppStatusHistory() {
+		if !from.IsZero() && transition.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && transition.Timestamp.After(to) {
+			continue
+		}
+		response.Transitions = append(response.Transitions, transition)
+	}
+
+	JSON(w, http.StatusOK, response)
+}
+
+// StreamAppStatus streams app status and license changes as server-sent events.
+// A client that reconnects with the "Last-Event-ID" header (or the "lastEventId" query parameter) receives the events it missed.
+// Otherwise, or if the events are no longer available, the stream starts with the current app status and license.
+func StreamAppStatus(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		JSON(w, http.StatusInternalServerError, types.ErrorResponse{Error: "streaming is not supported"})
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	snapshotID := events.LastEventID()
+	sub, missed, resumed := events.Subscribe(lastEventID)
+	defer events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumed {
+		missed = currentStatusEvents(snapshotID)
+	}
+	for _, event := range missed {
+		if err := writeServerSentEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			return
+		case event := <-sub.Events():
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func currentStatusEvents(id uint64) []eventstypes.Event {
+	now := time.Now().UTC()
+	result := []eventstypes.Event{}
+
+	if data, err := json.Marshal(store.GetStore().GetAppStatus()); err != nil {
+		logger.Error(errors.Wrap(err, "failed to marshal app status"))
+	} else {
+		result = append(result, eventstypes.Event{ID: id, Type: eventstypes.EventTypeAppStatus, Timestamp: now, Data: data})
+	}
+
+	if license := store.GetStore().GetLicense(); license != nil {
+		data, err := json.Marshal(eventstypes.LicenseEvent{
+			LicenseID:       license.Spec.LicenseID,
+			LicenseSequence: license.Spec.LicenseSequence,
+			LicenseType:     license.Spec.LicenseType,
+		})
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to marshal license event"))
+		} else {
+			result = append(result, eventstypes.Event{ID: id, Type: eventstypes.EventTypeLicense, Timestamp: now, Data: data})
+		}
+	}
+
+	return result
+}
+
+func writeServerSentEvent(w io.Writer, event eventstypes.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+func parseTimeQueryParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid %s time %q, expected RFC 3339 format", name, value)
+	}
+	return t, nil
+}
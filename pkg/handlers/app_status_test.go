@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAppStatusHistory(t *testing.T) {
+	s := &store.InMemoryStore{}
+	store.SetStore(s)
+	defer store.SetStore(nil)
+
+	start := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	s.AddAppStatusTransition(appstatetypes.AppStatusTransition{Timestamp: start, State: appstatetypes.StateReady})
+	s.AddAppStatusTransition(appstatetypes.AppStatusTransition{Timestamp: start.Add(10 * time.Minute), PreviousState: appstatetypes.StateReady, State: appstatetypes.StateDegraded})
+	s.AddAppStatusTransition(appstatetypes.AppStatusTransition{Timestamp: start.Add(22 * time.Minute), PreviousState: appstatetypes.StateDegraded, State: appstatetypes.StateReady})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantStates []appstatetypes.State
+	}{
+		{
+			name:       "all transitions",
+			wantStatus: http.StatusOK,
+			wantStates: []appstatetypes.State{appstatetypes.StateReady, appstatetypes.StateDegraded, appstatetypes.StateReady},
+		},
+		{
+			name:       "from",
+			query:      "?from=2024-01-01T03:10:00Z",
+			wantStatus: http.StatusOK,
+			wantStates: []appstatetypes.State{appstatetypes.StateDegraded, appstatetypes.StateReady},
+		},
+		{
+			name:       "from and to",
+			query:      "?from=2024-01-01T03:05:00Z&to=2024-01-01T03:15:00Z",
+			wantStatus: http.StatusOK,
+			wantStates: []appstatetypes.State{appstatetypes.StateDegraded},
+		},
+		{
+			name:       "no transitions in range",
+			query:      "?to=2024-01-01T02:00:00Z",
+			wantStatus: http.StatusOK,
+			wantStates: []appstatetypes.State{},
+		},
+		{
+			name:       "invalid time",
+			query:      "?from=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			w := httptest.NewRecorder()
+			GetAppStatusHistory(w, httptest.NewRequest("GET", "/api/v1/app/status/history"+tt.query, nil))
+			req.Equal(tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response GetAppStatusHistoryResponse
+			req.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+			states := []appstatetypes.State{}
+			for _, transition := range response.Transitions {
+				states = append(states, transition.State)
+			}
+			req.Equal(tt.wantStates, states)
+		})
+	}
+}
+
+func TestStreamAppStatus(t *testing.T) {
+	req := require.New(t)
+
+	s := &store.InMemoryStore{}
+	store.SetStore(s)
+	defer store.SetStore(nil)
+
+	s.SetAppStatus(appstatetypes.AppStatus{AppSlug: "app-slug", State: appstatetypes.StateUpdating})
+
+	srv := httptest.NewServer(http.HandlerFunc(StreamAppStatus))
+	defer srv.Close()
+
+	type sse struct {
+		id, event, data string
+	}
+	readEvent := func(r *bufio.Reader) sse {
+		var e sse
+		for {
+			line, err := r.ReadString('\n')
+			req.NoError(err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return e
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	resp, err := http.Get(srv.URL)
+	req.NoError(err)
+	defer resp.Body.Close()
+	req.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	body := bufio.NewReader(resp.Body)
+
+	// the stream starts with the current status
+	current := readEvent(body)
+	req.Equal("app-status", current.event)
+	req.Contains(current.data, `"state":"updating"`)
+
+	s.SetAppStatus(appstatetypes.AppStatus{AppSlug: "app-slug", State: appstatetypes.StateReady})
+	ready := readEvent(body)
+	req.Equal("app-status", ready.event)
+	req.Contains(ready.data, `"state":"ready"`)
+
+	s.SetAppStatus(appstatetypes.AppStatus{AppSlug: "app-slug", State: appstatetypes.StateDegraded})
+	degraded := readEvent(body)
+	req.Contains(degraded.data, `"state":"degraded"`)
+
+	// a reconnecting client receives the events it missed
+	r, err := http.NewRequest("GET", srv.URL, nil)
+	req.NoError(err)
+	r.Header.Set("Last-Event-ID", ready.id)
+	resumed, err := http.DefaultClient.Do(r)
+	req.NoError(err)
+	defer resumed.Body.Close()
+
+	missed := readEvent(bufio.NewReader(resumed.Body))
+	req.Equal(degraded.id, missed.id)
+	req.Contains(missed.data, `"state":"degraded"`)
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
 	"github.com/stretchr/testify/require"
 )
 
@@ -64,3 +72,68 @@ func Test_validateCustomAppMetricsData(t *testing.T) {
 		})
 	}
 }
+
+func TestSendCustomAppMetricsValidation(t *testing.T) {
+	req := require.New(t)
+
+	req.NoError(custommetrics.Init([]custommetricstypes.MetricDefinition{
+		{Name: "activeUsers", Type: custommetricstypes.MetricTypeGauge},
+	}))
+	t.Cleanup(func() { custommetrics.Init(nil) })
+
+	w := httptest.NewRecorder()
+	SendCustomAppMetrics(w, httptest.NewRequest("POST", "/api/v1/app/custom-metrics", strings.NewReader(`{"data": {"activeUsers": "many", "numProjects": 10}}`)))
+	req.Equal(http.StatusBadRequest, w.Code)
+
+	var response SendCustomAppMetricsErrorResponse
+	req.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	req.Equal([]custommetricstypes.ValidationError{
+		{Metric: "activeUsers", Code: custommetricstypes.ValidationErrorInvalidType, Message: "activeUsers is a gauge and must be a number"},
+		{Metric: "numProjects", Code: custommetricstypes.ValidationErrorUnknownMetric, Message: "numProjects is not a declared custom metric"},
+	}, response.Errors)
+
+	w = httptest.NewRecorder()
+	GetCustomAppMetricsSchema(w, httptest.NewRequest("GET", "/api/v1/app/custom-metrics/schema", nil))
+	req.Equal(http.StatusOK, w.Code)
+
+	var schema custommetricstypes.Schema
+	req.NoError(json.Unmarshal(w.Body.Bytes(), &schema))
+	req.True(schema.Strict)
+	req.Len(schema.Metrics, 1)
+	req.Equal("activeUsers", schema.Metrics[0].Name)
+}
+
+func TestGetCustomAppMetrics(t *testing.T) {
+	req := require.New(t)
+
+	s := &store.InMemoryStore{}
+	store.SetStore(s)
+	defer store.SetStore(nil)
+
+	start := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	s.AddCustomAppMetrics(custommetricstypes.Submission{Timestamp: start, Data: map[string]interface{}{"activeUsers": float64(10), "numProjects": float64(1)}})
+	s.AddCustomAppMetrics(custommetricstypes.Submission{Timestamp: start.Add(10 * time.Minute), Data: map[string]interface{}{"activeUsers": float64(20)}})
+
+	w := httptest.NewRecorder()
+	GetCustomAppMetrics(w, httptest.NewRequest("GET", "/api/v1/app/custom-metrics?key=activeUsers&aggregation=max", nil))
+	req.Equal(http.StatusOK, w.Code)
+
+	var response GetCustomAppMetricsResponse
+	req.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	req.Equal([]custommetricstypes.Series{
+		{Key: "activeUsers", Count: 2, Aggregation: custommetricstypes.AggregationMax, Value: float64(20)},
+	}, response.Metrics)
+
+	w = httptest.NewRecorder()
+	GetCustomAppMetrics(w, httptest.NewRequest("GET", "/api/v1/app/custom-metrics?from=2024-01-01T03:05:00Z", nil))
+	req.Equal(http.StatusOK, w.Code)
+
+	response = GetCustomAppMetricsResponse{}
+	req.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	req.Len(response.Metrics, 1)
+	req.Equal([]custommetricstypes.Sample{{Timestamp: start.Add(10 * time.Minute), Value: float64(20)}}, response.Metrics[0].Samples)
+
+	w = httptest.NewRecorder()
+	GetCustomAppMetrics(w, httptest.NewRequest("GET", "/api/v1/app/custom-metrics?aggregation=sum", nil))
+	req.Equal(http.StatusBadRequest, w.Code)
+}
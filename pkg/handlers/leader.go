@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/handlers/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	forwardedFromHeader = "X-Replicated-Forwarded-From"
+	// replicaTokenHeader authenticates requests forwarded by another replica, the forwarded from header is not trusted without it
+	replicaTokenHeader = "X-Replicated-Replica-Token"
+)
+
+var (
+	leaderTransport http.RoundTripper
+)
+
+// SetLeaderTLSConfig sets the tls config used to forward requests to the leader when the api is served over TLS
+func SetLeaderTLSConfig(tlsConfig *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	leaderTransport = transport
+}
+
+// ForwardToLeader proxies requests that write state (e.g. the report secrets) to the leader replica
+// when running in high-availability mode. All other replicas only serve read-only requests.
+func ForwardToLeader(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		forwarded := isForwardedByReplica(r)
+		if !forwarded {
+			// the headers can be set by any client, they are only trusted from another replica
+			r.Header.Del(forwardedFromHeader)
+			r.Header.Del(replicaTokenHeader)
+		}
+
+		if leader.IsLeader() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if forwarded {
+			// the replica that forwarded the request considers this replica the leader, but it is not (anymore).
+			// the request is not forwarded again so that it can't loop between replicas.
+			JSON(w, http.StatusServiceUnavailable, types.ErrorResponse{Error: "leader is not available"})
+			return
+		}
+
+		leaderURL, err := getLeaderURL(r)
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to get leader url"))
+			JSON(w, http.StatusServiceUnavailable, types.ErrorResponse{Error: "leader is not available"})
+			return
+		}
+
+		r.Header.Set(forwardedFromHeader, os.Getenv("REPLICATED_POD_NAME"))
+		r.Header.Set(replicaTokenHeader, leader.GetReplicaToken())
+		proxy := httputil.NewSingleHostReverseProxy(leaderURL)
+		if leaderURL.Scheme == "https" {
+			proxy.Transport = leaderTransport
+		}
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+// isForwardedByReplica returns true if the request was forwarded by another replica of the deployment.
+func isForwardedByReplica(r *http.Request) bool {
+	return r.Header.Get(forwardedFromHeader) != "" && leader.IsReplicaToken(r.Header.Get(replicaTokenHeader))
+}
+
+func getLeaderURL(r *http.Request) (*url.URL, error) {
+	identity := leader.GetLeaderIdentity()
+	if identity == "" {
+		return nil, errors.New("no leader has been elected")
+	}
+
+	clientset, err := k8sutil.GetClientset()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get clientset")
+	}
+
+	pod, err := clientset.CoreV1().Pods(store.GetStore().GetNamespace()).Get(r.Context(), identity, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get leader pod %s", identity)
+	}
+	if pod.Status.PodIP == "" {
+		return nil, errors.Errorf("leader pod %s has no ip", identity)
+	}
+
+	// all replicas listen on the same port, so use the port this request was received on
+	localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return nil, errors.New("failed to get local address")
+	}
+	_, port, err := net.SplitHostPort(localAddr.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse local address")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(pod.Status.PodIP, port),
+	}, nil
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardToLeader_UntrustedHeaders(t *testing.T) {
+	req := require.New(t)
+
+	var forwardedFrom, replicaToken string
+	handler := ForwardToLeader(func(w http.ResponseWriter, r *http.Request) {
+		forwardedFrom = r.Header.Get(forwardedFromHeader)
+		replicaToken = r.Header.Get(replicaTokenHeader)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// the headers of a request that was not forwarded by a replica are dropped
+	r := httptest.NewRequest("POST", "/api/v1/app/custom-metrics", nil)
+	r.Header.Set(forwardedFromHeader, "replicated-pod-2")
+	r.Header.Set(replicaTokenHeader, "forged")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	req.Equal(http.StatusOK, w.Code)
+	req.Empty(forwardedFrom)
+	req.Empty(replicaToken)
+}
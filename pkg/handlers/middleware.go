@@ -3,7 +3,11 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/auth"
 	"github.com/replicatedhq/replicated-sdk/pkg/handlers/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
 	"github.com/replicatedhq/replicated-sdk/pkg/store"
 )
 
@@ -26,17 +30,43 @@ func EnforceMockAccess(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func RequireValidLicenseIDMiddleware(next http.Handler) http.Handler {
+// RequireAuthMiddleware authenticates requests using the auth modes configured for the matched route
+func RequireAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		licenseID := r.Header.Get("authorization")
-		if licenseID == "" {
-			response := types.ErrorResponse{Error: "missing authorization header"}
-			JSON(w, http.StatusUnauthorized, response)
+		route := r.URL.Path
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			if pathTemplate, err := currentRoute.GetPathTemplate(); err == nil {
+				route = pathTemplate
+			}
+		}
+
+		if err := auth.GetAuthenticator().Authenticate(r, route); err != nil {
+			// the details are logged instead of returned, they can include file paths and api errors
+			if auth.IsUnavailable(err) {
+				logger.Error(errors.Wrapf(err, "failed to authenticate request to %s", route))
+				JSON(w, http.StatusServiceUnavailable, types.ErrorResponse{Error: "authentication is unavailable"})
+				return
+			}
+			logger.Debugf("unauthorized request to %s: %v", route, err)
+			JSON(w, http.StatusUnauthorized, types.ErrorResponse{Error: "unauthorized"})
 			return
 		}
 
-		if store.GetStore().GetLicense().Spec.LicenseID != licenseID {
-			response := types.ErrorResponse{Error: "license ID is not valid"}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireClientCertMiddleware rejects requests that did not present a verified client certificate (mTLS).
+// Client certificates are optional in the TLS handshake so that routes like /healthz can be reached by kubelet probes.
+// Requests forwarded by another replica are authenticated by the replica token instead, the client was verified by that replica.
+func RequireClientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && isForwardedByReplica(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			response := types.ErrorResponse{Error: "a valid client certificate is required"}
 			JSON(w, http.StatusUnauthorized, response)
 			return
 		}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/replicated-sdk/pkg/auth"
+	authtypes "github.com/replicatedhq/replicated-sdk/pkg/auth/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAuthMiddleware(t *testing.T) {
+	store.InitInMemory(store.InitInMemoryStoreOptions{
+		License: &kotsv1beta1.License{
+			Spec: kotsv1beta1.LicenseSpec{
+				LicenseID: "license-id",
+			},
+		},
+	})
+
+	// the token file does not exist, so bearer tokens cannot be checked
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, auth.Init(authtypes.AuthConfig{
+		DefaultModes:    []authtypes.AuthMode{authtypes.AuthModeLicenseID, authtypes.AuthModeBearerToken},
+		BearerTokenFile: tokenFile,
+	}, nil, "default"))
+	defer auth.Init(authtypes.AuthConfig{}, nil, "default")
+
+	handler := RequireAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{
+			name:          "authorized",
+			authorization: "license-id",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:       "missing authorization",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "auth backend unavailable",
+			authorization: "Bearer token",
+			wantStatus:    http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/app/info", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			// internal errors are not returned to the client
+			require.NotContains(t, w.Body.String(), tokenFile)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/report"
+)
+
+// GetOutbox returns the number of online events that failed to send and are waiting to be retried, and the age of the oldest event.
+func GetOutbox(w http.ResponseWriter, r *http.Request) {
+	JSON(w, http.StatusOK, report.GetOutboxStatus())
+}
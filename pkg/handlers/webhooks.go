@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/webhook"
+	webhooktypes "github.com/replicatedhq/replicated-sdk/pkg/webhook/types"
+)
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []webhooktypes.Delivery `json:"deliveries"`
+}
+
+// GetWebhookDeliveries returns the most recent webhook deliveries, oldest first.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	JSON(w, http.StatusOK, GetWebhookDeliveriesResponse{
+		Deliveries: webhook.GetDeliveries(),
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
 	sdklicense "github.com/replicatedhq/replicated-sdk/pkg/license"
 	"github.com/replicatedhq/replicated-sdk/pkg/logger"
 	"github.com/replicatedhq/replicated-sdk/pkg/report"
@@ -61,16 +60,7 @@ func Start() error {
 			}
 		}
 
-		go func() {
-			clientset, err := k8sutil.GetClientset()
-			if err != nil {
-				logger.Error(errors.Wrap(err, "failed to get clientset"))
-				return
-			}
-			if err := report.SendInstanceData(clientset, store.GetStore()); err != nil {
-				logger.Error(errors.Wrap(err, "failed to send instance data"))
-			}
-		}()
+		report.SendInstanceDataAsync(store.GetStore())
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to add func")
@@ -0,0 +1,135 @@
+package helm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/config"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// releasePollInterval is how often the release is checked for storage drivers that cannot be watched
+	releasePollInterval = time.Minute
+)
+
+// WatchRelease calls onRevision with each new deployed revision of the release after sinceRevision until the context is done.
+// The release storage (secrets or configmaps) is watched, other storage drivers are polled.
+func WatchRelease(ctx context.Context, clientset kubernetes.Interface, sinceRevision int, onRevision func(*release.Release)) {
+	lastRevision := sinceRevision
+	checkRelease := func() {
+		helmRelease, err := GetRelease(GetReleaseName())
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to get helm release"))
+			return
+		}
+		if helmRelease == nil || helmRelease.Version <= lastRevision {
+			return
+		}
+		// wait for upgrades to complete, the revision is updated when it is deployed
+		if helmRelease.Info == nil || helmRelease.Info.Status != release.StatusDeployed {
+			return
+		}
+		lastRevision = helmRelease.Version
+		logger.Infof("detected revision %d of helm release %s", helmRelease.Version, helmRelease.Name)
+		onRevision(helmRelease)
+	}
+
+	namespace := GetReleaseNamespace()
+	selector := labels.Set{"owner": "helm", "name": GetReleaseName()}.String()
+
+	var listwatch *cache.ListWatch
+	var objType runtime.Object
+	switch strings.ToLower(GetHelmDriver()) {
+	case "", "secret", "secrets":
+		listwatch = &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				return clientset.CoreV1().Secrets(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return clientset.CoreV1().Secrets(namespace).Watch(context.TODO(), options)
+			},
+		}
+		objType = &corev1.Secret{}
+	case "configmap", "configmaps":
+		listwatch = &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				return clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return clientset.CoreV1().ConfigMaps(namespace).Watch(context.TODO(), options)
+			},
+		}
+		objType = &corev1.ConfigMap{}
+	default:
+		ticker := time.NewTicker(releasePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkRelease()
+			}
+		}
+	}
+
+	informer := cache.NewSharedInformer(listwatch, objType, 0)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			checkRelease()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			checkRelease()
+		},
+	})
+	informer.Run(ctx.Done())
+}
+
+// GetReplicatedConfig returns the replicated config from the replicated secret in the release manifest,
+// or nil if the release does not contain it.
+func GetReplicatedConfig(helmRelease *release.Release) (*config.ReplicatedConfig, error) {
+	for _, doc := range strings.Split(helmRelease.Manifest, "\n---\n") {
+		if doc == "" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		_, gvk, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(doc), nil, obj)
+		if err != nil {
+			continue
+		}
+		if gvk.Group != "" || gvk.Version != "v1" || gvk.Kind != "Secret" || obj.GetName() != util.GetReplicatedSecretName() {
+			continue
+		}
+
+		configFile, ok, _ := unstructured.NestedString(obj.Object, "stringData", "config.yaml")
+		if !ok {
+			return nil, nil
+		}
+		replicatedConfig, err := config.ParseReplicatedConfig([]byte(configFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse replicated config")
+		}
+		return replicatedConfig, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,70 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestGetReplicatedConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []types.StatusInformerConfig
+		wantNil  bool
+	}{
+		{
+			name: "replicated secret",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: replicated
+stringData:
+  config.yaml: |
+    releaseSequence: 4
+    statusInformers:
+    - deployment/web
+`,
+			want: []types.StatusInformerConfig{{Informer: "deployment/web"}},
+		},
+		{
+			name: "other secret",
+			manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: other
+stringData:
+  config.yaml: |
+    releaseSequence: 4
+`,
+			wantNil: true,
+		},
+		{
+			name:     "no replicated secret",
+			manifest: "",
+			wantNil:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			replicatedConfig, err := GetReplicatedConfig(&release.Release{Manifest: tt.manifest})
+			req.NoError(err)
+			if tt.wantNil {
+				req.Nil(replicatedConfig)
+				return
+			}
+			req.NotNil(replicatedConfig)
+			req.Equal(int64(4), replicatedConfig.ReleaseSequence)
+			req.Equal(tt.want, replicatedConfig.StatusInformers)
+		})
+	}
+}
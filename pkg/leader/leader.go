@@ -0,0 +1,142 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+var (
+	enabled  atomic.Bool
+	isLeader atomic.Bool
+
+	elector    *leaderelection.LeaderElector
+	electorMtx sync.Mutex
+)
+
+type RunOptions struct {
+	Clientset kubernetes.Interface
+	Namespace string
+	// Identity uniquely identifies this replica, this is the name of the pod the sdk is running in
+	Identity string
+	// OnStartedLeading is called when this replica becomes the leader
+	OnStartedLeading func()
+	// OnStoppedLeading is called when this replica stops being the leader
+	OnStoppedLeading func()
+}
+
+// Enable marks leader election as enabled before it runs, so that a replica does not act as the leader
+// (e.g. write the store checkpoint or send webhooks) while it is starting and has not been elected yet.
+func Enable() {
+	enabled.Store(true)
+}
+
+// Disable marks leader election as disabled, the replica is the only one and always the leader.
+func Disable() {
+	enabled.Store(false)
+	isLeader.Store(false)
+}
+
+// IsEnabled returns true if leader election is running, i.e. the sdk is running in high-availability mode.
+func IsEnabled() bool {
+	return enabled.Load()
+}
+
+// IsLeader returns true if this replica is responsible for the singleton tasks (heartbeat, status informers and reporting).
+// When leader election is disabled, the only replica is always the leader.
+func IsLeader() bool {
+	return !enabled.Load() || isLeader.Load()
+}
+
+// GetLeaderIdentity returns the identity of the current leader as last observed by this replica.
+func GetLeaderIdentity() string {
+	electorMtx.Lock()
+	defer electorMtx.Unlock()
+
+	if elector == nil {
+		return ""
+	}
+	return elector.GetLeader()
+}
+
+func GetLeaseName() string {
+	return fmt.Sprintf("%s-leader", util.GetReplicatedDeploymentName())
+}
+
+// Run participates in leader election using a Lease object until the context is cancelled.
+// If leadership is lost, the replica steps down and campaigns again.
+func Run(ctx context.Context, options RunOptions) error {
+	Enable()
+
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		options.Namespace,
+		GetLeaseName(),
+		options.Clientset.CoreV1(),
+		options.Clientset.CoordinationV1(),
+		resourcelock.ResourceLockConfig{
+			Identity: options.Identity,
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to create resource lock")
+	}
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            GetLeaseName(),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logger.Infof("%s became the leader", options.Identity)
+				isLeader.Store(true)
+				if options.OnStartedLeading != nil {
+					options.OnStartedLeading()
+				}
+			},
+			OnStoppedLeading: func() {
+				logger.Infof("%s stopped leading", options.Identity)
+				isLeader.Store(false)
+				if options.OnStoppedLeading != nil {
+					options.OnStoppedLeading()
+				}
+			},
+			OnNewLeader: func(identity string) {
+				if identity != options.Identity {
+					logger.Infof("%s is the leader", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create leader elector")
+	}
+
+	electorMtx.Lock()
+	elector = le
+	electorMtx.Unlock()
+
+	for ctx.Err() == nil {
+		le.Run(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun(t *testing.T) {
+	req := require.New(t)
+
+	req.False(IsEnabled())
+	req.True(IsLeader(), "a single replica is always the leader")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		err := Run(ctx, RunOptions{
+			Clientset:        fake.NewSimpleClientset(),
+			Namespace:        "default",
+			Identity:         "replicated-pod-1",
+			OnStartedLeading: func() { close(started) },
+			OnStoppedLeading: func() { close(stopped) },
+		})
+		req.NoError(err)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting to become the leader")
+	}
+
+	req.True(IsEnabled())
+	req.True(IsLeader())
+	req.Equal("replicated-pod-1", GetLeaderIdentity())
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting to stop leading")
+	}
+	<-done
+
+	req.False(IsLeader())
+}
+
+func TestEnable(t *testing.T) {
+	req := require.New(t)
+	defer Disable()
+
+	// a replica that has not been elected yet is not the leader
+	Enable()
+	req.True(IsEnabled())
+	req.False(IsLeader())
+
+	Disable()
+	req.False(IsEnabled())
+	req.True(IsLeader())
+}
@@ -0,0 +1,127 @@
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ReplicaTokenSecretName = "replicated-replica-token"
+	ReplicaTokenSecretKey  = "token"
+)
+
+var (
+	replicaToken    string
+	replicaTokenMtx sync.RWMutex
+)
+
+// InitReplicaToken loads the token that the replicas of the deployment share to authenticate the requests they forward to the leader.
+// The first replica to start generates the token, it is never updated so the secret only has to be created.
+func InitReplicaToken(ctx context.Context, clientset kubernetes.Interface, namespace string) error {
+	token, err := getReplicaToken(ctx, clientset, namespace)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		token, err = createReplicaToken(ctx, clientset, namespace)
+		if err != nil {
+			return err
+		}
+	}
+
+	replicaTokenMtx.Lock()
+	defer replicaTokenMtx.Unlock()
+	replicaToken = token
+
+	return nil
+}
+
+// GetReplicaToken returns the token that authenticates requests forwarded between replicas, or an empty string if it is not initialized.
+func GetReplicaToken() string {
+	replicaTokenMtx.RLock()
+	defer replicaTokenMtx.RUnlock()
+	return replicaToken
+}
+
+// IsReplicaToken returns true if the token is the token shared by the replicas of the deployment.
+func IsReplicaToken(token string) bool {
+	expected := GetReplicaToken()
+	if expected == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func getReplicaToken(ctx context.Context, clientset kubernetes.Interface, namespace string) (string, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, ReplicaTokenSecretName, metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to get replica token secret")
+	}
+	return string(secret.Data[ReplicaTokenSecretKey]), nil
+}
+
+func createReplicaToken(ctx context.Context, clientset kubernetes.Interface, namespace string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate replica token")
+	}
+	token := hex.EncodeToString(b)
+
+	uid, err := util.GetReplicatedDeploymentUID(clientset, namespace)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get replicated deployment uid")
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ReplicaTokenSecretName,
+			Namespace: namespace,
+			// since this secret is created by the replicated deployment, we should set the owner reference
+			// so that it is deleted when the replicated deployment is deleted
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       util.GetReplicatedDeploymentName(),
+					UID:        uid,
+				},
+			},
+		},
+		Data: map[string][]byte{
+			ReplicaTokenSecretKey: []byte(token),
+		},
+	}
+
+	if _, err := clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		if !kuberneteserrors.IsAlreadyExists(err) {
+			return "", errors.Wrap(err, "failed to create replica token secret")
+		}
+		// another replica created the token first
+		token, err = getReplicaToken(ctx, clientset, namespace)
+		if err != nil {
+			return "", err
+		}
+		if token == "" {
+			return "", errors.New("replica token secret is empty")
+		}
+	}
+
+	return token, nil
+}
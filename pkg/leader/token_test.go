@@ -0,0 +1,35 @@
+package leader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInitReplicaToken(t *testing.T) {
+	req := require.New(t)
+	t.Cleanup(func() { replicaToken = "" })
+
+	req.False(IsReplicaToken(""), "no token is valid before it is initialized")
+
+	clientset := fake.NewSimpleClientset(
+		k8sutil.CreateTestDeployment(util.GetReplicatedDeploymentName(), "default", "1", map[string]string{"app": "replicated"}),
+	)
+
+	// the first replica generates the token
+	req.NoError(InitReplicaToken(context.Background(), clientset, "default"))
+	token := GetReplicaToken()
+	req.Len(token, 64)
+	req.True(IsReplicaToken(token))
+	req.False(IsReplicaToken("forged"))
+	req.False(IsReplicaToken(""))
+
+	// other replicas load the same token
+	replicaToken = ""
+	req.NoError(InitReplicaToken(context.Background(), clientset, "default"))
+	req.Equal(token, GetReplicaToken())
+}
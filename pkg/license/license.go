@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/replicated-sdk/pkg/license/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/metrics"
 	"github.com/replicatedhq/replicated-sdk/pkg/report"
 	"github.com/replicatedhq/replicated-sdk/pkg/store"
 	"github.com/replicatedhq/replicated-sdk/pkg/util"
@@ -44,7 +45,9 @@ func GetLatestLicense(license *kotsv1beta1.License, endpoint string) (*LicenseDa
 	}
 	url := fmt.Sprintf("%s/license/%s", endpoint, license.Spec.AppSlug)
 
+	start := time.Now()
 	licenseData, err := getLicenseFromAPI(url, license.Spec.LicenseID)
+	metrics.ObserveUpstreamRequest(metrics.UpstreamOperationGetLatestLicense, time.Since(start), err)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get license from api")
 	}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+)
+
+const (
+	namespace = "replicated"
+
+	UpstreamOperationGetUpdates       = "get_updates"
+	UpstreamOperationGetLatestLicense = "get_latest_license"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	heartbeatsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeats_total",
+		Help:      "Number of instance data reports (heartbeats) sent, by result.",
+	}, []string{"result"})
+
+	heartbeatLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "heartbeat_last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful heartbeat.",
+	})
+
+	upstreamRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests to the Replicated API, by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	upstreamRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_errors_total",
+		Help:      "Number of failed requests to the Replicated API, by operation.",
+	}, []string{"operation"})
+
+	reportSecretSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "report_secret_size_bytes",
+		Help:      "Size of the encoded report stored in the report secret, by report type.",
+	}, []string{"report_type"})
+
+	reportEvents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "report_events",
+		Help:      "Number of events stored in the report secret, by report type.",
+	}, []string{"report_type"})
+
+	suppressedStateFlaps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "suppressed_state_flaps_total",
+		Help:      "Number of resource state changes that were not reported because the resource returned to its previous state within the damping duration, by resource kind and suppressed state.",
+	}, []string{"kind", "state"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		heartbeatsTotal,
+		heartbeatLastSuccess,
+		upstreamRequestDuration,
+		upstreamRequestErrors,
+		reportSecretSize,
+		reportEvents,
+		suppressedStateFlaps,
+		&appStatusCollector{},
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveHeartbeat(err error) {
+	if err != nil {
+		heartbeatsTotal.WithLabelValues("failure").Inc()
+		return
+	}
+	heartbeatsTotal.WithLabelValues("success").Inc()
+	heartbeatLastSuccess.SetToCurrentTime()
+}
+
+func ObserveUpstreamRequest(operation string, duration time.Duration, err error) {
+	upstreamRequestDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		upstreamRequestErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func SetReportStats(reportType string, sizeBytes int, events int) {
+	reportSecretSize.WithLabelValues(reportType).Set(float64(sizeBytes))
+	reportEvents.WithLabelValues(reportType).Set(float64(events))
+}
+
+func IncSuppressedStateFlaps(kind string, state appstatetypes.State) {
+	suppressedStateFlaps.WithLabelValues(kind, string(state)).Inc()
+}
+
+var (
+	appStates = []appstatetypes.State{
+		appstatetypes.StateReady,
+		appstatetypes.StateUpdating,
+		appstatetypes.StateDegraded,
+		appstatetypes.StateUnavailable,
+		appstatetypes.StateMissing,
+	}
+
+	appStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "app_status"),
+		"Current state of the application, 1 for the current state and 0 otherwise.",
+		[]string{"app_slug", "state"}, nil,
+	)
+
+	resourceStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "resource_status"),
+		"Current state of each resource watched by the status informers, 1 for the current state and 0 otherwise.",
+		[]string{"kind", "namespace", "name", "state"}, nil,
+	)
+)
+
+// appStatusCollector reads the app status from the store when scraped,
+// so that every replica reports the current status, including the replicas that are not running the informers.
+type appStatusCollector struct{}
+
+func (c *appStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- appStatusDesc
+	ch <- resourceStatusDesc
+}
+
+func (c *appStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	appStatus := store.GetStore().GetAppStatus()
+	if appStatus.State == "" {
+		return
+	}
+
+	for _, state := range appStates {
+		ch <- prometheus.MustNewConstMetric(appStatusDesc, prometheus.GaugeValue, stateValue(appStatus.State, state), appStatus.AppSlug, string(state))
+	}
+
+	for _, resourceState := range appStatus.ResourceStates {
+		for _, state := range appStates {
+			ch <- prometheus.MustNewConstMetric(resourceStatusDesc, prometheus.GaugeValue, stateValue(resourceState.State, state),
+				resourceState.Kind, resourceState.Namespace, resourceState.Name, string(state))
+		}
+	}
+}
+
+func stateValue(current appstatetypes.State, state appstatetypes.State) float64 {
+	if current == state {
+		return 1
+	}
+	return 0
+}
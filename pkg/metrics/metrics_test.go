@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveHeartbeat(t *testing.T) {
+	req := require.New(t)
+
+	ObserveHeartbeat(nil)
+	ObserveHeartbeat(errors.New("failed"))
+	ObserveHeartbeat(nil)
+
+	req.Equal(float64(2), testutil.ToFloat64(heartbeatsTotal.WithLabelValues("success")))
+	req.Equal(float64(1), testutil.ToFloat64(heartbeatsTotal.WithLabelValues("failure")))
+	req.InDelta(float64(time.Now().Unix()), testutil.ToFloat64(heartbeatLastSuccess), 5)
+}
+
+func TestObserveUpstreamRequest(t *testing.T) {
+	req := require.New(t)
+
+	ObserveUpstreamRequest(UpstreamOperationGetUpdates, time.Second, nil)
+	ObserveUpstreamRequest(UpstreamOperationGetUpdates, time.Second, errors.New("failed"))
+
+	req.Equal(1, testutil.CollectAndCount(upstreamRequestDuration))
+	req.Equal(float64(1), testutil.ToFloat64(upstreamRequestErrors.WithLabelValues(UpstreamOperationGetUpdates)))
+}
+
+func TestAppStatusCollector(t *testing.T) {
+	store.SetStore(&store.InMemoryStore{})
+	defer store.SetStore(nil)
+
+	store.GetStore().SetAppStatus(appstatetypes.AppStatus{
+		AppSlug: "my-app",
+		State:   appstatetypes.StateDegraded,
+		ResourceStates: appstatetypes.ResourceStates{
+			{Kind: "deployment", Namespace: "default", Name: "web", State: appstatetypes.StateDegraded},
+		},
+	})
+
+	expected := `
+# HELP replicated_app_status Current state of the application, 1 for the current state and 0 otherwise.
+# TYPE replicated_app_status gauge
+replicated_app_status{app_slug="my-app",state="degraded"} 1
+replicated_app_status{app_slug="my-app",state="missing"} 0
+replicated_app_status{app_slug="my-app",state="ready"} 0
+replicated_app_status{app_slug="my-app",state="unavailable"} 0
+replicated_app_status{app_slug="my-app",state="updating"} 0
+# HELP replicated_resource_status Current state of each resource watched by the status informers, 1 for the current state and 0 otherwise.
+# TYPE replicated_resource_status gauge
+replicated_resource_status{kind="deployment",name="web",namespace="default",state="degraded"} 1
+replicated_resource_status{kind="deployment",name="web",namespace="default",state="missing"} 0
+replicated_resource_status{kind="deployment",name="web",namespace="default",state="ready"} 0
+replicated_resource_status{kind="deployment",name="web",namespace="default",state="unavailable"} 0
+replicated_resource_status{kind="deployment",name="web",namespace="default",state="updating"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(&appStatusCollector{}, strings.NewReader(expected)))
+}
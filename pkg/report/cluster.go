@@ -0,0 +1,149 @@
+package report
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/report/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// cloudProviderLabelPrefixes are the prefixes of the labels that cloud providers set on their nodes
+var cloudProviderLabelPrefixes = map[string]string{
+	"eks.amazonaws.com/":       "aws",
+	"cloud.google.com/":        "gcp",
+	"kubernetes.azure.com/":    "azure",
+	"doks.digitalocean.com/":   "digitalocean",
+	"oci.oraclecloud.com/":     "oracle",
+	"ibm-cloud.kubernetes.io/": "ibm",
+}
+
+// cloudProviderIDSchemes are the schemes of the provider ids of nodes, for the providers whose name differs from the scheme
+var cloudProviderIDSchemes = map[string]string{
+	"gce": "gcp",
+	"oci": "oracle",
+}
+
+// ClusterInventoryCacheTTL is how long the cluster inventory is reused before the nodes are listed again,
+// since the instance data is gathered on every heartbeat, state change and custom metrics submission.
+const ClusterInventoryCacheTTL = 10 * time.Minute
+
+var clusterInventoryCache struct {
+	mtx       sync.Mutex
+	enabled   bool
+	inventory *types.ClusterInventory
+	expiresAt time.Time
+}
+
+// InitClusterInventory sets whether the cluster inventory is reported with the instance data and clears the cache.
+func InitClusterInventory(config types.ClusterInventoryConfig) {
+	clusterInventoryCache.mtx.Lock()
+	defer clusterInventoryCache.mtx.Unlock()
+
+	clusterInventoryCache.enabled = config.Enabled
+	clusterInventoryCache.inventory = nil
+	clusterInventoryCache.expiresAt = time.Time{}
+}
+
+// getCachedClusterInventory returns the cluster inventory if it is enabled, listing the nodes at most once per TTL.
+// Failures are cached as well so that a missing cluster role does not cause a request on every call.
+func getCachedClusterInventory(clientset kubernetes.Interface) *types.ClusterInventory {
+	clusterInventoryCache.mtx.Lock()
+	defer clusterInventoryCache.mtx.Unlock()
+
+	if !clusterInventoryCache.enabled {
+		return nil
+	}
+	if time.Now().Before(clusterInventoryCache.expiresAt) {
+		return clusterInventoryCache.inventory
+	}
+
+	inventory, err := GetClusterInventory(clientset)
+	if err != nil {
+		logger.Debugf("failed to get cluster inventory: %v", err.Error())
+	}
+	clusterInventoryCache.inventory = inventory
+	clusterInventoryCache.expiresAt = time.Now().Add(ClusterInventoryCacheTTL)
+
+	return inventory
+}
+
+// GetClusterInventory returns the capacity of the cluster and the hardware and software of its nodes.
+func GetClusterInventory(clientset kubernetes.Interface) (*types.ClusterInventory, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+	return buildClusterInventory(nodes.Items), nil
+}
+
+func buildClusterInventory(nodes []corev1.Node) *types.ClusterInventory {
+	inventory := &types.ClusterInventory{
+		NodeCount: len(nodes),
+	}
+
+	for _, node := range nodes {
+		inventory.CPUCapacityMillicores += node.Status.Capacity.Cpu().MilliValue()
+		inventory.CPUAllocatableMillicores += node.Status.Allocatable.Cpu().MilliValue()
+		inventory.MemoryCapacityBytes += node.Status.Capacity.Memory().Value()
+		inventory.MemoryAllocatableBytes += node.Status.Allocatable.Memory().Value()
+
+		inventory.Architectures = appendUnique(inventory.Architectures, node.Status.NodeInfo.Architecture)
+		inventory.OSImages = appendUnique(inventory.OSImages, node.Status.NodeInfo.OSImage)
+		inventory.ContainerRuntimeVersions = appendUnique(inventory.ContainerRuntimeVersions, node.Status.NodeInfo.ContainerRuntimeVersion)
+		inventory.CloudProviders = appendUnique(inventory.CloudProviders, nodeCloudProvider(node))
+		inventory.Regions = appendUnique(inventory.Regions, nodeLabel(node, corev1.LabelTopologyRegion, corev1.LabelFailureDomainBetaRegion))
+		inventory.Zones = appendUnique(inventory.Zones, nodeLabel(node, corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone))
+	}
+
+	return inventory
+}
+
+// nodeCloudProvider returns the cloud provider of the node from its labels, or from the scheme of its provider id.
+func nodeCloudProvider(node corev1.Node) string {
+	for key := range node.Labels {
+		for prefix, provider := range cloudProviderLabelPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return provider
+			}
+		}
+	}
+
+	scheme, _, found := strings.Cut(node.Spec.ProviderID, "://")
+	if !found || scheme == "" {
+		return ""
+	}
+	if provider, ok := cloudProviderIDSchemes[scheme]; ok {
+		return provider
+	}
+	return scheme
+}
+
+// nodeLabel returns the value of the first of the labels that is set on the node.
+func nodeLabel(node corev1.Node, keys ...string) string {
+	for _, key := range keys {
+		if value := node.Labels[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// appendUnique adds the value to the sorted list if it is not empty and not in the list already.
+func appendUnique(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	i, found := slices.BinarySearch(list, value)
+	if found {
+		return list
+	}
+	return slices.Insert(list, i, value)
+}
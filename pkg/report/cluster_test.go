@@ -0,0 +1,120 @@
+package report
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/replicated-sdk/pkg/report/types"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestNode(name string, labels map[string]string, providerID string, cpu string, memory string, nodeInfo corev1.NodeSystemInfo) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{ProviderID: providerID},
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+			NodeInfo: nodeInfo,
+		},
+	}
+}
+
+func TestGetClusterInventory(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset(
+		newTestNode("node-1", map[string]string{
+			"eks.amazonaws.com/nodegroup": "default",
+			corev1.LabelTopologyRegion:    "us-east-1",
+			corev1.LabelTopologyZone:      "us-east-1a",
+		}, "aws:///us-east-1a/i-1", "4", "16Gi", corev1.NodeSystemInfo{
+			Architecture:            "amd64",
+			OSImage:                 "Amazon Linux 2",
+			ContainerRuntimeVersion: "containerd://1.7.2",
+		}),
+		newTestNode("node-2", map[string]string{
+			corev1.LabelFailureDomainBetaRegion: "us-east-1",
+			corev1.LabelFailureDomainBetaZone:   "us-east-1b",
+		}, "aws:///us-east-1b/i-2", "2", "8Gi", corev1.NodeSystemInfo{
+			Architecture:            "arm64",
+			OSImage:                 "Amazon Linux 2",
+			ContainerRuntimeVersion: "containerd://1.7.2",
+		}),
+		newTestNode("node-3", nil, "gce://project/us-central1-a/node-3", "500m", "2Gi", corev1.NodeSystemInfo{
+			Architecture:            "amd64",
+			OSImage:                 "Container-Optimized OS",
+			ContainerRuntimeVersion: "containerd://1.6.0",
+		}),
+	)
+
+	inventory, err := GetClusterInventory(clientset)
+	req.NoError(err)
+	req.Equal(&types.ClusterInventory{
+		NodeCount:                3,
+		CPUCapacityMillicores:    6500,
+		CPUAllocatableMillicores: 6500,
+		MemoryCapacityBytes:      26 * 1024 * 1024 * 1024,
+		MemoryAllocatableBytes:   3 * 1024 * 1024 * 1024,
+		Architectures:            []string{"amd64", "arm64"},
+		OSImages:                 []string{"Amazon Linux 2", "Container-Optimized OS"},
+		ContainerRuntimeVersions: []string{"containerd://1.6.0", "containerd://1.7.2"},
+		CloudProviders:           []string{"aws", "gcp"},
+		Regions:                  []string{"us-east-1"},
+		Zones:                    []string{"us-east-1a", "us-east-1b"},
+	}, inventory)
+
+	// the inventory is sent in a header with online reports
+	headers := GetInstanceDataHeaders(&types.InstanceData{ClusterInventory: inventory})
+	decoded, err := base64.StdEncoding.DecodeString(headers["X-Replicated-ClusterInventory"])
+	req.NoError(err)
+	var headerInventory types.ClusterInventory
+	req.NoError(json.Unmarshal(decoded, &headerInventory))
+	req.Equal(*inventory, headerInventory)
+}
+
+func TestGetCachedClusterInventory(t *testing.T) {
+	req := require.New(t)
+	defer InitClusterInventory(types.ClusterInventoryConfig{})
+
+	clientset := fake.NewSimpleClientset(
+		newTestNode("node-1", nil, "", "4", "16Gi", corev1.NodeSystemInfo{Architecture: "amd64"}),
+	)
+	listCount := 0
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listCount++
+		return false, nil, nil
+	})
+
+	// disabled by default, the nodes are not listed
+	InitClusterInventory(types.ClusterInventoryConfig{})
+	req.Nil(getCachedClusterInventory(clientset))
+	req.Equal(0, listCount)
+
+	// enabled, the nodes are listed once per ttl
+	InitClusterInventory(types.ClusterInventoryConfig{Enabled: true})
+	inventory := getCachedClusterInventory(clientset)
+	req.NotNil(inventory)
+	req.Equal(1, inventory.NodeCount)
+	req.Equal(inventory, getCachedClusterInventory(clientset))
+	req.Equal(1, listCount)
+
+	// the cache expires
+	clusterInventoryCache.expiresAt = time.Now().Add(-time.Second)
+	req.NotNil(getCachedClusterInventory(clientset))
+	req.Equal(2, listCount)
+}
@@ -2,10 +2,9 @@ package report
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"time"
 
@@ -15,11 +14,20 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// SendCustomAppMetrics sends the custom app metrics, or buffers them to be sent merged with the next batch if batching is enabled.
+// ErrMetricsBuffered is returned if the metrics were buffered.
 func SendCustomAppMetrics(clientset kubernetes.Interface, sdkStore store.Store, data map[string]interface{}) error {
+	if bufferCustomAppMetrics(data) {
+		return ErrMetricsBuffered
+	}
+	return sendCustomAppMetrics(context.Background(), clientset, sdkStore, data)
+}
+
+func sendCustomAppMetrics(ctx context.Context, clientset kubernetes.Interface, sdkStore store.Store, data map[string]interface{}) error {
 	if util.IsAirgap() {
 		return SendAirgapCustomAppMetrics(clientset, sdkStore, data)
 	}
-	return SendOnlineCustomAppMetrics(sdkStore, data)
+	return SendOnlineCustomAppMetrics(ctx, sdkStore, data)
 }
 
 func SendAirgapCustomAppMetrics(clientset kubernetes.Interface, sdkStore store.Store, data map[string]interface{}) error {
@@ -41,7 +49,7 @@ func SendAirgapCustomAppMetrics(clientset kubernetes.Interface, sdkStore store.S
 	return nil
 }
 
-func SendOnlineCustomAppMetrics(sdkStore store.Store, data map[string]interface{}) error {
+func SendOnlineCustomAppMetrics(ctx context.Context, sdkStore store.Store, data map[string]interface{}) error {
 	license := sdkStore.GetLicense()
 
 	endpoint := sdkStore.GetReplicatedAppEndpoint()
@@ -83,22 +91,12 @@ func SendOnlineCustomAppMetrics(sdkStore store.Store, data map[string]interface{
 	instanceData := GetInstanceData(sdkStore)
 	InjectInstanceDataHeaders(req, instanceData)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to execute get request")
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "failed to read response body")
-	}
-
-	if resp.StatusCode >= 400 {
-		if len(body) > 0 {
-			return util.ActionableError{Message: string(body)}
+	if err := sendOnlineEvent(ctx, newOutboxEvent(ReportTypeCustomAppMetrics, req, reqBody)); err != nil {
+		var deliveryErr *outboxDeliveryError
+		if errors.As(err, &deliveryErr) && deliveryErr.Body != "" {
+			return util.ActionableError{Message: deliveryErr.Body}
 		}
-		return errors.Errorf("unexpected result from get request: %d", resp.StatusCode)
+		return errors.Wrap(err, "failed to send custom app metrics")
 	}
 
 	return nil
@@ -0,0 +1,159 @@
+package report
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	customAppMetricsBatchMtx sync.Mutex
+	// activeCustomAppMetricsBatch buffers the custom app metrics while batching is enabled, which is only in the leader
+	activeCustomAppMetricsBatch *customAppMetricsBatch
+)
+
+// ErrMetricsBuffered is returned when custom app metrics were buffered to be sent with the next batch.
+var ErrMetricsBuffered = errors.New("custom app metrics were buffered to be sent with the next batch")
+
+// IsMetricsBuffered returns true if the custom app metrics of the error were buffered to be sent with the next batch.
+func IsMetricsBuffered(err error) bool {
+	return errors.Is(err, ErrMetricsBuffered)
+}
+
+type customAppMetricsBatch struct {
+	clientset kubernetes.Interface
+	interval  time.Duration
+	maxSize   int
+
+	mtx  sync.Mutex
+	data map[string]interface{}
+	size int
+
+	// the batch is sent by a single goroutine so that the batches are sent in order
+	flush chan struct{}
+	// stop receives the context that bounds sending the last batch
+	stop chan context.Context
+	done chan struct{}
+}
+
+// StartCustomAppMetricsBatch starts buffering the custom app metrics and sending them merged on the interval of the config,
+// or once the max size is reached. Custom app metrics are sent right away if batching is disabled.
+func StartCustomAppMetricsBatch(clientset kubernetes.Interface, config custommetricstypes.BatchingConfig) {
+	StopCustomAppMetricsBatch(context.Background())
+
+	interval := config.GetInterval()
+	if interval == 0 {
+		return
+	}
+
+	b := &customAppMetricsBatch{
+		clientset: clientset,
+		interval:  interval,
+		maxSize:   config.MaxSize,
+		data:      map[string]interface{}{},
+		flush:     make(chan struct{}, 1),
+		stop:      make(chan context.Context, 1),
+		done:      make(chan struct{}),
+	}
+
+	customAppMetricsBatchMtx.Lock()
+	activeCustomAppMetricsBatch = b
+	customAppMetricsBatchMtx.Unlock()
+
+	go b.run()
+}
+
+// StopCustomAppMetricsBatch stops batching and sends the custom app metrics that are buffered.
+// It returns when the last batch is sent or the context is done, whichever comes first.
+func StopCustomAppMetricsBatch(ctx context.Context) {
+	customAppMetricsBatchMtx.Lock()
+	b := activeCustomAppMetricsBatch
+	activeCustomAppMetricsBatch = nil
+	customAppMetricsBatchMtx.Unlock()
+
+	if b == nil {
+		return
+	}
+
+	b.stop <- ctx
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		logger.Infof("timed out sending the last batch of custom app metrics: %v", ctx.Err())
+	}
+}
+
+// bufferCustomAppMetrics adds the metrics to the active batch, it returns false if batching is disabled.
+func bufferCustomAppMetrics(data map[string]interface{}) bool {
+	customAppMetricsBatchMtx.Lock()
+	defer customAppMetricsBatchMtx.Unlock()
+
+	if activeCustomAppMetricsBatch == nil {
+		return false
+	}
+	activeCustomAppMetricsBatch.add(data)
+	return true
+}
+
+func (b *customAppMetricsBatch) add(data map[string]interface{}) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	custommetrics.Merge(b.data, data)
+	b.size++
+
+	if b.maxSize > 0 && b.size >= b.maxSize {
+		select {
+		case b.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *customAppMetricsBatch) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ctx := <-b.stop:
+			b.send(ctx)
+			return
+		case <-ticker.C:
+			b.send(context.Background())
+		case <-b.flush:
+			b.send(context.Background())
+			ticker.Reset(b.interval)
+		}
+	}
+}
+
+// send sends the buffered metrics, metrics that fail to send online are retried by the outbox.
+func (b *customAppMetricsBatch) send(ctx context.Context) {
+	b.mtx.Lock()
+	data := b.data
+	b.data = map[string]interface{}{}
+	b.size = 0
+	b.mtx.Unlock()
+
+	if len(data) == 0 {
+		return
+	}
+
+	if err := sendCustomAppMetrics(ctx, b.clientset, store.GetStore(), data); err != nil {
+		if IsEventQueued(err) {
+			logger.Infof("batched custom app metrics were queued: %v", err)
+			return
+		}
+		logger.Error(errors.Wrap(err, "failed to send batched custom app metrics"))
+	}
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/replicated-sdk/pkg/custommetrics"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_CustomAppMetricsBatch(t *testing.T) {
+	req := require.New(t)
+
+	var receivedMtx sync.Mutex
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		req.NoError(json.NewDecoder(r.Body).Decode(&payload))
+		receivedMtx.Lock()
+		received = append(received, payload.Data)
+		receivedMtx.Unlock()
+	}))
+	defer server.Close()
+
+	getReceived := func() []map[string]interface{} {
+		receivedMtx.Lock()
+		defer receivedMtx.Unlock()
+		return append([]map[string]interface{}{}, received...)
+	}
+
+	store.InitInMemory(store.InitInMemoryStoreOptions{
+		License:               &kotsv1beta1.License{Spec: kotsv1beta1.LicenseSpec{LicenseID: "license-id"}},
+		ReplicatedAppEndpoint: server.URL,
+	})
+	defer store.SetStore(nil)
+
+	req.NoError(custommetrics.Init([]custommetricstypes.MetricDefinition{
+		{Name: "requests", Type: custommetricstypes.MetricTypeCounter},
+	}))
+	t.Cleanup(func() { custommetrics.Init(nil) })
+
+	clientset := fake.NewSimpleClientset()
+
+	// the metrics are sent right away while batching is disabled
+	StartCustomAppMetricsBatch(clientset, custommetricstypes.BatchingConfig{})
+	req.NoError(SendCustomAppMetrics(clientset, store.GetStore(), map[string]interface{}{"activeUsers": 1}))
+	req.Len(getReceived(), 1)
+
+	StartCustomAppMetricsBatch(clientset, custommetricstypes.BatchingConfig{Interval: "1h", MaxSize: 3})
+	defer StopCustomAppMetricsBatch(context.Background())
+
+	// the metrics are buffered and merged until the max size is reached
+	err := SendCustomAppMetrics(clientset, store.GetStore(), map[string]interface{}{"activeUsers": 5, "requests": 10})
+	req.True(IsMetricsBuffered(err))
+	err = SendCustomAppMetrics(clientset, store.GetStore(), map[string]interface{}{"activeUsers": 7, "requests": 3})
+	req.True(IsMetricsBuffered(err))
+	req.Len(getReceived(), 1)
+
+	err = SendCustomAppMetrics(clientset, store.GetStore(), map[string]interface{}{"plan": "pro"})
+	req.True(IsMetricsBuffered(err))
+	req.Eventually(func() bool {
+		return len(getReceived()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	req.Equal(map[string]interface{}{"activeUsers": float64(7), "requests": float64(13), "plan": "pro"}, getReceived()[1])
+
+	// the buffered metrics are sent when batching stops, e.g. on shutdown
+	err = SendCustomAppMetrics(clientset, store.GetStore(), map[string]interface{}{"activeUsers": 8})
+	req.True(IsMetricsBuffered(err))
+	StopCustomAppMetricsBatch(context.Background())
+	req.Len(getReceived(), 3)
+	req.Equal(map[string]interface{}{"activeUsers": float64(8)}, getReceived()[2])
+
+	// nothing is sent if nothing was buffered
+	StartCustomAppMetricsBatch(clientset, custommetricstypes.BatchingConfig{Interval: "1h"})
+	StopCustomAppMetricsBatch(context.Background())
+	req.Len(getReceived(), 3)
+}
+
+func Test_StopCustomAppMetricsBatch_Timeout(t *testing.T) {
+	req := require.New(t)
+
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	store.InitInMemory(store.InitInMemoryStoreOptions{
+		License:               &kotsv1beta1.License{Spec: kotsv1beta1.LicenseSpec{LicenseID: "license-id"}},
+		ReplicatedAppEndpoint: server.URL,
+	})
+	defer store.SetStore(nil)
+
+	clientset := fake.NewSimpleClientset()
+	StartCustomAppMetricsBatch(clientset, custommetricstypes.BatchingConfig{Interval: "1h"})
+	err := SendCustomAppMetrics(clientset, store.GetStore(), map[string]interface{}{"activeUsers": 1})
+	req.True(IsMetricsBuffered(err))
+
+	// the last batch is bounded by the context, e.g. the shutdown timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	StopCustomAppMetricsBatch(ctx)
+	req.Less(time.Since(start), 5*time.Second)
+}
@@ -62,6 +62,10 @@ func (r *CustomAppMetricsReport) AppendEvents(report Report) error {
 	return nil
 }
 
+func (r *CustomAppMetricsReport) GetEventCount() int {
+	return len(r.Events)
+}
+
 func (r *CustomAppMetricsReport) GetEventLimit() int {
 	return ReportEventLimit
 }
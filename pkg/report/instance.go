@@ -6,7 +6,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"sync"
 	"time"
 
@@ -15,6 +14,7 @@ import (
 	"github.com/replicatedhq/replicated-sdk/pkg/buildversion"
 	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
 	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/metrics"
 	"github.com/replicatedhq/replicated-sdk/pkg/report/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/store"
 	"github.com/replicatedhq/replicated-sdk/pkg/tags"
@@ -24,6 +24,42 @@ import (
 
 var instanceDataMtx sync.Mutex
 
+// pendingReports tracks instance data that is being sent in the background so that it can be flushed on shutdown
+var pendingReports sync.WaitGroup
+
+// SendInstanceDataAsync sends instance data in the background.
+func SendInstanceDataAsync(sdkStore store.Store) {
+	pendingReports.Add(1)
+	go func() {
+		defer pendingReports.Done()
+
+		clientset, err := k8sutil.GetClientset()
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to get clientset"))
+			return
+		}
+		if err := SendInstanceData(clientset, sdkStore); err != nil {
+			logger.Error(errors.Wrap(err, "failed to send instance data"))
+		}
+	}()
+}
+
+// FlushPendingReports waits for the instance data that is being sent in the background to finish sending.
+func FlushPendingReports(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pendingReports.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "timed out waiting for pending reports")
+	}
+}
+
 func SendInstanceData(clientset kubernetes.Interface, sdkStore store.Store) error {
 	license := sdkStore.GetLicense()
 
@@ -45,10 +81,13 @@ func SendInstanceData(clientset kubernetes.Interface, sdkStore store.Store) erro
 	instanceData := GetInstanceData(sdkStore)
 
 	if util.IsAirgap() {
-		return SendAirgapInstanceData(clientset, sdkStore.GetNamespace(), license.Spec.LicenseID, instanceData)
+		err = SendAirgapInstanceData(clientset, sdkStore.GetNamespace(), license.Spec.LicenseID, instanceData)
+	} else {
+		err = SendOnlineInstanceData(license, instanceData)
 	}
+	metrics.ObserveHeartbeat(err)
 
-	return SendOnlineInstanceData(license, instanceData)
+	return err
 }
 
 func SendAirgapInstanceData(clientset kubernetes.Interface, namespace string, licenseID string, instanceData *types.InstanceData) error {
@@ -74,6 +113,14 @@ func SendAirgapInstanceData(clientset kubernetes.Interface, namespace string, li
 		event.ResourceStates = string(marshalledRS)
 	}
 
+	if instanceData.ClusterInventory != nil {
+		marshalledInventory, err := json.Marshal(instanceData.ClusterInventory)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal cluster inventory")
+		}
+		event.ClusterInventory = string(marshalledInventory)
+	}
+
 	marshalledTags, err := json.Marshal(instanceData.Tags)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal tags")
@@ -111,14 +158,8 @@ func SendOnlineInstanceData(license *v1beta1.License, instanceData *types.Instan
 
 	InjectInstanceDataHeaders(postReq, instanceData)
 
-	resp, err := http.DefaultClient.Do(postReq)
-	if err != nil {
-		return errors.Wrap(err, "failed to post request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return errors.Errorf("Unexpected status code %d", resp.StatusCode)
+	if err := sendOnlineEvent(context.Background(), newOutboxEvent(ReportTypeInstance, postReq, reqBody)); err != nil {
+		return errors.Wrap(err, "failed to send instance data")
 	}
 
 	return nil
@@ -150,6 +191,8 @@ func GetInstanceData(sdkStore store.Store) *types.InstanceData {
 			r.K8sDistribution = distribution.String()
 		}
 
+		r.ClusterInventory = getCachedClusterInventory(clientset)
+
 		if tdata, err := tags.Get(context.TODO(), clientset, sdkStore.GetNamespace()); err != nil {
 			logger.Debugf("failed to get instance tag data: %v", err.Error())
 		} else {
@@ -27,6 +27,7 @@ type InstanceReportEvent struct {
 	DownstreamChannelSequence int64  `json:"downstream_channel_sequence"`
 	DownstreamChannelName     string `json:"downstream_channel_name,omitempty"`
 	Tags                      string `json:"tags"`
+	ClusterInventory          string `json:"cluster_inventory,omitempty"`
 }
 
 func (r *InstanceReport) GetType() ReportType {
@@ -71,6 +72,10 @@ func (r *InstanceReport) AppendEvents(report Report) error {
 	return nil
 }
 
+func (r *InstanceReport) GetEventCount() int {
+	return len(r.Events)
+}
+
 func (r *InstanceReport) GetEventLimit() int {
 	return ReportEventLimit
 }
@@ -0,0 +1,467 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/report/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	OutboxSecretName = "replicated-outbox"
+	OutboxSecretKey  = "outbox"
+	// OutboxEventLimit is the number of events that are kept in the outbox, the oldest events are dropped first
+	OutboxEventLimit = 1000
+	OutboxSizeLimit  = 1 * 1024 * 1024 // 1MB
+
+	outboxRequestTimeout = 30 * time.Second
+)
+
+var (
+	outboxMtx sync.Mutex
+	// activeOutbox holds the online events that failed to send while it is running, which is only in the leader
+	activeOutbox *outbox
+
+	newOutboxBackOff = func() backoff.BackOff {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = 5 * time.Second
+		b.MaxInterval = 5 * time.Minute
+		b.MaxElapsedTime = 0 // retry until the event is delivered
+		return b
+	}
+)
+
+// ErrEventQueued is wrapped by the errors of online events that failed to send and were queued in the outbox to be retried.
+var ErrEventQueued = errors.New("event was queued to be retried")
+
+// IsEventQueued returns true if the event of the error was queued in the outbox to be retried.
+func IsEventQueued(err error) bool {
+	return errors.Is(err, ErrEventQueued)
+}
+
+// OutboxEvent is an online event with the request that sends it, so that it is retried exactly as it was first sent.
+type OutboxEvent struct {
+	ID        string      `json:"id"`
+	Type      ReportType  `json:"type"`
+	URL       string      `json:"url"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"lastError,omitempty"`
+}
+
+// outboxDeliveryError is returned when replicated.app responds to an event with an unsuccessful status code.
+type outboxDeliveryError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *outboxDeliveryError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+}
+
+// isPermanent returns true if the event was rejected and retrying it would not succeed.
+func (e *outboxDeliveryError) isPermanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
+
+type outbox struct {
+	clientset kubernetes.Interface
+	namespace string
+	cancel    context.CancelFunc
+	kick      chan struct{}
+
+	mtx           sync.Mutex
+	events        []OutboxEvent
+	nextAttemptAt time.Time
+	lastID        int64
+}
+
+// StartOutbox loads the online events that failed to send from the outbox secret, and retries them in order until they are delivered.
+// Online events that fail to send are queued in the outbox while it is running. A previously started outbox is stopped.
+func StartOutbox(clientset kubernetes.Interface, namespace string) error {
+	StopOutbox()
+
+	o := &outbox{
+		clientset: clientset,
+		namespace: namespace,
+		kick:      make(chan struct{}, 1),
+	}
+
+	events, err := loadOutboxEvents(clientset, namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to load outbox events")
+	}
+	o.events = events
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+
+	outboxMtx.Lock()
+	activeOutbox = o
+	outboxMtx.Unlock()
+
+	go o.run(ctx)
+
+	return nil
+}
+
+// StopOutbox stops retrying the events in the outbox, they are kept in the outbox secret for the next leader.
+func StopOutbox() {
+	outboxMtx.Lock()
+	defer outboxMtx.Unlock()
+
+	if activeOutbox != nil {
+		activeOutbox.cancel()
+		activeOutbox = nil
+	}
+}
+
+// GetOutboxStatus returns the number of events in the outbox and the age of the oldest event.
+func GetOutboxStatus() types.OutboxStatus {
+	outboxMtx.Lock()
+	o := activeOutbox
+	outboxMtx.Unlock()
+
+	if o == nil {
+		return types.OutboxStatus{}
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	status := types.OutboxStatus{
+		Running: true,
+		Depth:   len(o.events),
+	}
+	if len(o.events) > 0 {
+		oldest := o.events[0]
+		status.OldestEventCreatedAt = &oldest.CreatedAt
+		status.OldestEventAgeSeconds = int64(time.Since(oldest.CreatedAt).Seconds())
+		status.LastError = oldest.LastError
+		if !o.nextAttemptAt.IsZero() {
+			nextAttemptAt := o.nextAttemptAt
+			status.NextAttemptAt = &nextAttemptAt
+		}
+	}
+	return status
+}
+
+// newOutboxEvent returns an event for the request, the body of the request must be passed separately since it is consumed when sent.
+func newOutboxEvent(reportType ReportType, req *http.Request, body []byte) OutboxEvent {
+	return OutboxEvent{
+		Type:      reportType,
+		URL:       req.URL.String(),
+		Header:    req.Header.Clone(),
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// sendOnlineEvent sends the event to replicated.app. If the outbox is running and the event fails to send, or older events
+// are still waiting in the outbox, the event is queued in the outbox and the returned error wraps ErrEventQueued.
+func sendOnlineEvent(ctx context.Context, event OutboxEvent) error {
+	outboxMtx.Lock()
+	o := activeOutbox
+	outboxMtx.Unlock()
+
+	if o == nil {
+		return deliverOutboxEvent(ctx, event)
+	}
+
+	// events are sent in order, so new events wait behind the events that are being retried
+	if o.depth() > 0 {
+		o.enqueue(event)
+		return errors.Wrap(ErrEventQueued, "older events are waiting in the outbox")
+	}
+
+	err := deliverOutboxEvent(ctx, event)
+	if err == nil {
+		return nil
+	}
+	var deliveryErr *outboxDeliveryError
+	if errors.As(err, &deliveryErr) && deliveryErr.isPermanent() {
+		return err
+	}
+
+	event.Attempts = 1
+	event.LastError = err.Error()
+	o.enqueue(event)
+	return errors.Wrapf(ErrEventQueued, "failed to send event: %v", err)
+}
+
+func deliverOutboxEvent(ctx context.Context, event OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, outboxRequestTimeout)
+	defer cancel()
+
+	req, err := util.NewRequest("POST", event.URL, bytes.NewReader(event.Body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create http request")
+	}
+	for key, values := range event.Header {
+		req.Header[key] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "failed to post request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return &outboxDeliveryError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return nil
+}
+
+// run delivers the events in the outbox one at a time, oldest first, and backs off while they fail to send.
+func (o *outbox) run(ctx context.Context) {
+	b := newOutboxBackOff()
+	for {
+		event, ok := o.head()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.kick:
+				continue
+			}
+		}
+
+		err := deliverOutboxEvent(ctx, event)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var deliveryErr *outboxDeliveryError
+		if err == nil || errors.As(err, &deliveryErr) && deliveryErr.isPermanent() {
+			if err != nil {
+				logger.Errorf("dropping %s event from the outbox after %d attempts: %v", event.Type, event.Attempts+1, err)
+			}
+			o.remove(event.ID)
+			b.Reset()
+			continue
+		}
+
+		wait := b.NextBackOff()
+		o.recordAttempt(event.ID, err, time.Now().Add(wait))
+		logger.Debugf("failed to send %s event from the outbox, retrying in %s: %v", event.Type, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (o *outbox) depth() int {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return len(o.events)
+}
+
+func (o *outbox) head() (OutboxEvent, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if len(o.events) == 0 {
+		return OutboxEvent{}, false
+	}
+	return o.events[0], true
+}
+
+func (o *outbox) enqueue(event OutboxEvent) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	id := time.Now().UnixNano()
+	if id <= o.lastID {
+		id = o.lastID + 1
+	}
+	o.lastID = id
+	event.ID = strconv.FormatInt(id, 10)
+
+	o.events = append(o.events, event)
+	if len(o.events) > OutboxEventLimit {
+		logger.Errorf("outbox is full, dropping %d oldest events", len(o.events)-OutboxEventLimit)
+		o.events = o.events[len(o.events)-OutboxEventLimit:]
+	}
+	o.save()
+
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (o *outbox) remove(id string) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	for i, event := range o.events {
+		if event.ID == id {
+			o.events = append(o.events[:i:i], o.events[i+1:]...)
+			break
+		}
+	}
+	o.nextAttemptAt = time.Time{}
+	o.save()
+}
+
+func (o *outbox) recordAttempt(id string, err error, nextAttemptAt time.Time) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	for i := range o.events {
+		if o.events[i].ID == id {
+			o.events[i].Attempts++
+			o.events[i].LastError = err.Error()
+			break
+		}
+	}
+	o.nextAttemptAt = nextAttemptAt
+	o.save()
+}
+
+// save persists the events in the outbox secret, dropping the oldest events if they exceed the size limit.
+// The events are kept in memory if they fail to persist, and persisting is retried on the next change. Must be called with the lock held.
+func (o *outbox) save() {
+	data, err := encodeOutboxEvents(o.events)
+	for err == nil && len(data) > OutboxSizeLimit && len(o.events) > 0 {
+		o.events = o.events[1:]
+		data, err = encodeOutboxEvents(o.events)
+	}
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to encode outbox events"))
+		return
+	}
+
+	if err := saveOutboxData(o.clientset, o.namespace, data); err != nil {
+		logger.Error(errors.Wrap(err, "failed to save outbox"))
+	}
+}
+
+func loadOutboxEvents(clientset kubernetes.Interface, namespace string) ([]OutboxEvent, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), OutboxSecretName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get outbox secret")
+	}
+
+	data := secret.Data[OutboxSecretKey]
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	events, err := decodeOutboxEvents(data)
+	if err != nil {
+		// the events cannot be recovered, start with an empty outbox instead of failing to start
+		logger.Error(errors.Wrap(err, "failed to decode outbox events, discarding them"))
+		return nil, nil
+	}
+	return events, nil
+}
+
+func saveOutboxData(clientset kubernetes.Interface, namespace string, data []byte) error {
+	existingSecret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), OutboxSecretName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get outbox secret")
+	}
+
+	if kuberneteserrors.IsNotFound(err) {
+		uid, err := util.GetReplicatedDeploymentUID(clientset, namespace)
+		if err != nil {
+			return errors.Wrap(err, "failed to get replicated deployment uid")
+		}
+
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      OutboxSecretName,
+				Namespace: namespace,
+				// since this secret is created by the replicated deployment, we should set the owner reference
+				// so that it is deleted when the replicated deployment is deleted
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       util.GetReplicatedDeploymentName(),
+						UID:        uid,
+					},
+				},
+			},
+			Data: map[string][]byte{
+				OutboxSecretKey: data,
+			},
+		}
+
+		_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to create outbox secret")
+		}
+		return nil
+	}
+
+	if existingSecret.Data == nil {
+		existingSecret.Data = map[string][]byte{}
+	}
+	existingSecret.Data[OutboxSecretKey] = data
+
+	_, err = clientset.CoreV1().Secrets(namespace).Update(context.TODO(), existingSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to update outbox secret")
+	}
+
+	return nil
+}
+
+func encodeOutboxEvents(events []OutboxEvent) ([]byte, error) {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal outbox events")
+	}
+	compressedData, err := util.GzipData(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to gzip outbox events")
+	}
+	return []byte(base64.StdEncoding.EncodeToString(compressedData)), nil
+}
+
+func decodeOutboxEvents(encodedData []byte) ([]OutboxEvent, error) {
+	decodedData, err := base64.StdEncoding.DecodeString(string(encodedData))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode outbox events")
+	}
+	decompressedData, err := util.GunzipData(decodedData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to gunzip outbox events")
+	}
+	var events []OutboxEvent
+	if err := json.Unmarshal(decompressedData, &events); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal outbox events")
+	}
+	return events, nil
+}
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/replicatedhq/replicated-sdk/pkg/k8sutil"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestOutboxRequest(t *testing.T, url string, body string) OutboxEvent {
+	req, err := util.NewRequest("POST", url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	return newOutboxEvent(ReportTypeInstance, req, []byte(body))
+}
+
+func getTestOutboxSecretEvents(t *testing.T, clientset kubernetes.Interface) []OutboxEvent {
+	secret, err := clientset.CoreV1().Secrets("test-namespace").Get(context.TODO(), OutboxSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	events, err := decodeOutboxEvents(secret.Data[OutboxSecretKey])
+	require.NoError(t, err)
+	return events
+}
+
+func Test_Outbox(t *testing.T) {
+	req := require.New(t)
+
+	defaultNewOutboxBackOff := newOutboxBackOff
+	newOutboxBackOff = func() backoff.BackOff {
+		return backoff.NewConstantBackOff(10 * time.Millisecond)
+	}
+	t.Cleanup(func() {
+		newOutboxBackOff = defaultNewOutboxBackOff
+	})
+
+	var online atomic.Bool
+	var receivedMtx sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "rejected" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req.Equal("application/json", r.Header.Get("Content-Type"))
+		receivedMtx.Lock()
+		received = append(received, string(body))
+		receivedMtx.Unlock()
+	}))
+	defer server.Close()
+
+	clientset := fake.NewSimpleClientset(
+		k8sutil.CreateTestDeployment(util.GetReplicatedDeploymentName(), "test-namespace", "1", map[string]string{"app": "test-app"}),
+	)
+
+	// events are sent directly while the outbox is not running
+	err := sendOnlineEvent(context.Background(), newTestOutboxRequest(t, server.URL, "first"))
+	req.Error(err)
+	req.False(IsEventQueued(err))
+
+	req.NoError(StartOutbox(clientset, "test-namespace"))
+	defer StopOutbox()
+
+	// events that fail to send are queued, and new events wait behind them
+	err = sendOnlineEvent(context.Background(), newTestOutboxRequest(t, server.URL, "first"))
+	req.True(IsEventQueued(err))
+	err = sendOnlineEvent(context.Background(), newTestOutboxRequest(t, server.URL, "second"))
+	req.True(IsEventQueued(err))
+
+	status := GetOutboxStatus()
+	req.True(status.Running)
+	req.Equal(2, status.Depth)
+	req.NotNil(status.OldestEventCreatedAt)
+
+	events := getTestOutboxSecretEvents(t, clientset)
+	req.Len(events, 2)
+	req.Equal([]byte("first"), events[0].Body)
+	req.Equal([]byte("second"), events[1].Body)
+
+	// the outbox is loaded from the secret when it starts, e.g. in a new leader
+	StopOutbox()
+	req.False(GetOutboxStatus().Running)
+	req.NoError(StartOutbox(clientset, "test-namespace"))
+	req.Equal(2, GetOutboxStatus().Depth)
+
+	// the events are sent in order once replicated.app is reachable
+	online.Store(true)
+	req.Eventually(func() bool {
+		return GetOutboxStatus().Depth == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	receivedMtx.Lock()
+	req.Equal([]string{"first", "second"}, received)
+	receivedMtx.Unlock()
+	req.Empty(getTestOutboxSecretEvents(t, clientset))
+
+	// rejected events are not queued
+	err = sendOnlineEvent(context.Background(), newTestOutboxRequest(t, server.URL, "rejected"))
+	req.Error(err)
+	req.False(IsEventQueued(err))
+	req.Equal(0, GetOutboxStatus().Depth)
+}
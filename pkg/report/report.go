@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/metrics"
 	"github.com/replicatedhq/replicated-sdk/pkg/util"
 	corev1 "k8s.io/api/core/v1"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
@@ -33,6 +34,7 @@ type Report interface {
 	GetSecretName() string
 	GetSecretKey() string
 	AppendEvents(report Report) error
+	GetEventCount() int
 	GetEventLimit() int
 	GetSizeLimit() int
 	GetMtx() *sync.Mutex
@@ -90,6 +92,8 @@ func AppendReport(clientset kubernetes.Interface, namespace string, report Repor
 			return errors.Wrap(err, "failed to create report secret")
 		}
 
+		metrics.SetReportStats(string(report.GetType()), len(data), report.GetEventCount())
+
 		return nil
 	}
 
@@ -124,6 +128,8 @@ func AppendReport(clientset kubernetes.Interface, namespace string, report Repor
 		return errors.Wrap(err, "failed to update report secret")
 	}
 
+	metrics.SetReportStats(string(report.GetType()), len(data), existingReport.GetEventCount())
+
 	return nil
 }
 
@@ -1,6 +1,10 @@
 package types
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
 	tagstypes "github.com/replicatedhq/replicated-sdk/pkg/tags/types"
 )
@@ -36,6 +40,38 @@ type InstanceData struct {
 	K8sVersion      string                       `json:"k8s_version"`
 	K8sDistribution string                       `json:"k8s_distribution"`
 	Tags            tagstypes.InstanceTagData    `json:"tags"`
+	// ClusterInventory is nil if the nodes of the cluster cannot be listed
+	ClusterInventory *ClusterInventory `json:"cluster_inventory,omitempty"`
+}
+
+// ClusterInventoryConfig controls whether the cluster inventory is reported with the instance data.
+// Reading the nodes requires a cluster role, so it is disabled by default.
+type ClusterInventoryConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// ClusterInventory is the capacity of the cluster and the hardware and software of its nodes.
+// The lists are sorted and contain each value once.
+type ClusterInventory struct {
+	NodeCount                int      `json:"node_count"`
+	CPUCapacityMillicores    int64    `json:"cpu_capacity_millicores"`
+	CPUAllocatableMillicores int64    `json:"cpu_allocatable_millicores"`
+	MemoryCapacityBytes      int64    `json:"memory_capacity_bytes"`
+	MemoryAllocatableBytes   int64    `json:"memory_allocatable_bytes"`
+	Architectures            []string `json:"architectures,omitempty"`
+	OSImages                 []string `json:"os_images,omitempty"`
+	ContainerRuntimeVersions []string `json:"container_runtime_versions,omitempty"`
+	CloudProviders           []string `json:"cloud_providers,omitempty"`
+	Regions                  []string `json:"regions,omitempty"`
+	Zones                    []string `json:"zones,omitempty"`
+}
+
+func (i ClusterInventory) MarshalBase64() ([]byte, error) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(b)), nil
 }
 
 func (d Distribution) String() string {
@@ -69,3 +105,14 @@ func (d Distribution) String() string {
 	}
 	return "unknown"
 }
+
+// OutboxStatus is the status of the online events that failed to send and are waiting to be retried
+type OutboxStatus struct {
+	// Running is true in the replica that retries the events, which is the leader
+	Running               bool       `json:"running"`
+	Depth                 int        `json:"depth"`
+	OldestEventCreatedAt  *time.Time `json:"oldestEventCreatedAt,omitempty"`
+	OldestEventAgeSeconds int64      `json:"oldestEventAgeSeconds"`
+	NextAttemptAt         *time.Time `json:"nextAttemptAt,omitempty"`
+	LastError             string     `json:"lastError,omitempty"`
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
 	"github.com/replicatedhq/replicated-sdk/pkg/logger"
 	"github.com/replicatedhq/replicated-sdk/pkg/report/types"
 	"github.com/replicatedhq/replicated-sdk/pkg/util"
@@ -45,6 +46,14 @@ func GetInstanceDataPayload(instanceData *types.InstanceData) (map[string]interf
 		payload["resource_states"] = string(marshalledRS)
 	}
 
+	if instanceData.ClusterInventory != nil {
+		marshalledInventory, err := json.Marshal(instanceData.ClusterInventory)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal cluster inventory")
+		}
+		payload["cluster_inventory"] = string(marshalledInventory)
+	}
+
 	return payload, nil
 }
 
@@ -93,10 +102,24 @@ func GetInstanceDataHeaders(instanceData *types.InstanceData) map[string]string
 		}
 	}
 
+	if instanceData.ClusterInventory != nil {
+		b64, err := instanceData.ClusterInventory.MarshalBase64()
+		if err != nil {
+			logger.Errorf("Failed to base64 encode cluster inventory into headers: %v: %v", instanceData.ClusterInventory, err)
+		} else {
+			headers["X-Replicated-ClusterInventory"] = string(b64)
+		}
+	}
+
 	return headers
 }
 
 func canReport(clientset kubernetes.Interface, namespace string, license *kotsv1beta1.License) (bool, error) {
+	if !leader.IsLeader() {
+		// only the leader reports instance data so that it is not reported once per replica
+		return false, nil
+	}
+
 	if util.IsDevEnv() && !util.IsDevLicense(license) {
 		// don't send reports from our dev env to our production services even if this is a production license
 		return false, nil
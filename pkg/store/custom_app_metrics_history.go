@@ -0,0 +1,173 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	CustomAppMetricsHistorySecretName = "replicated-custom-metrics-history"
+	CustomAppMetricsHistorySecretKey  = "history"
+)
+
+var (
+	// CustomAppMetricsHistoryCheckpointInterval is how often the custom app metrics history is checkpointed if it changed
+	CustomAppMetricsHistoryCheckpointInterval = 30 * time.Second
+)
+
+type customAppMetricsHistoryCheckpoint struct {
+	AppSlug string                          `json:"appSlug"`
+	History []custommetricstypes.Submission `json:"history"`
+}
+
+// AddCustomAppMetrics records the submission in memory, the history is checkpointed by RunCustomAppMetricsHistoryCheckpoints.
+func (s *SecretStore) AddCustomAppMetrics(submission custommetricstypes.Submission) {
+	s.InMemoryStore.AddCustomAppMetrics(submission)
+	s.customAppMetricsHistoryDirty.Store(true)
+}
+
+// RunCustomAppMetricsHistoryCheckpoints checkpoints the custom app metrics history on an interval while this replica is the leader.
+// The history is checkpointed one last time on shutdown with CheckpointCustomAppMetricsHistory.
+func (s *SecretStore) RunCustomAppMetricsHistoryCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(CustomAppMetricsHistoryCheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.CheckpointCustomAppMetricsHistory(ctx)
+		}
+	}
+}
+
+// CheckpointCustomAppMetricsHistory saves the custom app metrics history if it changed since it was last saved.
+func (s *SecretStore) CheckpointCustomAppMetricsHistory(ctx context.Context) {
+	if !leader.IsLeader() {
+		// only the leader writes the checkpoint, other replicas reload it
+		return
+	}
+	if !s.customAppMetricsHistoryDirty.Swap(false) {
+		return
+	}
+	if err := s.saveCustomAppMetricsHistory(ctx); err != nil {
+		// retried on the next interval
+		s.customAppMetricsHistoryDirty.Store(true)
+		logger.Error(errors.Wrap(err, "failed to checkpoint custom app metrics history"))
+	}
+}
+
+func (s *SecretStore) rehydrateCustomAppMetricsHistory(ctx context.Context) error {
+	secret, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Get(ctx, CustomAppMetricsHistorySecretName, metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to get custom app metrics history secret")
+	}
+
+	data, ok := secret.Data[CustomAppMetricsHistorySecretKey]
+	if !ok || len(data) == 0 {
+		return nil
+	}
+
+	var c customAppMetricsHistoryCheckpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		// a corrupt checkpoint should not prevent the sdk from starting, it will be overwritten on the next save
+		logger.Infof("failed to unmarshal custom app metrics history checkpoint, ignoring: %v", err)
+		return nil
+	}
+
+	// the history of a different app (e.g. a reused namespace) is not restored
+	if c.AppSlug == s.GetAppSlug() {
+		s.InMemoryStore.setCustomAppMetricsHistory(c.History)
+	}
+
+	s.customAppMetricsHistoryMtx.Lock()
+	s.lastSavedCustomAppMetricsHistory = data
+	s.customAppMetricsHistoryMtx.Unlock()
+
+	return nil
+}
+
+func (s *SecretStore) saveCustomAppMetricsHistory(ctx context.Context) error {
+	s.customAppMetricsHistoryMtx.Lock()
+	defer s.customAppMetricsHistoryMtx.Unlock()
+
+	data, err := json.Marshal(customAppMetricsHistoryCheckpoint{
+		AppSlug: s.GetAppSlug(),
+		History: s.GetCustomAppMetricsHistory(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal custom app metrics history")
+	}
+
+	if bytes.Equal(data, s.lastSavedCustomAppMetricsHistory) {
+		return nil
+	}
+
+	existingSecret, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Get(ctx, CustomAppMetricsHistorySecretName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get custom app metrics history secret")
+	}
+
+	if kuberneteserrors.IsNotFound(err) {
+		uid, err := util.GetReplicatedDeploymentUID(s.clientset, s.GetNamespace())
+		if err != nil {
+			return errors.Wrap(err, "failed to get replicated deployment uid")
+		}
+
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      CustomAppMetricsHistorySecretName,
+				Namespace: s.GetNamespace(),
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       util.GetReplicatedDeploymentName(),
+						UID:        uid,
+					},
+				},
+			},
+			Data: map[string][]byte{
+				CustomAppMetricsHistorySecretKey: data,
+			},
+		}
+
+		if _, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "failed to create custom app metrics history secret")
+		}
+
+		s.lastSavedCustomAppMetricsHistory = data
+		return nil
+	}
+
+	if existingSecret.Data == nil {
+		existingSecret.Data = map[string][]byte{}
+	}
+	existingSecret.Data[CustomAppMetricsHistorySecretKey] = data
+
+	if _, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Update(ctx, existingSecret, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update custom app metrics history secret")
+	}
+
+	s.lastSavedCustomAppMetricsHistory = data
+	return nil
+}
@@ -1,32 +1,54 @@
 package store
 
 import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sync"
+
 	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/events"
+	eventstypes "github.com/replicatedhq/replicated-sdk/pkg/events/types"
 	sdklicensetypes "github.com/replicatedhq/replicated-sdk/pkg/license/types"
 	upstreamtypes "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
 )
 
 type InMemoryStore struct {
-	replicatedID          string
-	appID                 string
-	license               *kotsv1beta1.License
-	licenseFields         sdklicensetypes.LicenseFields
-	appSlug               string
-	appName               string
-	channelID             string
-	channelName           string
-	channelSequence       int64
-	releaseSequence       int64
-	releaseCreatedAt      string
-	releaseNotes          string
-	versionLabel          string
-	replicatedAppEndpoint string
-	namespace             string
-	appStatus             appstatetypes.AppStatus
-	updates               []upstreamtypes.ChannelRelease
+	replicatedID               string
+	appID                      string
+	license                    *kotsv1beta1.License
+	licenseFields              sdklicensetypes.LicenseFields
+	appSlug                    string
+	appName                    string
+	channelID                  string
+	channelName                string
+	channelSequence            int64
+	releaseSequence            int64
+	releaseCreatedAt           string
+	releaseNotes               string
+	versionLabel               string
+	replicatedAppEndpoint      string
+	namespace                  string
+	appStatus                  appstatetypes.AppStatus
+	appStatusHistory           []appstatetypes.AppStatusTransition
+	appStatusHistoryMtx        sync.Mutex
+	customAppMetricsHistory    []custommetricstypes.Submission
+	customAppMetricsSizes      []int
+	customAppMetricsHistoryMtx sync.Mutex
+	updates                    []upstreamtypes.ChannelRelease
 }
 
+const (
+	// AppStatusHistoryLimit is the number of app status transitions that are kept, the oldest transitions are dropped first
+	AppStatusHistoryLimit = 100
+	// CustomAppMetricsHistoryLimit is the number of custom app metrics submissions that are kept, the oldest submissions are dropped first
+	CustomAppMetricsHistoryLimit = 500
+	// CustomAppMetricsHistorySizeLimit is the size in bytes of the json encoded submissions that are kept, so that the history fits in a secret
+	CustomAppMetricsHistorySizeLimit = 512 * 1024
+)
+
 type InitInMemoryStoreOptions struct {
 	ReplicatedID          string
 	AppID                 string
@@ -45,7 +67,11 @@ type InitInMemoryStoreOptions struct {
 }
 
 func InitInMemory(options InitInMemoryStoreOptions) {
-	SetStore(&InMemoryStore{
+	SetStore(newInMemoryStore(options))
+}
+
+func newInMemoryStore(options InitInMemoryStoreOptions) *InMemoryStore {
+	return &InMemoryStore{
 		replicatedID:          options.ReplicatedID,
 		appID:                 options.AppID,
 		appSlug:               options.License.Spec.AppSlug,
@@ -61,7 +87,7 @@ func InitInMemory(options InitInMemoryStoreOptions) {
 		versionLabel:          options.VersionLabel,
 		replicatedAppEndpoint: options.ReplicatedAppEndpoint,
 		namespace:             options.Namespace,
-	})
+	}
 }
 
 func (s *InMemoryStore) GetReplicatedID() string {
@@ -77,7 +103,16 @@ func (s *InMemoryStore) GetLicense() *kotsv1beta1.License {
 }
 
 func (s *InMemoryStore) SetLicense(license *kotsv1beta1.License) {
+	previous := s.license
 	s.license = license.DeepCopy()
+
+	if license != nil && (previous == nil || previous.Spec.LicenseID != license.Spec.LicenseID || previous.Spec.LicenseSequence != license.Spec.LicenseSequence) {
+		events.Publish(eventstypes.EventTypeLicense, eventstypes.LicenseEvent{
+			LicenseID:       license.Spec.LicenseID,
+			LicenseSequence: license.Spec.LicenseSequence,
+			LicenseType:     license.Spec.LicenseType,
+		})
+	}
 }
 
 func (s *InMemoryStore) GetLicenseFields() sdklicensetypes.LicenseFields {
@@ -151,7 +186,91 @@ func (s *InMemoryStore) GetAppStatus() appstatetypes.AppStatus {
 }
 
 func (s *InMemoryStore) SetAppStatus(status appstatetypes.AppStatus) {
+	previous := s.appStatus
 	s.appStatus = status
+
+	if !reflect.DeepEqual(previous, status) {
+		events.Publish(eventstypes.EventTypeAppStatus, status)
+	}
+}
+
+func (s *InMemoryStore) GetAppStatusHistory() []appstatetypes.AppStatusTransition {
+	s.appStatusHistoryMtx.Lock()
+	defer s.appStatusHistoryMtx.Unlock()
+	return append([]appstatetypes.AppStatusTransition{}, s.appStatusHistory...)
+}
+
+func (s *InMemoryStore) AddAppStatusTransition(transition appstatetypes.AppStatusTransition) {
+	s.appStatusHistoryMtx.Lock()
+	defer s.appStatusHistoryMtx.Unlock()
+	s.appStatusHistory = append(s.appStatusHistory, transition)
+	if len(s.appStatusHistory) > AppStatusHistoryLimit {
+		s.appStatusHistory = s.appStatusHistory[len(s.appStatusHistory)-AppStatusHistoryLimit:]
+	}
+
+	events.Publish(eventstypes.EventTypeAppState, transition)
+}
+
+// SetAppStatusWithTransition sets the app status and records the transition of the app state to it.
+func (s *InMemoryStore) SetAppStatusWithTransition(status appstatetypes.AppStatus, transition appstatetypes.AppStatusTransition) {
+	s.SetAppStatus(status)
+	s.AddAppStatusTransition(transition)
+}
+
+func (s *InMemoryStore) setAppStatusHistory(history []appstatetypes.AppStatusTransition) {
+	s.appStatusHistoryMtx.Lock()
+	defer s.appStatusHistoryMtx.Unlock()
+	s.appStatusHistory = history
+}
+
+func (s *InMemoryStore) GetCustomAppMetricsHistory() []custommetricstypes.Submission {
+	s.customAppMetricsHistoryMtx.Lock()
+	defer s.customAppMetricsHistoryMtx.Unlock()
+	return append([]custommetricstypes.Submission{}, s.customAppMetricsHistory...)
+}
+
+func (s *InMemoryStore) AddCustomAppMetrics(submission custommetricstypes.Submission) {
+	s.customAppMetricsHistoryMtx.Lock()
+	defer s.customAppMetricsHistoryMtx.Unlock()
+	s.customAppMetricsHistory = append(s.customAppMetricsHistory, submission)
+	s.customAppMetricsSizes = append(s.customAppMetricsSizes, customAppMetricsSize(submission))
+	s.trimCustomAppMetricsHistory()
+}
+
+func (s *InMemoryStore) setCustomAppMetricsHistory(history []custommetricstypes.Submission) {
+	s.customAppMetricsHistoryMtx.Lock()
+	defer s.customAppMetricsHistoryMtx.Unlock()
+	s.customAppMetricsHistory = history
+	s.customAppMetricsSizes = make([]int, 0, len(history))
+	for _, submission := range history {
+		s.customAppMetricsSizes = append(s.customAppMetricsSizes, customAppMetricsSize(submission))
+	}
+	s.trimCustomAppMetricsHistory()
+}
+
+// trimCustomAppMetricsHistory drops the oldest submissions until the history is within its count and size limits.
+func (s *InMemoryStore) trimCustomAppMetricsHistory() {
+	size := 0
+	for _, submissionSize := range s.customAppMetricsSizes {
+		size += submissionSize
+	}
+
+	drop := 0
+	for drop < len(s.customAppMetricsHistory) && (len(s.customAppMetricsHistory)-drop > CustomAppMetricsHistoryLimit || size > CustomAppMetricsHistorySizeLimit) {
+		size -= s.customAppMetricsSizes[drop]
+		drop++
+	}
+
+	s.customAppMetricsHistory = s.customAppMetricsHistory[drop:]
+	s.customAppMetricsSizes = s.customAppMetricsSizes[drop:]
+}
+
+func customAppMetricsSize(submission custommetricstypes.Submission) int {
+	data, err := json.Marshal(submission)
+	if err != nil {
+		return 0
+	}
+	return len(data)
 }
 
 func (s *InMemoryStore) GetUpdates() []upstreamtypes.ChannelRelease {
@@ -159,5 +278,16 @@ func (s *InMemoryStore) GetUpdates() []upstreamtypes.ChannelRelease {
 }
 
 func (s *InMemoryStore) SetUpdates(updates []upstreamtypes.ChannelRelease) {
+	previous := s.updates
 	s.updates = updates
+
+	newUpdates := []upstreamtypes.ChannelRelease{}
+	for _, update := range updates {
+		if !slices.Contains(previous, update) {
+			newUpdates = append(newUpdates, update)
+		}
+	}
+	if len(newUpdates) > 0 {
+		events.Publish(eventstypes.EventTypeUpdates, newUpdates)
+	}
 }
@@ -10,8 +10,9 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	v1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
 	types "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
-	types0 "github.com/replicatedhq/replicated-sdk/pkg/license/types"
-	types1 "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
+	types0 "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	types1 "github.com/replicatedhq/replicated-sdk/pkg/license/types"
+	types2 "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
 )
 
 // MockStore is a mock of Store interface.
@@ -37,6 +38,30 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AddAppStatusTransition mocks base method.
+func (m *MockStore) AddAppStatusTransition(transition types.AppStatusTransition) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddAppStatusTransition", transition)
+}
+
+// AddAppStatusTransition indicates an expected call of AddAppStatusTransition.
+func (mr *MockStoreMockRecorder) AddAppStatusTransition(transition interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAppStatusTransition", reflect.TypeOf((*MockStore)(nil).AddAppStatusTransition), transition)
+}
+
+// AddCustomAppMetrics mocks base method.
+func (m *MockStore) AddCustomAppMetrics(submission types0.Submission) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddCustomAppMetrics", submission)
+}
+
+// AddCustomAppMetrics indicates an expected call of AddCustomAppMetrics.
+func (mr *MockStoreMockRecorder) AddCustomAppMetrics(submission interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCustomAppMetrics", reflect.TypeOf((*MockStore)(nil).AddCustomAppMetrics), submission)
+}
+
 // GetAppID mocks base method.
 func (m *MockStore) GetAppID() string {
 	m.ctrl.T.Helper()
@@ -93,6 +118,20 @@ func (mr *MockStoreMockRecorder) GetAppStatus() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAppStatus", reflect.TypeOf((*MockStore)(nil).GetAppStatus))
 }
 
+// GetAppStatusHistory mocks base method.
+func (m *MockStore) GetAppStatusHistory() []types.AppStatusTransition {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAppStatusHistory")
+	ret0, _ := ret[0].([]types.AppStatusTransition)
+	return ret0
+}
+
+// GetAppStatusHistory indicates an expected call of GetAppStatusHistory.
+func (mr *MockStoreMockRecorder) GetAppStatusHistory() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAppStatusHistory", reflect.TypeOf((*MockStore)(nil).GetAppStatusHistory))
+}
+
 // GetChannelID mocks base method.
 func (m *MockStore) GetChannelID() string {
 	m.ctrl.T.Helper()
@@ -135,6 +174,20 @@ func (mr *MockStoreMockRecorder) GetChannelSequence() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChannelSequence", reflect.TypeOf((*MockStore)(nil).GetChannelSequence))
 }
 
+// GetCustomAppMetricsHistory mocks base method.
+func (m *MockStore) GetCustomAppMetricsHistory() []types0.Submission {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomAppMetricsHistory")
+	ret0, _ := ret[0].([]types0.Submission)
+	return ret0
+}
+
+// GetCustomAppMetricsHistory indicates an expected call of GetCustomAppMetricsHistory.
+func (mr *MockStoreMockRecorder) GetCustomAppMetricsHistory() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomAppMetricsHistory", reflect.TypeOf((*MockStore)(nil).GetCustomAppMetricsHistory))
+}
+
 // GetLicense mocks base method.
 func (m *MockStore) GetLicense() *v1beta1.License {
 	m.ctrl.T.Helper()
@@ -150,10 +203,10 @@ func (mr *MockStoreMockRecorder) GetLicense() *gomock.Call {
 }
 
 // GetLicenseFields mocks base method.
-func (m *MockStore) GetLicenseFields() types0.LicenseFields {
+func (m *MockStore) GetLicenseFields() types1.LicenseFields {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetLicenseFields")
-	ret0, _ := ret[0].(types0.LicenseFields)
+	ret0, _ := ret[0].(types1.LicenseFields)
 	return ret0
 }
 
@@ -248,10 +301,10 @@ func (mr *MockStoreMockRecorder) GetReplicatedID() *gomock.Call {
 }
 
 // GetUpdates mocks base method.
-func (m *MockStore) GetUpdates() []types1.ChannelRelease {
+func (m *MockStore) GetUpdates() []types2.ChannelRelease {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetUpdates")
-	ret0, _ := ret[0].([]types1.ChannelRelease)
+	ret0, _ := ret[0].([]types2.ChannelRelease)
 	return ret0
 }
 
@@ -301,6 +354,18 @@ func (mr *MockStoreMockRecorder) SetAppStatus(status interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAppStatus", reflect.TypeOf((*MockStore)(nil).SetAppStatus), status)
 }
 
+// SetAppStatusWithTransition mocks base method.
+func (m *MockStore) SetAppStatusWithTransition(status types.AppStatus, transition types.AppStatusTransition) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetAppStatusWithTransition", status, transition)
+}
+
+// SetAppStatusWithTransition indicates an expected call of SetAppStatusWithTransition.
+func (mr *MockStoreMockRecorder) SetAppStatusWithTransition(status, transition interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAppStatusWithTransition", reflect.TypeOf((*MockStore)(nil).SetAppStatusWithTransition), status, transition)
+}
+
 // SetLicense mocks base method.
 func (m *MockStore) SetLicense(license *v1beta1.License) {
 	m.ctrl.T.Helper()
@@ -314,7 +379,7 @@ func (mr *MockStoreMockRecorder) SetLicense(license interface{}) *gomock.Call {
 }
 
 // SetLicenseFields mocks base method.
-func (m *MockStore) SetLicenseFields(licenseFields types0.LicenseFields) {
+func (m *MockStore) SetLicenseFields(licenseFields types1.LicenseFields) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "SetLicenseFields", licenseFields)
 }
@@ -326,7 +391,7 @@ func (mr *MockStoreMockRecorder) SetLicenseFields(licenseFields interface{}) *go
 }
 
 // SetUpdates mocks base method.
-func (m *MockStore) SetUpdates(updates []types1.ChannelRelease) {
+func (m *MockStore) SetUpdates(updates []types2.ChannelRelease) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "SetUpdates", updates)
 }
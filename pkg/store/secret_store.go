@@ -0,0 +1,252 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
+	sdklicensetypes "github.com/replicatedhq/replicated-sdk/pkg/license/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	upstreamtypes "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	StoreSecretName = "replicated-store"
+	StoreSecretKey  = "checkpoint"
+)
+
+var _ Store = (*SecretStore)(nil)
+
+// SecretStore is an in-memory store that checkpoints the values that are refreshed at runtime
+// (app status and its history, updates, license and license fields) to a Kubernetes secret so that they survive pod restarts.
+type SecretStore struct {
+	*InMemoryStore
+
+	clientset kubernetes.Interface
+	mtx       sync.Mutex
+	lastSaved []byte
+
+	// the custom app metrics history is checkpointed to its own secret on an interval, instead of on every submission
+	customAppMetricsHistoryMtx       sync.Mutex
+	customAppMetricsHistoryDirty     atomic.Bool
+	lastSavedCustomAppMetricsHistory []byte
+}
+
+type InitSecretStoreOptions struct {
+	InitInMemoryStoreOptions
+	Clientset kubernetes.Interface
+}
+
+type storeCheckpoint struct {
+	License          *kotsv1beta1.License                `json:"license,omitempty"`
+	LicenseFields    sdklicensetypes.LicenseFields       `json:"licenseFields,omitempty"`
+	AppStatus        appstatetypes.AppStatus             `json:"appStatus"`
+	AppStatusHistory []appstatetypes.AppStatusTransition `json:"appStatusHistory,omitempty"`
+	Updates          []upstreamtypes.ChannelRelease      `json:"updates,omitempty"`
+}
+
+// InitSecret initializes a secret backed store and rehydrates it from the last checkpoint, if one exists.
+func InitSecret(options InitSecretStoreOptions) error {
+	s := &SecretStore{
+		InMemoryStore: newInMemoryStore(options.InitInMemoryStoreOptions),
+		clientset:     options.Clientset,
+	}
+
+	if err := s.rehydrate(context.TODO(), false); err != nil {
+		return errors.Wrap(err, "failed to rehydrate store")
+	}
+
+	SetStore(s)
+
+	return nil
+}
+
+func (s *SecretStore) SetLicense(license *kotsv1beta1.License) {
+	s.InMemoryStore.SetLicense(license)
+	s.checkpoint()
+}
+
+func (s *SecretStore) SetLicenseFields(licenseFields sdklicensetypes.LicenseFields) {
+	s.InMemoryStore.SetLicenseFields(licenseFields)
+	s.checkpoint()
+}
+
+func (s *SecretStore) SetAppStatus(status appstatetypes.AppStatus) {
+	s.InMemoryStore.SetAppStatus(status)
+	s.checkpoint()
+}
+
+func (s *SecretStore) AddAppStatusTransition(transition appstatetypes.AppStatusTransition) {
+	s.InMemoryStore.AddAppStatusTransition(transition)
+	s.checkpoint()
+}
+
+// SetAppStatusWithTransition sets the app status and records the transition to it with a single checkpoint.
+func (s *SecretStore) SetAppStatusWithTransition(status appstatetypes.AppStatus, transition appstatetypes.AppStatusTransition) {
+	s.InMemoryStore.SetAppStatusWithTransition(status, transition)
+	s.checkpoint()
+}
+
+func (s *SecretStore) SetUpdates(updates []upstreamtypes.ChannelRelease) {
+	s.InMemoryStore.SetUpdates(updates)
+	s.checkpoint()
+}
+
+// Reload refreshes the store from the last checkpoint. This is used by replicas that are not the leader
+// to serve the state that is maintained by the leader, the changes are published to the subscribers of this replica.
+func (s *SecretStore) Reload(ctx context.Context) error {
+	return s.rehydrate(ctx, true)
+}
+
+// rehydrate restores the store from the last checkpoint. Events are only published if publish is true, the state that is
+// restored when the store is initialized was published before the restart (e.g. the updates were already announced).
+func (s *SecretStore) rehydrate(ctx context.Context, publish bool) error {
+	if err := s.rehydrateCustomAppMetricsHistory(ctx); err != nil {
+		return errors.Wrap(err, "failed to rehydrate custom app metrics history")
+	}
+
+	secret, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Get(ctx, StoreSecretName, metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to get store secret")
+	}
+
+	data, ok := secret.Data[StoreSecretKey]
+	if !ok || len(data) == 0 {
+		return nil
+	}
+
+	var c storeCheckpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		// a corrupt checkpoint should not prevent the sdk from starting, it will be overwritten on the next save
+		logger.Infof("failed to unmarshal store checkpoint, ignoring: %v", err)
+		return nil
+	}
+
+	// the license and its fields are only restored if the checkpoint is not older than the license
+	// the store was initialized with (e.g. the license was not updated as part of an upgrade)
+	if c.License != nil && s.license != nil && c.License.Spec.LicenseID == s.license.Spec.LicenseID {
+		if c.License.Spec.LicenseSequence > s.license.Spec.LicenseSequence {
+			if publish {
+				s.InMemoryStore.SetLicense(c.License)
+			} else {
+				s.license = c.License.DeepCopy()
+			}
+		}
+		if c.License.Spec.LicenseSequence >= s.license.Spec.LicenseSequence && c.LicenseFields != nil {
+			s.InMemoryStore.SetLicenseFields(c.LicenseFields)
+		}
+	}
+
+	// app status and updates from a different app (e.g. a reused namespace) are not restored
+	if c.AppStatus.AppSlug == s.GetAppSlug() {
+		if publish {
+			s.InMemoryStore.SetAppStatus(c.AppStatus)
+			s.InMemoryStore.SetUpdates(c.Updates)
+		} else {
+			s.appStatus = c.AppStatus
+			s.updates = c.Updates
+		}
+		s.InMemoryStore.setAppStatusHistory(c.AppStatusHistory)
+	}
+
+	s.lastSaved = data
+
+	return nil
+}
+
+func (s *SecretStore) checkpoint() {
+	if !leader.IsLeader() {
+		// only the leader writes the checkpoint, other replicas reload it
+		return
+	}
+	if err := s.save(context.TODO()); err != nil {
+		logger.Error(errors.Wrap(err, "failed to checkpoint store"))
+	}
+}
+
+func (s *SecretStore) save(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	data, err := json.Marshal(storeCheckpoint{
+		License:          s.GetLicense(),
+		LicenseFields:    s.GetLicenseFields(),
+		AppStatus:        s.GetAppStatus(),
+		AppStatusHistory: s.GetAppStatusHistory(),
+		Updates:          s.GetUpdates(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal checkpoint")
+	}
+
+	if bytes.Equal(data, s.lastSaved) {
+		return nil
+	}
+
+	existingSecret, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Get(ctx, StoreSecretName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get store secret")
+	}
+
+	if kuberneteserrors.IsNotFound(err) {
+		uid, err := util.GetReplicatedDeploymentUID(s.clientset, s.GetNamespace())
+		if err != nil {
+			return errors.Wrap(err, "failed to get replicated deployment uid")
+		}
+
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      StoreSecretName,
+				Namespace: s.GetNamespace(),
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       util.GetReplicatedDeploymentName(),
+						UID:        uid,
+					},
+				},
+			},
+			Data: map[string][]byte{
+				StoreSecretKey: data,
+			},
+		}
+
+		if _, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "failed to create store secret")
+		}
+
+		s.lastSaved = data
+		return nil
+	}
+
+	if existingSecret.Data == nil {
+		existingSecret.Data = map[string][]byte{}
+	}
+	existingSecret.Data[StoreSecretKey] = data
+
+	if _, err := s.clientset.CoreV1().Secrets(s.GetNamespace()).Update(ctx, existingSecret, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update store secret")
+	}
+
+	s.lastSaved = data
+	return nil
+}
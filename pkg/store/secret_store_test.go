@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
+	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/events"
+	sdklicensetypes "github.com/replicatedhq/replicated-sdk/pkg/license/types"
+	upstreamtypes "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testLicense(licenseID string, sequence int64) *kotsv1beta1.License {
+	return &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			LicenseID:       licenseID,
+			AppSlug:         "app-slug",
+			LicenseSequence: sequence,
+		},
+	}
+}
+
+func TestSecretStore_CheckpointAndRehydrate(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replicated",
+			Namespace: "default",
+			UID:       "deployment-uid",
+		},
+	})
+
+	options := InitSecretStoreOptions{
+		InitInMemoryStoreOptions: InitInMemoryStoreOptions{
+			License:   testLicense("license-id", 1),
+			Namespace: "default",
+		},
+		Clientset: clientset,
+	}
+
+	// first start, nothing to rehydrate
+	req.NoError(InitSecret(options))
+	req.Equal(appstatetypes.AppStatus{}, GetStore().GetAppStatus())
+
+	appStatus := appstatetypes.AppStatus{
+		AppSlug: "app-slug",
+		State:   appstatetypes.StateDegraded,
+		ResourceStates: appstatetypes.ResourceStates{
+			{Kind: "deployment", Name: "app", Namespace: "default", State: appstatetypes.StateDegraded},
+		},
+	}
+	updates := []upstreamtypes.ChannelRelease{{VersionLabel: "1.0.1"}}
+	licenseFields := sdklicensetypes.LicenseFields{"seats": {Name: "seats", Value: float64(10)}}
+
+	GetStore().SetLicense(testLicense("license-id", 2))
+	GetStore().SetLicenseFields(licenseFields)
+	GetStore().SetAppStatus(appStatus)
+	GetStore().AddAppStatusTransition(appstatetypes.AppStatusTransition{PreviousState: appstatetypes.StateReady, State: appstatetypes.StateDegraded})
+	GetStore().AddCustomAppMetrics(custommetricstypes.Submission{Data: map[string]interface{}{"activeUsers": 10}})
+	GetStore().(*SecretStore).CheckpointCustomAppMetricsHistory(context.Background())
+	GetStore().SetUpdates(updates)
+
+	// restart with the original license, the restored state was published before the restart
+	lastEventID := events.LastEventID()
+	req.NoError(InitSecret(options))
+	req.Equal(lastEventID, events.LastEventID(), "rehydrating publishes no events")
+	req.Equal(int64(2), GetStore().GetLicense().Spec.LicenseSequence)
+	req.Equal(licenseFields, GetStore().GetLicenseFields())
+	req.Equal(appStatus.State, GetStore().GetAppStatus().State)
+	req.Equal(appStatus.ResourceStates, GetStore().GetAppStatus().ResourceStates)
+	req.Len(GetStore().GetAppStatusHistory(), 1)
+	req.Equal(appstatetypes.StateDegraded, GetStore().GetAppStatusHistory()[0].State)
+	req.Len(GetStore().GetCustomAppMetricsHistory(), 1)
+	req.Equal(float64(10), GetStore().GetCustomAppMetricsHistory()[0].Data["activeUsers"])
+	req.Equal(updates, GetStore().GetUpdates())
+
+	// restart with a newer license, the checkpointed license and fields are stale
+	options.License = testLicense("license-id", 3)
+	req.NoError(InitSecret(options))
+	req.Equal(int64(3), GetStore().GetLicense().Spec.LicenseSequence)
+	req.Nil(GetStore().GetLicenseFields())
+	req.Equal(appStatus.State, GetStore().GetAppStatus().State)
+
+	// restart with a different license
+	options.License = testLicense("other-license-id", 1)
+	req.NoError(InitSecret(options))
+	req.Equal("other-license-id", GetStore().GetLicense().Spec.LicenseID)
+	req.Nil(GetStore().GetLicenseFields())
+}
+
+func TestSecretStore_SetAppStatusWithTransition(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replicated",
+			Namespace: "default",
+			UID:       "deployment-uid",
+		},
+	})
+	req.NoError(InitSecret(InitSecretStoreOptions{
+		InitInMemoryStoreOptions: InitInMemoryStoreOptions{
+			License:   testLicense("license-id", 1),
+			Namespace: "default",
+		},
+		Clientset: clientset,
+	}))
+	GetStore().SetAppStatus(appstatetypes.AppStatus{AppSlug: "app-slug", State: appstatetypes.StateReady})
+	clientset.ClearActions()
+
+	// the status and the transition are written with a single checkpoint
+	GetStore().SetAppStatusWithTransition(
+		appstatetypes.AppStatus{AppSlug: "app-slug", State: appstatetypes.StateDegraded},
+		appstatetypes.AppStatusTransition{PreviousState: appstatetypes.StateReady, State: appstatetypes.StateDegraded},
+	)
+	writes := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "create" || action.GetVerb() == "update" {
+			writes++
+		}
+	}
+	req.Equal(1, writes)
+
+	req.Equal(appstatetypes.StateDegraded, GetStore().GetAppStatus().State)
+	req.Len(GetStore().GetAppStatusHistory(), 1)
+}
+
+func TestInMemoryStore_AppStatusHistoryLimit(t *testing.T) {
+	req := require.New(t)
+
+	s := &InMemoryStore{}
+	for i := 0; i < AppStatusHistoryLimit+10; i++ {
+		s.AddAppStatusTransition(appstatetypes.AppStatusTransition{Sequence: int64(i)})
+	}
+
+	history := s.GetAppStatusHistory()
+	req.Len(history, AppStatusHistoryLimit)
+	req.Equal(int64(10), history[0].Sequence, "the oldest transitions are dropped")
+	req.Equal(int64(AppStatusHistoryLimit+9), history[len(history)-1].Sequence)
+}
+
+func TestInMemoryStore_CustomAppMetricsHistoryLimit(t *testing.T) {
+	req := require.New(t)
+
+	s := &InMemoryStore{}
+	for i := 0; i < CustomAppMetricsHistoryLimit+10; i++ {
+		s.AddCustomAppMetrics(custommetricstypes.Submission{Data: map[string]interface{}{"count": i}})
+	}
+
+	history := s.GetCustomAppMetricsHistory()
+	req.Len(history, CustomAppMetricsHistoryLimit)
+	req.Equal(10, history[0].Data["count"], "the oldest submissions are dropped")
+	req.Equal(CustomAppMetricsHistoryLimit+9, history[len(history)-1].Data["count"])
+}
+
+func TestSecretStore_CustomAppMetricsHistoryCheckpoint(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replicated",
+			Namespace: "default",
+			UID:       "deployment-uid",
+		},
+	})
+
+	req.NoError(InitSecret(InitSecretStoreOptions{
+		InitInMemoryStoreOptions: InitInMemoryStoreOptions{
+			License:   testLicense("license-id", 1),
+			Namespace: "default",
+		},
+		Clientset: clientset,
+	}))
+	secretStore := GetStore().(*SecretStore)
+
+	// submissions are not checkpointed when they are added
+	clientset.ClearActions()
+	for i := 0; i < 10; i++ {
+		secretStore.AddCustomAppMetrics(custommetricstypes.Submission{Data: map[string]interface{}{"count": i}})
+	}
+	req.Empty(clientset.Actions())
+
+	// the history is checkpointed to its own secret, and only if it changed
+	secretStore.CheckpointCustomAppMetricsHistory(context.Background())
+	secret, err := clientset.CoreV1().Secrets("default").Get(context.Background(), CustomAppMetricsHistorySecretName, metav1.GetOptions{})
+	req.NoError(err)
+	req.Contains(string(secret.Data[CustomAppMetricsHistorySecretKey]), `"count":9`)
+
+	storeSecret, err := clientset.CoreV1().Secrets("default").Get(context.Background(), StoreSecretName, metav1.GetOptions{})
+	if err == nil {
+		req.NotContains(string(storeSecret.Data[StoreSecretKey]), `"count"`)
+	}
+
+	clientset.ClearActions()
+	secretStore.CheckpointCustomAppMetricsHistory(context.Background())
+	req.Empty(clientset.Actions())
+
+	// the history is restored on restart
+	req.NoError(InitSecret(InitSecretStoreOptions{
+		InitInMemoryStoreOptions: InitInMemoryStoreOptions{
+			License:   testLicense("license-id", 1),
+			Namespace: "default",
+		},
+		Clientset: clientset,
+	}))
+	req.Len(GetStore().GetCustomAppMetricsHistory(), 10)
+}
+
+func TestInMemoryStore_CustomAppMetricsHistorySizeLimit(t *testing.T) {
+	req := require.New(t)
+
+	s := &InMemoryStore{}
+	value := strings.Repeat("x", 10*1024)
+	for i := 0; i < 100; i++ {
+		s.AddCustomAppMetrics(custommetricstypes.Submission{Data: map[string]interface{}{"value": value, "count": i}})
+	}
+
+	history := s.GetCustomAppMetricsHistory()
+	req.Less(len(history), 100, "the oldest submissions are dropped")
+	req.Equal(99, history[len(history)-1].Data["count"])
+
+	size := 0
+	for _, submission := range history {
+		size += customAppMetricsSize(submission)
+	}
+	req.LessOrEqual(size, CustomAppMetricsHistorySizeLimit)
+}
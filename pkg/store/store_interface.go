@@ -3,6 +3,7 @@ package store
 import (
 	kotsv1beta1 "github.com/replicatedhq/kotskinds/apis/kots/v1beta1"
 	appstatetypes "github.com/replicatedhq/replicated-sdk/pkg/appstate/types"
+	custommetricstypes "github.com/replicatedhq/replicated-sdk/pkg/custommetrics/types"
 	sdklicensetypes "github.com/replicatedhq/replicated-sdk/pkg/license/types"
 	upstreamtypes "github.com/replicatedhq/replicated-sdk/pkg/upstream/types"
 )
@@ -34,6 +35,11 @@ type Store interface {
 	GetNamespace() string
 	GetAppStatus() appstatetypes.AppStatus
 	SetAppStatus(status appstatetypes.AppStatus)
+	GetAppStatusHistory() []appstatetypes.AppStatusTransition
+	AddAppStatusTransition(transition appstatetypes.AppStatusTransition)
+	SetAppStatusWithTransition(status appstatetypes.AppStatus, transition appstatetypes.AppStatusTransition)
+	GetCustomAppMetricsHistory() []custommetricstypes.Submission
+	AddCustomAppMetrics(submission custommetricstypes.Submission)
 	GetUpdates() []upstreamtypes.ChannelRelease
 	SetUpdates(updates []upstreamtypes.ChannelRelease)
 }
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	eventstypes "github.com/replicatedhq/replicated-sdk/pkg/events/types"
+)
+
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Secret is the shared secret the payloads are signed with (HMAC-SHA256)
+	Secret string `yaml:"secret"`
+	// Events are the event types that are sent to the webhook, defaults to "app-state", "license" and "updates"
+	Events []eventstypes.EventType `yaml:"events"`
+}
+
+// DefaultEvents are the event types that are sent to webhooks that don't configure any.
+// App status events are not included by default since they are sent on every resource change.
+var DefaultEvents = []eventstypes.EventType{eventstypes.EventTypeAppState, eventstypes.EventTypeLicense, eventstypes.EventTypeUpdates}
+
+func (c WebhookConfig) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid webhook url %q", c.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("invalid webhook url %q, expected an http or https url", c.URL)
+	}
+	if c.Secret == "" {
+		return errors.Errorf("webhook %q has no secret", c.URL)
+	}
+	for _, event := range c.Events {
+		if !slices.Contains(eventstypes.EventTypes, event) {
+			return errors.Errorf("webhook %q has an unknown event type %q", c.URL, event)
+		}
+	}
+	return nil
+}
+
+func (c WebhookConfig) IsSubscribed(eventType eventstypes.EventType) bool {
+	if len(c.Events) == 0 {
+		return slices.Contains(DefaultEvents, eventType)
+	}
+	return slices.Contains(c.Events, eventType)
+}
+
+// Payload is the body that is sent to webhooks.
+type Payload struct {
+	ID        string                `json:"id"`
+	Event     eventstypes.EventType `json:"event"`
+	Timestamp time.Time             `json:"timestamp"`
+	AppSlug   string                `json:"appSlug"`
+	AppID     string                `json:"appID,omitempty"`
+	Data      json.RawMessage       `json:"data"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+type Delivery struct {
+	ID          string                `json:"id"`
+	URL         string                `json:"url"`
+	Event       eventstypes.EventType `json:"event"`
+	Status      DeliveryStatus        `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	StatusCode  int                   `json:"statusCode,omitempty"`
+	Error       string                `json:"error,omitempty"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	CompletedAt time.Time             `json:"completedAt"`
+}
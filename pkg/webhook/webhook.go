@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/replicated-sdk/pkg/events"
+	eventstypes "github.com/replicatedhq/replicated-sdk/pkg/events/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/leader"
+	"github.com/replicatedhq/replicated-sdk/pkg/logger"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/replicatedhq/replicated-sdk/pkg/util"
+	"github.com/replicatedhq/replicated-sdk/pkg/webhook/types"
+)
+
+const (
+	// SignatureHeader contains the hex encoded HMAC-SHA256 of the request body, prefixed with "sha256="
+	SignatureHeader = "X-Replicated-Signature"
+	EventHeader     = "X-Replicated-Event"
+	DeliveryHeader  = "X-Replicated-Delivery"
+
+	// DeliveryLogLimit is the number of deliveries that are kept in the delivery log, the oldest deliveries are dropped first
+	DeliveryLogLimit = 100
+
+	queueSize          = 100
+	requestTimeout     = 10 * time.Second
+	resubscribeBackoff = time.Second
+)
+
+var (
+	dispatcherMtx    sync.Mutex
+	cancelDispatcher context.CancelFunc
+
+	deliveryLog    []types.Delivery
+	deliveryLogMtx sync.Mutex
+
+	httpClient = &http.Client{Timeout: requestTimeout}
+
+	newBackOff = func() backoff.BackOff {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = time.Second
+		b.MaxInterval = time.Minute
+		b.MaxElapsedTime = 10 * time.Minute
+		return b
+	}
+)
+
+type worker struct {
+	config types.WebhookConfig
+	queue  chan types.Payload
+}
+
+// Start sends the published events to the configured webhooks until the context is done.
+// Only the leader sends webhooks, so that each event is delivered once. A previously started dispatcher is stopped.
+func Start(ctx context.Context, configs []types.WebhookConfig) error {
+	for _, config := range configs {
+		if err := config.Validate(); err != nil {
+			return errors.Wrap(err, "invalid webhook config")
+		}
+	}
+
+	Stop()
+
+	if len(configs) == 0 {
+		return nil
+	}
+
+	dispatcherMtx.Lock()
+	defer dispatcherMtx.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancelDispatcher = cancel
+
+	workers := []*worker{}
+	for _, config := range configs {
+		w := &worker{
+			config: config,
+			queue:  make(chan types.Payload, queueSize),
+		}
+		workers = append(workers, w)
+		go w.run(ctx)
+	}
+
+	sub, _, _ := events.Subscribe("")
+	go dispatch(ctx, sub, workers)
+
+	return nil
+}
+
+// Stop stops sending webhooks, deliveries that are in progress are abandoned.
+func Stop() {
+	dispatcherMtx.Lock()
+	defer dispatcherMtx.Unlock()
+
+	if cancelDispatcher != nil {
+		cancelDispatcher()
+		cancelDispatcher = nil
+	}
+}
+
+// GetDeliveries returns the most recent deliveries, oldest first.
+func GetDeliveries() []types.Delivery {
+	deliveryLogMtx.Lock()
+	defer deliveryLogMtx.Unlock()
+	return append([]types.Delivery{}, deliveryLog...)
+}
+
+// Sign returns the value of the signature header for a request body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func dispatch(ctx context.Context, sub *events.Subscription, workers []*worker) {
+	var lastEventID string
+	for {
+		select {
+		case <-ctx.Done():
+			events.Unsubscribe(sub)
+			return
+		case event := <-sub.Events():
+			lastEventID = strconv.FormatUint(event.ID, 10)
+			enqueue(event, workers)
+		case <-sub.Done():
+			// the subscription was dropped because the workers fell behind, resume from the last event
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeBackoff):
+			}
+			var missed []eventstypes.Event
+			sub, missed, _ = events.Subscribe(lastEventID)
+			for _, event := range missed {
+				lastEventID = strconv.FormatUint(event.ID, 10)
+				enqueue(event, workers)
+			}
+		}
+	}
+}
+
+func enqueue(event eventstypes.Event, workers []*worker) {
+	if !leader.IsLeader() {
+		return
+	}
+
+	payload := types.Payload{
+		ID:        strconv.FormatUint(event.ID, 10),
+		Event:     event.Type,
+		Timestamp: event.Timestamp,
+		AppSlug:   store.GetStore().GetAppSlug(),
+		AppID:     store.GetStore().GetAppID(),
+		Data:      event.Data,
+	}
+
+	for _, w := range workers {
+		if !w.config.IsSubscribed(event.Type) {
+			continue
+		}
+		select {
+		case w.queue <- payload:
+		default:
+			recordDelivery(types.Delivery{
+				ID:          payload.ID,
+				URL:         w.config.URL,
+				Event:       payload.Event,
+				Status:      types.DeliveryStatusFailed,
+				Error:       "delivery queue is full",
+				CreatedAt:   time.Now().UTC(),
+				CompletedAt: time.Now().UTC(),
+			})
+		}
+	}
+}
+
+// run delivers the payloads one at a time so that a webhook receives the events in order.
+func (w *worker) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-w.queue:
+			recordDelivery(deliver(ctx, w.config, payload))
+		}
+	}
+}
+
+func deliver(ctx context.Context, config types.WebhookConfig, payload types.Payload) types.Delivery {
+	delivery := types.Delivery{
+		ID:        payload.ID,
+		URL:       config.URL,
+		Event:     payload.Event,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Status = types.DeliveryStatusFailed
+		delivery.Error = errors.Wrap(err, "failed to marshal payload").Error()
+		delivery.CompletedAt = time.Now().UTC()
+		return delivery
+	}
+	signature := Sign(config.Secret, body)
+
+	operation := func() error {
+		delivery.Attempts++
+
+		req, err := util.NewRequest("POST", config.URL, bytes.NewReader(body))
+		if err != nil {
+			return backoff.Permanent(errors.Wrap(err, "failed to create request"))
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(SignatureHeader, signature)
+		req.Header.Set(EventHeader, string(payload.Event))
+		req.Header.Set(DeliveryHeader, payload.ID)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "failed to send request")
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+		delivery.StatusCode = resp.StatusCode
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+
+		err = errors.Errorf("unexpected status code %d", resp.StatusCode)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout {
+			return err
+		}
+		return backoff.Permanent(err)
+	}
+
+	if err := backoff.Retry(operation, backoff.WithContext(newBackOff(), ctx)); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to deliver %s event %s to webhook %s", payload.Event, payload.ID, config.URL))
+		delivery.Status = types.DeliveryStatusFailed
+		delivery.Error = err.Error()
+	} else {
+		delivery.Status = types.DeliveryStatusSucceeded
+	}
+	delivery.CompletedAt = time.Now().UTC()
+
+	return delivery
+}
+
+func recordDelivery(delivery types.Delivery) {
+	deliveryLogMtx.Lock()
+	defer deliveryLogMtx.Unlock()
+
+	deliveryLog = append(deliveryLog, delivery)
+	if len(deliveryLog) > DeliveryLogLimit {
+		deliveryLog = deliveryLog[len(deliveryLog)-DeliveryLogLimit:]
+	}
+}
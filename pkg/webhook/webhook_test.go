@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/replicatedhq/replicated-sdk/pkg/events"
+	eventstypes "github.com/replicatedhq/replicated-sdk/pkg/events/types"
+	"github.com/replicatedhq/replicated-sdk/pkg/store"
+	"github.com/replicatedhq/replicated-sdk/pkg/webhook/types"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	newBackOff = func() backoff.BackOff {
+		return backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 3)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"event":"app-state"}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t, "sha256=1b747485fdd84dc429aad808587e325db145c647bef6bbda179ca4ad13dd1c12", Sign("secret", []byte(`{"event":"app-state"}`)))
+	require.NotEqual(t, Sign("secret", []byte("body")), Sign("other-secret", []byte("body")))
+}
+
+func Test_deliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		wantStatus   types.DeliveryStatus
+		wantAttempts int
+	}{
+		{
+			name:         "success",
+			statusCodes:  []int{http.StatusOK},
+			wantStatus:   types.DeliveryStatusSucceeded,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retried after server errors",
+			statusCodes:  []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusNoContent},
+			wantStatus:   types.DeliveryStatusSucceeded,
+			wantAttempts: 3,
+		},
+		{
+			name:         "client errors are not retried",
+			statusCodes:  []int{http.StatusBadRequest},
+			wantStatus:   types.DeliveryStatusFailed,
+			wantAttempts: 1,
+		},
+		{
+			name:         "gives up after the retries",
+			statusCodes:  []int{http.StatusInternalServerError},
+			wantStatus:   types.DeliveryStatusFailed,
+			wantAttempts: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			payload := types.Payload{
+				ID:      "1",
+				Event:   eventstypes.EventTypeAppState,
+				AppSlug: "app-slug",
+				Data:    json.RawMessage(`{"state":"ready"}`),
+			}
+
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(requests.Add(1)) - 1
+				body, err := io.ReadAll(r.Body)
+				req.NoError(err)
+				req.Equal(Sign("secret", body), r.Header.Get(SignatureHeader))
+				req.Equal("app-state", r.Header.Get(EventHeader))
+				req.Equal("1", r.Header.Get(DeliveryHeader))
+
+				var got types.Payload
+				req.NoError(json.Unmarshal(body, &got))
+				req.Equal(payload, got)
+
+				w.WriteHeader(tt.statusCodes[min(i, len(tt.statusCodes)-1)])
+			}))
+			defer srv.Close()
+
+			delivery := deliver(context.Background(), types.WebhookConfig{URL: srv.URL, Secret: "secret"}, payload)
+			req.Equal(tt.wantStatus, delivery.Status)
+			req.Equal(tt.wantAttempts, delivery.Attempts)
+			req.Equal(tt.statusCodes[min(tt.wantAttempts, len(tt.statusCodes))-1], delivery.StatusCode)
+			if tt.wantStatus == types.DeliveryStatusFailed {
+				req.NotEmpty(delivery.Error)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	req := require.New(t)
+
+	store.SetStore(&store.InMemoryStore{})
+	defer store.SetStore(nil)
+
+	received := make(chan types.Payload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload types.Payload
+		req.NoError(json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req.Error(Start(ctx, []types.WebhookConfig{{URL: srv.URL}}), "a secret is required")
+	req.NoError(Start(ctx, []types.WebhookConfig{{URL: srv.URL, Secret: "secret"}}))
+	defer Stop()
+
+	// app status events are not sent by default
+	events.Publish(eventstypes.EventTypeAppStatus, map[string]string{"state": "degraded"})
+	events.Publish(eventstypes.EventTypeAppState, map[string]string{"state": "degraded"})
+
+	select {
+	case payload := <-received:
+		req.Equal(eventstypes.EventTypeAppState, payload.Event)
+		req.JSONEq(`{"state":"degraded"}`, string(payload.Data))
+	case <-time.After(5 * time.Second):
+		req.Fail("webhook was not delivered")
+	}
+
+	req.Eventually(func() bool {
+		deliveries := GetDeliveries()
+		return len(deliveries) > 0 && deliveries[len(deliveries)-1].Status == types.DeliveryStatusSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  types.WebhookConfig
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			config: types.WebhookConfig{URL: "https://example.com/hook", Secret: "secret", Events: []eventstypes.EventType{"license"}},
+		},
+		{
+			name:    "not an http url",
+			config:  types.WebhookConfig{URL: "example.com/hook", Secret: "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "no secret",
+			config:  types.WebhookConfig{URL: "https://example.com/hook"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown event",
+			config:  types.WebhookConfig{URL: "https://example.com/hook", Secret: "secret", Events: []eventstypes.EventType{"deploy"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}